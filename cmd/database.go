@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/app"
+	"github.com/gomonov/otus-go-project/internal/config"
+	"github.com/gomonov/otus-go-project/internal/feed"
+	"github.com/gomonov/otus-go-project/internal/logger"
+	migrations "github.com/gomonov/otus-go-project/internal/migration"
+	"github.com/gomonov/otus-go-project/internal/storage/sqlstorage"
+)
+
+// databaseRetryInterval - пауза между попытками подключиться к базе при старте.
+const databaseRetryInterval = 5 * time.Second
+
+// runDatabase ждёт, пока база станет доступна, применяет миграции и запускает работу со списками в базе:
+// загрузку списков вместо снимка, их фоновое перечитывание, удаление истёкших подсетей, синхронизацию фидов
+// и подписку на изменения.
+func runDatabase(ctx context.Context, logg *logger.Logger, cfg *config.Config,
+	store *sqlstorage.Storage, application *app.App, feeds []feed.Feed,
+) {
+	if !waitForDatabase(ctx, logg, cfg, store) {
+		return
+	}
+
+	if err := application.LoadIPLists(); err != nil {
+		logg.Error("Failed to load IP lists: " + err.Error())
+	}
+	if err := application.LoadGeoRules(); err != nil {
+		logg.Error("Failed to load geo rules: " + err.Error())
+	}
+	if err := application.LoadLoginRules(); err != nil {
+		logg.Error("Failed to load login rules: " + err.Error())
+	}
+	go application.RunCacheRefresher(ctx)
+	go application.RunExpirySweeper(ctx, cfg.App.SweepInterval)
+	application.RunFeedSync(ctx, feeds)
+
+	subnetListener, err := sqlstorage.NewSubnetListener(cfg.Storage.Dsn)
+	if err != nil {
+		logg.Error("Failed to listen for subnet changes, IP lists will be reloaded by cache TTL: " + err.Error())
+		return
+	}
+	defer subnetListener.Close()
+
+	go application.RunListener(ctx, subnetListener)
+	subnetListener.Run(ctx)
+}
+
+// waitForDatabase повторяет подключение и миграции, пока они не пройдут. Возвращает false, если контекст завершился.
+func waitForDatabase(ctx context.Context, logg *logger.Logger, cfg *config.Config, store *sqlstorage.Storage) bool {
+	for {
+		err := store.Ping(ctx)
+		if err == nil {
+			err = migrations.AutoMigrate(logg, migrations.Conf(cfg.Migrations))
+		}
+		if err == nil {
+			return true
+		}
+
+		logg.Error("Database is unavailable, retrying: " + err.Error())
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(databaseRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/config"
+	"github.com/gomonov/otus-go-project/internal/feed"
+)
+
+// defaultFeedInterval - период синхронизации фида, для которого Interval не задан.
+const defaultFeedInterval = time.Hour
+
+func newFeeds(feedsConf []config.FeedConf) ([]feed.Feed, error) {
+	feeds := make([]feed.Feed, 0, len(feedsConf))
+	names := make(map[string]bool, len(feedsConf))
+
+	for _, conf := range feedsConf {
+		if conf.Name == "" || conf.URL == "" {
+			return nil, fmt.Errorf("feed must have Name and URL")
+		}
+		if names[conf.Name] {
+			return nil, fmt.Errorf("duplicate feed name: %s", conf.Name)
+		}
+		names[conf.Name] = true
+
+		format, err := feed.ParseFormat(conf.Format)
+		if err != nil {
+			return nil, fmt.Errorf("feed %s: %w", conf.Name, err)
+		}
+
+		interval := conf.Interval
+		if interval <= 0 {
+			interval = defaultFeedInterval
+		}
+
+		feeds = append(feeds, feed.Feed{Name: conf.Name, URL: conf.URL, Format: format, Interval: interval})
+	}
+
+	return feeds, nil
+}
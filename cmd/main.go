@@ -9,12 +9,11 @@ import (
 
 	"github.com/gomonov/otus-go-project/internal/app"
 	"github.com/gomonov/otus-go-project/internal/config"
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/gomonov/otus-go-project/internal/geoip"
 	"github.com/gomonov/otus-go-project/internal/logger"
-	migrations "github.com/gomonov/otus-go-project/internal/migration"
-	"github.com/gomonov/otus-go-project/internal/ratelimit"
 	"github.com/gomonov/otus-go-project/internal/server"
 	"github.com/gomonov/otus-go-project/internal/storage/sqlstorage"
-	"github.com/redis/go-redis/v9"
 )
 
 var configFile string
@@ -41,40 +40,59 @@ func main() {
 		panic(err)
 	}
 
-	if err = migrations.AutoMigrate(logg, migrations.Conf(cfg.Migrations)); err != nil {
-		panic(err)
-	}
-
 	store, err := sqlstorage.NewStorage(cfg.Storage.Dsn)
 	if err != nil {
 		panic(err)
 	}
 	defer store.Close()
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Address,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
 
-	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+	feeds, err := newFeeds(cfg.Feeds)
+	if err != nil {
 		store.Close()
 		panic(err)
 	}
-	defer redisClient.Close()
 
-	rateLimiter := ratelimit.NewRateLimiter(redisClient, ratelimit.Config{
-		LoginLimit:    cfg.App.LoginLimit,
-		PasswordLimit: cfg.App.PasswordLimit,
-		IPLimit:       cfg.App.IPLimit,
-		Window:        cfg.App.Window,
-	})
+	precedence, err := domain.ParseListPrecedence(cfg.App.ListPrecedence)
+	if err != nil {
+		store.Close()
+		panic(err)
+	}
 
-	application := app.New(logg, store, cfg.App.CacheTTL, rateLimiter)
+	rateLimiter, closeRateLimiter, err := newRateLimiter(ctx, logg, cfg)
+	if err != nil {
+		store.Close()
+		panic(err)
+	}
+	defer closeRateLimiter()
+
+	var geo app.GeoResolver
+	if cfg.App.GeoIPCountryDB != "" || cfg.App.GeoIPASNDB != "" {
+		geoReader, err := geoip.Open(cfg.App.GeoIPCountryDB, cfg.App.GeoIPASNDB)
+		if err != nil {
+			store.Close()
+			panic(err)
+		}
+		defer geoReader.Close()
+		geo = geoReader
+	} else {
+		logg.Info("GeoIP databases are not configured, country and ASN rules are disabled")
+	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(),
-		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
-	defer cancel()
+	application := app.New(logg, store, app.CacheConf{
+		TTL:          cfg.App.CacheTTL,
+		MaxStaleness: cfg.App.MaxStaleness,
+		SnapshotPath: cfg.App.SnapshotPath,
+		Precedence:   precedence,
+	}, rateLimiter, geo)
+	if err := application.LoadSnapshot(); err != nil {
+		logg.Warn("Failed to load IP lists snapshot, auth checks will fail until the database is available: " +
+			err.Error())
+	}
+	go runDatabase(ctx, logg, cfg, store, application, feeds)
 
 	context.AfterFunc(ctx, func() {
 		logg.Info("application is stopping...")
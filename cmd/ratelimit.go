@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gomonov/otus-go-project/internal/config"
+	"github.com/gomonov/otus-go-project/internal/logger"
+	"github.com/gomonov/otus-go-project/internal/ratelimit"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRateLimiter(
+	ctx context.Context, logg *logger.Logger, cfg *config.Config,
+) (*ratelimit.RateLimiter, func(), error) {
+	rateLimitConfig := ratelimit.Config{
+		LoginLimit:        cfg.App.LoginLimit,
+		PasswordLimit:     cfg.App.PasswordLimit,
+		IPLimit:           cfg.App.IPLimit,
+		Window:            cfg.App.Window,
+		LoginAlgorithm:    ratelimit.Algorithm(cfg.App.LoginAlgorithm),
+		PasswordAlgorithm: ratelimit.Algorithm(cfg.App.PasswordAlgorithm),
+		IPAlgorithm:       ratelimit.Algorithm(cfg.App.IPAlgorithm),
+		HashLogins:        cfg.RateLimit.HashLogins,
+		IPv4Prefix:        cfg.RateLimit.IPv4Prefix,
+		IPv6Prefix:        cfg.RateLimit.IPv6Prefix,
+		Bans: ratelimit.BanConfig{
+			Threshold:   cfg.RateLimit.BanThreshold,
+			Period:      cfg.RateLimit.BanPeriod,
+			Durations:   cfg.RateLimit.BanDurations,
+			ForgetAfter: cfg.RateLimit.BanForgetAfter,
+		},
+		GreylistLoginLimit:    cfg.App.GreylistLoginLimit,
+		GreylistPasswordLimit: cfg.App.GreylistPasswordLimit,
+		GreylistIPLimit:       cfg.App.GreylistIPLimit,
+	}
+
+	keySecrets, err := loadKeySecrets(cfg.RateLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	rateLimitConfig.KeySecrets = keySecrets
+
+	switch cfg.RateLimit.Backend {
+	case "memory":
+		logg.Info("Using in-memory rate limit backend")
+		if len(keySecrets) == 0 {
+			logg.Warn("Rate limit key secret is not configured, using a random one: " +
+				"password buckets will not survive restart")
+		}
+
+		memoryBackend := ratelimit.NewMemoryBackend(ratelimit.MemoryConfig{
+			Shards:          cfg.RateLimit.MemoryShards,
+			MaxKeys:         cfg.RateLimit.MemoryMaxKeys,
+			CleanupInterval: cfg.RateLimit.MemoryCleanupInterval,
+		})
+		go memoryBackend.Run(ctx)
+
+		rateLimiter, err := ratelimit.NewMemoryRateLimiter(memoryBackend, rateLimitConfig)
+		return rateLimiter, func() {}, err
+
+	case "redis", "":
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			redisClient.Close()
+			return nil, nil, err
+		}
+
+		rateLimiter, err := ratelimit.NewRateLimiter(redisClient, rateLimitConfig)
+		if err != nil {
+			redisClient.Close()
+			if errors.Is(err, ratelimit.ErrKeySecretRequired) {
+				err = fmt.Errorf("%w: set RateLimit.KeySecret or RateLimit.KeySecretFile "+
+					"(ABF_RATELIMIT_KEY_SECRET, ABF_RATELIMIT_KEY_SECRET_FILE)", err)
+			}
+			return nil, nil, err
+		}
+		return rateLimiter, func() { redisClient.Close() }, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown rate limit backend: %s", cfg.RateLimit.Backend)
+	}
+}
+
+func loadKeySecrets(cfg config.RateLimitConf) ([]string, error) {
+	if cfg.KeySecretFile != "" {
+		return ratelimit.LoadKeySecrets(cfg.KeySecretFile)
+	}
+
+	if cfg.KeySecret == "" {
+		return nil, nil
+	}
+
+	return append([]string{cfg.KeySecret}, cfg.PreviousKeySecrets...), nil
+}
@@ -2,10 +2,14 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sync"
 	"time"
 
 	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/gomonov/otus-go-project/internal/feed"
 	"github.com/gomonov/otus-go-project/internal/ratelimit"
 	"github.com/gomonov/otus-go-project/internal/storage"
 )
@@ -14,7 +18,27 @@ type App struct {
 	logger      Logger
 	storage     storage.Storage
 	cache       *IPListsCache
+	cacheConf   CacheConf
 	rateLimiter *ratelimit.RateLimiter
+	geo         GeoResolver
+	geoRules    *geoRulesCache
+	loginRules  *loginRulesCache
+
+	feedsMu sync.Mutex
+	feeds   []feed.Feed
+}
+
+type CacheConf struct {
+	// TTL - период фонового перечитывания списков.
+	TTL time.Duration
+	// MaxStaleness - сколько можно отвечать по последней загруженной копии списков, пока база недоступна.
+	// 0 снимает ограничение.
+	MaxStaleness time.Duration
+	// SnapshotPath - файл, в который сохраняется каждая успешная загрузка списков. Из него списки
+	// загружаются при старте, пока база недоступна. Пустой путь отключает снимки.
+	SnapshotPath string
+	// Precedence - какой из списков важнее, если IP входит в подсети обоих. По умолчанию чёрный.
+	Precedence domain.ListPrecedence
 }
 
 type Logger interface {
@@ -24,79 +48,420 @@ type Logger interface {
 	Warn(args ...interface{})
 }
 
-func New(logger Logger, storage storage.Storage, cacheTTL time.Duration, rateLimiter *ratelimit.RateLimiter) *App {
-	return &App{logger: logger, storage: storage, cache: newIPListsCache(cacheTTL), rateLimiter: rateLimiter}
+// New создаёт приложение. geo может быть nil, тогда правила по странам и ASN не применяются.
+func New(
+	logger Logger, storage storage.Storage, cacheConf CacheConf, rateLimiter *ratelimit.RateLimiter, geo GeoResolver,
+) *App {
+	return &App{
+		logger:      logger,
+		storage:     storage,
+		cache:       newIPListsCache(cacheConf.MaxStaleness, cacheConf.SnapshotPath, cacheConf.Precedence),
+		cacheConf:   cacheConf,
+		rateLimiter: rateLimiter,
+		geo:         geo,
+		geoRules:    newGeoRulesCache(),
+		loginRules:  newLoginRulesCache(),
+	}
 }
 
-func (a *App) CreateSubnet(subnet *domain.Subnet) error {
+// CreateSubnet добавляет подсеть и возвращает её пересечения с подсетями всех списков.
+// Без force подсеть с пересечениями не добавляется, а возвращается *domain.SubnetOverlapError.
+func (a *App) CreateSubnet(subnet *domain.Subnet, force bool) ([]domain.SubnetOverlap, error) {
 	a.logger.Info("Creating subnet: ", subnet.CIDR, " for list: ", subnet.ListType)
-	return a.storage.Subnet().Create(subnet)
+
+	overlapping, err := a.storage.Subnet().FindOverlapping(subnet.ListType, subnet.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check overlaps: %w", err)
+	}
+
+	overlaps := make([]domain.SubnetOverlap, 0, len(overlapping))
+	for _, other := range overlapping {
+		overlaps = append(overlaps, domain.NewSubnetOverlap(*subnet, other))
+	}
+	if len(overlaps) > 0 && !force {
+		return nil, &domain.SubnetOverlapError{Overlaps: overlaps}
+	}
+
+	if err := a.storage.Subnet().Create(subnet); err != nil {
+		return nil, err
+	}
+	if len(overlaps) > 0 {
+		a.logger.Warn("Subnet added despite overlaps",
+			"cidr", subnet.CIDR,
+			"list", subnet.ListType,
+			"overlaps", len(overlaps))
+	}
+
+	// Свои изменения применяются к кэшу сразу, не дожидаясь уведомления из базы
+	a.applySubnetChange(domain.SubnetChange{Op: domain.SubnetUpserted, Subnet: *subnet})
+	return overlaps, nil
 }
 
 func (a *App) DeleteSubnet(listType domain.ListType, cidr string) error {
 	a.logger.Info("Deleting subnet: ", cidr, " from list: ", listType)
-	return a.storage.Subnet().Delete(listType, cidr)
+	if err := a.storage.Subnet().Delete(listType, cidr); err != nil {
+		return err
+	}
+
+	a.applySubnetChange(domain.SubnetChange{
+		Op:     domain.SubnetDeleted,
+		Subnet: domain.Subnet{ListType: listType, CIDR: cidr},
+	})
+	return nil
+}
+
+// CreateSubnetRange добавляет в одной транзакции подсети, покрывающие диапазон. Остальные поля подсетей
+// берутся из template. Пересечения проверяются так же, как в CreateSubnet, для всех подсетей сразу.
+func (a *App) CreateSubnetRange(
+	template domain.Subnet, ipRange domain.IPRange, force bool,
+) ([]domain.Subnet, []domain.SubnetOverlap, error) {
+	cidrs := ipRange.CIDRs()
+	a.logger.Info("Creating range: ", ipRange.String(), " as ", len(cidrs), " subnets for list: ", template.ListType)
+
+	subnets := make([]*domain.Subnet, len(cidrs))
+	overlaps := []domain.SubnetOverlap{}
+	for i, cidr := range cidrs {
+		subnet := template
+		subnet.CIDR = cidr
+		subnets[i] = &subnet
+
+		overlapping, err := a.storage.Subnet().FindOverlapping(subnet.ListType, subnet.CIDR)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check overlaps: %w", err)
+		}
+		for _, other := range overlapping {
+			overlaps = append(overlaps, domain.NewSubnetOverlap(subnet, other))
+		}
+	}
+	if len(overlaps) > 0 && !force {
+		return nil, nil, &domain.SubnetOverlapError{Overlaps: overlaps}
+	}
+
+	if err := a.storage.Subnet().CreateAll(subnets); err != nil {
+		return nil, nil, err
+	}
+	if len(overlaps) > 0 {
+		a.logger.Warn("Range added despite overlaps",
+			"range", ipRange.String(),
+			"list", template.ListType,
+			"overlaps", len(overlaps))
+	}
+
+	created := make([]domain.Subnet, len(subnets))
+	for i, subnet := range subnets {
+		created[i] = *subnet
+		a.applySubnetChange(domain.SubnetChange{Op: domain.SubnetUpserted, Subnet: *subnet})
+	}
+	return created, overlaps, nil
+}
+
+// DeleteSubnetRange удаляет подсети, которыми был добавлен диапазон, и возвращает удалённые.
+func (a *App) DeleteSubnetRange(listType domain.ListType, ipRange domain.IPRange) ([]domain.Subnet, error) {
+	a.logger.Info("Deleting range: ", ipRange.String(), " from list: ", listType)
+	deleted, err := a.storage.Subnet().DeleteAll(listType, ipRange.CIDRs())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subnet := range deleted {
+		a.applySubnetChange(domain.SubnetChange{Op: domain.SubnetDeleted, Subnet: subnet})
+	}
+	return deleted, nil
 }
 
-func (a *App) GetSubnetsByListType(listType domain.ListType) ([]domain.Subnet, error) {
+// GetSubnetsByListType возвращает страницу подсетей списка, упорядоченных по CIDR.
+// С filter.Limit = 0 страница одна и содержит все подходящие подсети.
+func (a *App) GetSubnetsByListType(listType domain.ListType, filter domain.SubnetFilter) (domain.SubnetsPage, error) {
 	a.logger.Debug("Getting subnets for list: ", listType)
-	return a.storage.Subnet().GetByListType(listType)
+
+	limit := filter.Limit
+	if limit > 0 {
+		// Лишняя подсеть показывает, есть ли следующая страница
+		filter.Limit = limit + 1
+	}
+
+	subnets, err := a.storage.Subnet().GetByListType(listType, filter)
+	if err != nil {
+		return domain.SubnetsPage{}, err
+	}
+
+	page := domain.SubnetsPage{Subnets: subnets}
+	if limit > 0 && len(subnets) > limit {
+		page.Subnets = subnets[:limit]
+		page.NextCursor = domain.EncodeSubnetCursor(subnets[limit-1].CIDR)
+	}
+
+	return page, nil
+}
+
+// GetConflicts возвращает пересечения подсетей, уже внесённых в списки.
+func (a *App) GetConflicts() ([]domain.SubnetOverlap, error) {
+	overlaps, err := a.storage.Subnet().FindOverlaps()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, overlap := range overlaps {
+		overlaps[i] = domain.NewSubnetOverlap(overlap.Subnet, overlap.Other)
+	}
+
+	return overlaps, nil
 }
 
 func (a *App) CheckAuth(req domain.AuthRequest) (domain.AuthResponse, error) {
-	ipStatus, err := a.checkIPInLists(req.IP)
+	ipStatus, match, err := a.checkIPInLists(req.IP)
 	if err != nil {
 		return domain.AuthResponse{}, err
 	}
 
+	geo := a.lookupGeo(req.IP)
+
 	if ipStatus == domain.IPInBlacklist {
-		a.logger.Info("IP blocked by blacklist", "ip", req.IP)
-		return domain.AuthResponse{OK: false}, nil
+		a.logger.Info("IP blocked by blacklist", "ip", req.IP, "subnet", match.CIDR,
+			"country", geo.Country, "asn", geo.ASN)
+		return domain.AuthResponse{OK: false, Reason: domain.DenialBlacklist, Match: match.CIDR}, nil
+	}
+
+	// Логин под атакой блокируется с любого адреса, в том числе из подсетей белого списка
+	loginList, loginRule, loginListed := loginStatus(a.loginRules.matches(req.Login))
+	if loginListed && loginList == domain.Blacklist {
+		a.logger.Info("Login blocked by blacklist", "login", req.Login, "ip", req.IP, "rule", loginRule.String(),
+			"country", geo.Country, "asn", geo.ASN)
+		return domain.AuthResponse{OK: false, Reason: domain.DenialLoginBlacklist, Match: loginRule.String()}, nil
+	}
+
+	limits, limited := requestLimits(ipStatus, match, loginListed && loginList == domain.Whitelist)
+	switch {
+	case !limited:
+		a.logger.Info("IP allowed by whitelist", "ip", req.IP, "subnet", match.CIDR,
+			"country", geo.Country, "asn", geo.ASN)
+		return domain.AuthResponse{OK: true, Match: match.CIDR}, nil
+	case ipStatus == domain.IPInWhitelist:
+		// Подсеть белого списка освобождает только от части лимитов: перебор паролей одного логина
+		// из скомпрометированной доверенной сети по-прежнему ограничен
+		a.logger.Debug("IP whitelisted with limited trust", "ip", req.IP, "subnet", match.CIDR,
+			"trust", match.Trust)
+	case limits.Greylisted:
+		a.logger.Debug("IP greylisted, stricter rate limits apply", "ip", req.IP, "subnet", match.CIDR,
+			"country", geo.Country, "asn", geo.ASN)
 	}
 
-	if ipStatus == domain.IPInWhitelist {
-		a.logger.Info("IP allowed by whitelist", "ip", req.IP)
-		return domain.AuthResponse{OK: true}, nil
+	if limits.SkipLogin {
+		a.logger.Debug("Login whitelisted, login bucket skipped", "login", req.Login, "rule", loginRule.String())
 	}
 
-	if err := a.rateLimiter.Check(context.Background(), req.Login, req.Password, req.IP); err != nil {
+	// Подсети списков точнее правил по странам и ASN, поэтому подсеть белого списка от них освобождает
+	if ipStatus != domain.IPInWhitelist {
+		if rule, blocked := blockingRule(a.geoRules.matches(geo)); blocked {
+			a.logger.Info("IP blocked by geo rule", "ip", req.IP, "rule", rule.String(),
+				"country", geo.Country, "asn", geo.ASN, "as_org", geo.ASOrg)
+			return domain.AuthResponse{OK: false, Reason: domain.DenialGeoBlacklist, Match: rule.String()}, nil
+		}
+	}
+
+	ctx := context.Background()
+
+	banLogin, banIP := req.Login, req.IP
+	if limits.SkipLogin {
+		banLogin = ""
+	}
+	if limits.SkipIP {
+		banIP = ""
+	}
+	if err := a.rateLimiter.CheckBan(ctx, banLogin, banIP); err != nil {
+		var banErr *ratelimit.BannedError
+		if !errors.As(err, &banErr) {
+			a.logger.Error("Ban check failed",
+				"login", req.Login,
+				"ip", req.IP,
+				"error", err.Error())
+			return domain.AuthResponse{OK: false}, nil
+		}
+
+		a.logger.Info("Request rejected by ban",
+			"login", req.Login,
+			"ip", req.IP,
+			"country", geo.Country,
+			"asn", geo.ASN,
+			"bucket", banErr.Bucket,
+			"level", banErr.Level,
+			"until", banErr.Until)
+		return banResponse(banErr), nil
+	}
+
+	result, err := a.rateLimiter.CheckWithOptions(ctx, req.Login, req.Password, req.IP, limits)
+	if err != nil {
+		var banErr *ratelimit.BannedError
+		if errors.As(err, &banErr) {
+			a.logger.Warn("Banned after repeated rate limit rejections",
+				"login", req.Login,
+				"ip", req.IP,
+				"country", geo.Country,
+				"asn", geo.ASN,
+				"bucket", banErr.Bucket,
+				"level", banErr.Level,
+				"until", banErr.Until)
+			return banResponse(banErr), nil
+		}
+
+		var limitErr *ratelimit.LimitExceededError
+		if !errors.As(err, &limitErr) {
+			a.logger.Error("Rate limit check failed",
+				"login", req.Login,
+				"ip", req.IP,
+				"error", err.Error())
+			return domain.AuthResponse{OK: false}, nil
+		}
+
 		a.logger.Warn("Rate limit exceeded",
 			"login", req.Login,
 			"ip", req.IP,
-			"error", err.Error())
-		return domain.AuthResponse{OK: false}, nil
+			"country", geo.Country,
+			"asn", geo.ASN,
+			"password_hash", a.rateLimiter.PasswordHash(req.Password),
+			"bucket", limitErr.Bucket,
+			"retry_after", limitErr.RetryAfter)
+
+		remaining := 0
+		return domain.AuthResponse{
+			OK:         false,
+			Reason:     denialReasons[limitErr.Bucket],
+			Match:      string(limitErr.Bucket),
+			Remaining:  &remaining,
+			RetryAfter: int(math.Ceil(limitErr.RetryAfter.Seconds())),
+		}, nil
 	}
 
 	a.logger.Info("Auth request allowed",
 		"login", req.Login,
-		"ip", req.IP)
-	return domain.AuthResponse{OK: true}, nil
+		"ip", req.IP,
+		"country", geo.Country,
+		"asn", geo.ASN)
+	return domain.AuthResponse{OK: true, Match: match.CIDR, Remaining: &result.Remaining}, nil
 }
 
-func (a *App) checkIPInLists(ip string) (domain.IPListStatus, error) {
-	if a.cache.needsReload() {
-		a.logger.Debug("Reloading IP lists cache")
-
-		blacklist, err := a.storage.Subnet().GetByListType(domain.Blacklist)
-		if err != nil {
-			return domain.IPNotInList, err
+// requestLimits возвращает ограничения для запроса с IP в списке ipStatus (match - решившая подсеть)
+// и логином из белого списка, если loginWhitelisted. limited равен false, если лимиты не применяются вовсе.
+func requestLimits(
+	ipStatus domain.IPListStatus, match domain.ListMatch, loginWhitelisted bool,
+) (limits ratelimit.Options, limited bool) {
+	switch ipStatus {
+	case domain.IPInWhitelist:
+		if limits, limited = trustLimits(match.Trust); !limited {
+			return limits, false
 		}
+	case domain.IPInGreylist:
+		limits.Greylisted = true
+	case domain.IPInBlacklist, domain.IPNotInList:
+	}
 
-		whitelist, err := a.storage.Subnet().GetByListType(domain.Whitelist)
-		if err != nil {
-			return domain.IPNotInList, err
+	limits.SkipLogin = loginWhitelisted
+	return limits, true
+}
+
+// trustLimits возвращает ограничения для подсети белого списка с уровнем доверия trust.
+// limited равен false, если подсеть освобождает от всех ограничений.
+func trustLimits(trust domain.TrustLevel) (limits ratelimit.Options, limited bool) {
+	switch trust {
+	case domain.TrustBypassIP:
+		return ratelimit.Options{SkipIP: true}, true
+	case domain.TrustRelaxed:
+		return ratelimit.Options{LimitMultiplier: domain.RelaxedLimitMultiplier}, true
+	case domain.TrustBypassAll:
+	}
+
+	return ratelimit.Options{}, false
+}
+
+var denialReasons = map[ratelimit.Bucket]domain.DenialReason{
+	ratelimit.BucketLogin:    domain.DenialLoginLimit,
+	ratelimit.BucketPassword: domain.DenialPasswordLimit,
+	ratelimit.BucketIP:       domain.DenialIPLimit,
+}
+
+var banReasons = map[ratelimit.Bucket]domain.DenialReason{
+	ratelimit.BucketLogin: domain.DenialLoginBan,
+	ratelimit.BucketIP:    domain.DenialIPBan,
+}
+
+func banResponse(banErr *ratelimit.BannedError) domain.AuthResponse {
+	remaining := 0
+	return domain.AuthResponse{
+		OK:         false,
+		Reason:     banReasons[banErr.Bucket],
+		Match:      string(banErr.Bucket),
+		Remaining:  &remaining,
+		RetryAfter: int(math.Ceil(banErr.RetryAfter.Seconds())),
+	}
+}
+
+func (a *App) checkIPInLists(ip string) (domain.IPListStatus, domain.ListMatch, error) {
+	return a.cache.checkIP(ip)
+}
+
+func (a *App) GetBuckets(req domain.BucketsRequest) (domain.BucketsResponse, error) {
+	if req.Login == "" && req.PasswordHash == "" && req.IP == "" {
+		return domain.BucketsResponse{}, fmt.Errorf("either login, password_hash or ip must be provided")
+	}
+
+	// Бакеты выбираются так же, как в CheckAuth: у IP из серого списка они свои, а уровень доверия
+	// подсети белого списка меняет лимиты или освобождает от части бакетов
+	ipStatus, match := domain.IPNotInList, domain.ListMatch{}
+	if req.IP != "" {
+		var err error
+		if ipStatus, match, err = a.checkIPInLists(req.IP); err != nil {
+			return domain.BucketsResponse{}, err
 		}
+	}
+	loginList, _, loginListed := loginStatus(a.loginRules.matches(req.Login))
+	limits, limited := requestLimits(ipStatus, match, loginListed && loginList == domain.Whitelist)
+	if !limited {
+		// Подсеть белого списка освобождает от всех лимитов и банов
+		return domain.BucketsResponse{Buckets: []domain.BucketState{}, Bans: []domain.Ban{}}, nil
+	}
+
+	states, err := a.rateLimiter.Inspect(context.Background(), req.Login, req.PasswordHash, req.IP, limits)
+	if err != nil {
+		return domain.BucketsResponse{}, err
+	}
+
+	banLogin, banIP := req.Login, req.IP
+	if limits.SkipLogin {
+		banLogin = ""
+	}
+	if limits.SkipIP {
+		banIP = ""
+	}
+	bans, err := a.rateLimiter.Bans(context.Background(), banLogin, banIP)
+	if err != nil {
+		return domain.BucketsResponse{}, err
+	}
 
-		if err := a.cache.reload(blacklist, whitelist); err != nil {
-			return domain.IPNotInList, err
+	response := domain.BucketsResponse{
+		Buckets: make([]domain.BucketState, len(states)),
+		Bans:    make([]domain.Ban, len(bans)),
+	}
+	for i, state := range states {
+		response.Buckets[i] = domain.BucketState{
+			Bucket:    string(state.Bucket),
+			Algorithm: string(state.Algorithm),
+			Available: state.Available,
+			Capacity:  state.Capacity,
+			Window:    int(state.Window.Seconds()),
+			FullIn:    int(math.Ceil(state.FullIn.Seconds())),
 		}
+	}
 
-		a.logger.Info("IP lists cache reloaded",
-			"blacklist_count", len(blacklist),
-			"whitelist_count", len(whitelist))
+	for i, ban := range bans {
+		response.Bans[i] = domain.Ban{
+			Bucket:    string(ban.Bucket),
+			Level:     ban.Level,
+			Until:     ban.Until.UTC(),
+			ExpiresIn: int(time.Until(ban.Until).Round(time.Second).Seconds()),
+		}
 	}
 
-	return a.cache.checkIP(ip)
+	return response, nil
 }
 
 func (a *App) ResetBuckets(req domain.ResetBucketsRequest) (domain.ResetBucketsResponse, error) {
@@ -0,0 +1,1498 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/gomonov/otus-go-project/internal/feed"
+	"github.com/gomonov/otus-go-project/internal/ratelimit"
+	"github.com/gomonov/otus-go-project/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) log(args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprint(args...))
+}
+
+func (l *recordingLogger) Info(args ...interface{})  { l.log(args...) }
+func (l *recordingLogger) Error(args ...interface{}) { l.log(args...) }
+func (l *recordingLogger) Debug(args ...interface{}) { l.log(args...) }
+func (l *recordingLogger) Warn(args ...interface{})  { l.log(args...) }
+
+type memoryStorage struct {
+	subnets    *memorySubnetRepository
+	geoRules   *memoryGeoRuleRepository
+	loginRules *memoryLoginRuleRepository
+	feeds      *memoryFeedRepository
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		subnets:    &memorySubnetRepository{},
+		geoRules:   &memoryGeoRuleRepository{},
+		loginRules: &memoryLoginRuleRepository{},
+		feeds:      newMemoryFeedRepository(),
+	}
+}
+
+func (s *memoryStorage) Subnet() storage.SubnetRepository       { return s.subnets }
+func (s *memoryStorage) GeoRule() storage.GeoRuleRepository     { return s.geoRules }
+func (s *memoryStorage) LoginRule() storage.LoginRuleRepository { return s.loginRules }
+func (s *memoryStorage) Feed() storage.FeedRepository           { return s.feeds }
+func (s *memoryStorage) Close() error                           { return nil }
+
+type memorySubnetRepository struct {
+	mu      sync.Mutex
+	subnets []domain.Subnet
+}
+
+func (r *memorySubnetRepository) Create(subnet *domain.Subnet) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	subnet.CreatedAt, subnet.UpdatedAt = now, now
+	for i, existing := range r.subnets {
+		if existing.ListType == subnet.ListType && existing.CIDR == subnet.CIDR {
+			subnet.CreatedBy, subnet.CreatedAt = existing.CreatedBy, existing.CreatedAt
+			r.subnets[i] = *subnet
+			return nil
+		}
+	}
+	r.subnets = append(r.subnets, *subnet)
+	return nil
+}
+
+func (r *memorySubnetRepository) CreateAll(subnets []*domain.Subnet) error {
+	for _, subnet := range subnets {
+		if err := r.Create(subnet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memorySubnetRepository) Delete(listType domain.ListType, cidr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, subnet := range r.subnets {
+		if subnet.ListType == listType && subnet.CIDR == cidr {
+			r.subnets = append(r.subnets[:i], r.subnets[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrSubnetNotFound
+}
+
+func (r *memorySubnetRepository) DeleteAll(listType domain.ListType, cidrs []string) ([]domain.Subnet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted []domain.Subnet
+	kept := r.subnets[:0]
+	for _, subnet := range r.subnets {
+		if subnet.ListType == listType && slices.Contains(cidrs, subnet.CIDR) {
+			deleted = append(deleted, subnet)
+			continue
+		}
+		kept = append(kept, subnet)
+	}
+	r.subnets = kept
+	if len(deleted) == 0 {
+		return nil, domain.ErrSubnetNotFound
+	}
+	return deleted, nil
+}
+
+func (r *memorySubnetRepository) GetByListType(
+	listType domain.ListType, filter domain.SubnetFilter,
+) ([]domain.Subnet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var subnets []domain.Subnet
+	for _, subnet := range r.subnets {
+		if subnet.ListType == listType && !subnet.Expired(time.Now()) && filter.Matches(subnet) {
+			subnets = append(subnets, subnet)
+		}
+	}
+
+	// Как ORDER BY cidr в базе: по адресу, затем по длине префикса
+	compare := func(a, b string) int {
+		prefixA, prefixB := netip.MustParsePrefix(a), netip.MustParsePrefix(b)
+		if c := prefixA.Addr().Compare(prefixB.Addr()); c != 0 {
+			return c
+		}
+		return prefixA.Bits() - prefixB.Bits()
+	}
+	slices.SortFunc(subnets, func(a, b domain.Subnet) int { return compare(a.CIDR, b.CIDR) })
+	if filter.After != "" {
+		subnets = slices.DeleteFunc(subnets, func(subnet domain.Subnet) bool {
+			return compare(subnet.CIDR, filter.After) <= 0
+		})
+	}
+	if filter.Limit > 0 && len(subnets) > filter.Limit {
+		subnets = subnets[:filter.Limit]
+	}
+	return subnets, nil
+}
+
+func (r *memorySubnetRepository) FindOverlapping(listType domain.ListType, cidr string) ([]domain.Subnet, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var subnets []domain.Subnet
+	for _, subnet := range r.subnets {
+		other := netip.MustParsePrefix(subnet.CIDR)
+		if subnet.Expired(time.Now()) || !other.Overlaps(prefix) || (subnet.ListType == listType && other == prefix) {
+			continue
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+func (r *memorySubnetRepository) FindOverlaps() ([]domain.SubnetOverlap, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var overlaps []domain.SubnetOverlap
+	for _, subnet := range r.subnets {
+		for _, other := range r.subnets {
+			prefix, otherPrefix := netip.MustParsePrefix(subnet.CIDR), netip.MustParsePrefix(other.CIDR)
+			first := subnet.ListType < other.ListType ||
+				(subnet.ListType == other.ListType && prefix.Bits() > otherPrefix.Bits())
+			if first && prefix.Overlaps(otherPrefix) && !subnet.Expired(time.Now()) && !other.Expired(time.Now()) {
+				overlaps = append(overlaps, domain.SubnetOverlap{Subnet: subnet, Other: other})
+			}
+		}
+	}
+	return overlaps, nil
+}
+
+func (r *memorySubnetRepository) DeleteExpired(now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	subnets := r.subnets[:0]
+	for _, subnet := range r.subnets {
+		if subnet.Expired(now) {
+			deleted++
+			continue
+		}
+		subnets = append(subnets, subnet)
+	}
+	r.subnets = subnets
+	return deleted, nil
+}
+
+func (r *memorySubnetRepository) Import(
+	listType domain.ListType, source string, subnets []domain.Subnet, replace bool,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	imported := make(map[string]bool, len(subnets))
+	for _, subnet := range subnets {
+		subnet.ListType, subnet.Source = listType, source
+		imported[subnet.CIDR] = true
+		i := slices.IndexFunc(r.subnets, func(existing domain.Subnet) bool {
+			return existing.ListType == listType && existing.CIDR == subnet.CIDR
+		})
+		switch {
+		case i < 0:
+			subnet.CreatedAt, subnet.UpdatedAt = time.Now(), time.Now()
+			r.subnets = append(r.subnets, subnet)
+		case r.subnets[i].Source == source || source == "":
+			subnet.CreatedBy, subnet.CreatedAt, subnet.UpdatedAt = r.subnets[i].CreatedBy, r.subnets[i].CreatedAt, time.Now()
+			r.subnets[i] = subnet
+		}
+	}
+
+	kept := r.subnets[:0]
+	for _, subnet := range r.subnets {
+		if replace && subnet.ListType == listType && subnet.Source == source && !imported[subnet.CIDR] {
+			continue
+		}
+		kept = append(kept, subnet)
+	}
+	r.subnets = kept
+	return nil
+}
+
+type memoryGeoRuleRepository struct {
+	mu    sync.Mutex
+	rules []domain.GeoRule
+}
+
+func (r *memoryGeoRuleRepository) Create(rule *domain.GeoRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule.CreatedAt = time.Now()
+	r.rules = append(r.rules, *rule)
+	return nil
+}
+
+func (r *memoryGeoRuleRepository) Delete(listType domain.ListType, kind domain.GeoRuleKind, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rule := range r.rules {
+		if rule.ListType == listType && rule.Kind == kind && rule.Value == value {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrGeoRuleNotFound
+}
+
+func (r *memoryGeoRuleRepository) GetAll() ([]domain.GeoRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.rules), nil
+}
+
+type memoryLoginRuleRepository struct {
+	mu    sync.Mutex
+	rules []domain.LoginRule
+}
+
+func (r *memoryLoginRuleRepository) Create(rule *domain.LoginRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rule.CreatedAt = time.Now()
+	r.rules = append(r.rules, *rule)
+	return nil
+}
+
+func (r *memoryLoginRuleRepository) Delete(
+	listType domain.ListType, kind domain.LoginRuleKind, pattern string,
+) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rule := range r.rules {
+		if rule.ListType == listType && rule.Kind == kind && rule.Pattern == pattern {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrLoginRuleNotFound
+}
+
+func (r *memoryLoginRuleRepository) GetAll() ([]domain.LoginRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.rules), nil
+}
+
+type memoryFeedRepository struct {
+	mu       sync.Mutex
+	statuses map[string]domain.FeedStatus
+	locked   map[string]bool
+}
+
+func newMemoryFeedRepository() *memoryFeedRepository {
+	return &memoryFeedRepository{statuses: make(map[string]domain.FeedStatus), locked: make(map[string]bool)}
+}
+
+func (r *memoryFeedRepository) Lock(_ context.Context, name string) (func(), bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.locked[name] {
+		return nil, false, nil
+	}
+	r.locked[name] = true
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.locked, name)
+	}, true, nil
+}
+
+func (r *memoryFeedRepository) GetStatuses() ([]domain.FeedStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]domain.FeedStatus, 0, len(r.statuses))
+	for _, status := range r.statuses {
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (r *memoryFeedRepository) SaveStatus(status domain.FeedStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[status.Name] = status
+	return nil
+}
+
+type memoryGeoResolver map[string]domain.GeoInfo
+
+func (r memoryGeoResolver) Lookup(addr netip.Addr) (domain.GeoInfo, error) {
+	return r[addr.String()], nil
+}
+
+func createSubnet(t *testing.T, application *App, subnet *domain.Subnet) {
+	t.Helper()
+	_, err := application.CreateSubnet(subnet, true)
+	require.NoError(t, err)
+}
+
+func newTestApp(t *testing.T, config ratelimit.Config) (*App, *recordingLogger) {
+	t.Helper()
+
+	rateLimiter, err := ratelimit.NewMemoryRateLimiter(ratelimit.NewMemoryBackend(ratelimit.MemoryConfig{}), config)
+	require.NoError(t, err)
+
+	logger := &recordingLogger{}
+	application := New(logger, newMemoryStorage(), CacheConf{TTL: time.Minute}, rateLimiter, nil)
+	require.NoError(t, application.LoadIPLists())
+
+	return application, logger
+}
+
+func TestCheckAuth_PasswordNeverLogged(t *testing.T) {
+	const password = "Tr0ub4dor&3-very-secret"
+
+	application, logger := newTestApp(t, ratelimit.Config{
+		LoginLimit:    2,
+		PasswordLimit: 2,
+		IPLimit:       10,
+		Window:        60,
+		KeySecrets:    []string{"secret"},
+	})
+
+	denied := false
+	for i := 0; i < 4; i++ {
+		response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: password, IP: "10.0.0.1"})
+		require.NoError(t, err)
+		denied = denied || !response.OK
+	}
+	_, err := application.ResetBuckets(domain.ResetBucketsRequest{Login: "user", IP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	require.True(t, denied)
+	require.NotEmpty(t, logger.lines)
+	for _, line := range logger.lines {
+		assert.False(t, strings.Contains(line, password), "password found in log line: %s", line)
+	}
+}
+
+func TestCheckAuth_DenialReasons(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{
+		LoginLimit:    1,
+		PasswordLimit: 10,
+		IPLimit:       10,
+		Window:        60,
+	})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "192.168.0.0/16"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "192.168.1.0/24"})
+
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.168.1.10"})
+	require.NoError(t, err)
+	assert.False(t, response.OK)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+	assert.Equal(t, "192.168.1.0/24", response.Match)
+	assert.Zero(t, response.RetryAfter)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+	require.NotNil(t, response.Remaining)
+	assert.Equal(t, 0, *response.Remaining)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.False(t, response.OK)
+	assert.Equal(t, domain.DenialLoginLimit, response.Reason)
+	assert.Equal(t, "login", response.Match)
+	assert.InDelta(t, 60, response.RetryAfter, 1)
+}
+
+func TestCheckAuth_BanCheckedBeforeBuckets(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{
+		LoginLimit:    1,
+		PasswordLimit: 100,
+		IPLimit:       100,
+		Window:        3600,
+		Bans: ratelimit.BanConfig{
+			Threshold: 2,
+			Period:    time.Hour,
+			Durations: []time.Duration{time.Hour},
+		},
+	})
+
+	reasons := make([]domain.DenialReason, 0, 4)
+	for i := 0; i < 4; i++ {
+		response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+		require.NoError(t, err)
+		reasons = append(reasons, response.Reason)
+	}
+	expected := []domain.DenialReason{"", domain.DenialLoginLimit, domain.DenialLoginBan, domain.DenialLoginBan}
+	assert.Equal(t, expected, reasons)
+
+	// Забанен логин, а не IP
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "other", Password: "pass", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	buckets, err := application.GetBuckets(domain.BucketsRequest{Login: "user"})
+	require.NoError(t, err)
+	require.Len(t, buckets.Bans, 1)
+	assert.Equal(t, "login", buckets.Bans[0].Bucket)
+	assert.InDelta(t, 3600, buckets.Bans[0].ExpiresIn, 1)
+
+	_, err = application.ResetBuckets(domain.ResetBucketsRequest{Login: "user", IP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+}
+
+func TestIPListsCache_IgnoresExpiredEntries(t *testing.T) {
+	expired := time.Now().Add(-time.Second)
+	active := time.Now().Add(time.Hour)
+
+	cache := newIPListsCache(time.Minute, "", "")
+	require.NoError(t, cache.reload([]domain.Subnet{
+		{ListType: domain.Blacklist, CIDR: "10.0.0.0/8", ExpiresAt: &active},
+		{ListType: domain.Blacklist, CIDR: "10.1.0.0/16", ExpiresAt: &expired},
+		{ListType: domain.Blacklist, CIDR: "192.168.0.0/16", ExpiresAt: &expired},
+	}, nil, nil, 0))
+
+	// Истёкшая подсеть не учитывается, и срабатывает более общая действующая
+	status, match, err := cache.checkIP("10.1.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.IPInBlacklist, status)
+	assert.Equal(t, "10.0.0.0/8", match.CIDR)
+
+	status, _, err = cache.checkIP("192.168.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.IPNotInList, status)
+}
+
+func TestSweepExpiredSubnets(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	expired := time.Now().Add(-time.Second)
+
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"})
+	createSubnet(t, application, &domain.Subnet{
+		ListType:  domain.Blacklist,
+		CIDR:      "192.168.0.0/16",
+		ExpiresAt: &expired,
+	})
+
+	application.sweepExpiredSubnets()
+
+	repository := application.storage.Subnet().(*memorySubnetRepository)
+	require.Len(t, repository.subnets, 1)
+	assert.Equal(t, "10.0.0.0/8", repository.subnets[0].CIDR)
+}
+
+func TestCheckAuth_IPv6(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{
+		LoginLimit:    10,
+		PasswordLimit: 10,
+		IPLimit:       1,
+		Window:        60,
+		IPv6Prefix:    64,
+	})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "2001:db8:bad::/48"})
+
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "2001:db8:bad:1::1"})
+	require.NoError(t, err)
+	assert.False(t, response.OK)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+	assert.Equal(t, "2001:db8:bad::/48", response.Match)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "2001:db8:1:1::1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	// Другой адрес из той же /64 расходует тот же бакет IP
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "other", Password: "pass2", IP: "2001:db8:1:1::2"})
+	require.NoError(t, err)
+	assert.False(t, response.OK)
+	assert.Equal(t, domain.DenialIPLimit, response.Reason)
+}
+
+type channelListener struct {
+	changes chan domain.SubnetChange
+}
+
+func (l *channelListener) Changes() <-chan domain.SubnetChange { return l.changes }
+func (l *channelListener) Close() error                        { return nil }
+
+func TestRunListener_AppliesChangesWithoutReload(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	request := domain.AuthRequest{Login: "user", Password: "pass", IP: "10.1.2.3"}
+
+	response, err := application.CheckAuth(request)
+	require.NoError(t, err)
+	require.True(t, response.OK)
+
+	// Подсеть добавлена другим экземпляром: в этот экземпляр она приходит только уведомлением
+	subnet := domain.Subnet{ListType: domain.Blacklist, CIDR: "10.1.0.0/16"}
+	require.NoError(t, application.storage.Subnet().Create(&subnet))
+
+	listener := &channelListener{changes: make(chan domain.SubnetChange, 2)}
+	listener.changes <- domain.SubnetChange{Op: domain.SubnetUpserted, Subnet: subnet}
+	close(listener.changes)
+	application.RunListener(context.Background(), listener)
+
+	response, err = application.CheckAuth(request)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+
+	application.applySubnetChange(domain.SubnetChange{Op: domain.SubnetDeleted, Subnet: subnet})
+
+	response, err = application.CheckAuth(request)
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+}
+
+func TestRunListener_ReloadsRules(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	geo := domain.GeoInfo{Country: "RU"}
+
+	// Правило добавлено другим экземпляром: в этот экземпляр оно приходит только уведомлением
+	rule := domain.GeoRule{ListType: domain.Blacklist, Kind: domain.GeoRuleCountry, Value: "RU"}
+	require.NoError(t, application.storage.GeoRule().Create(&rule))
+	assert.Empty(t, application.geoRules.matches(geo))
+
+	loginRule := domain.LoginRule{ListType: domain.Blacklist, Kind: domain.LoginRuleExact, Pattern: "admin"}
+	require.NoError(t, application.storage.LoginRule().Create(&loginRule))
+	assert.Empty(t, application.loginRules.matches("admin"))
+
+	listener := &channelListener{changes: make(chan domain.SubnetChange, 2)}
+	listener.changes <- domain.SubnetChange{Op: domain.GeoRulesChanged}
+	listener.changes <- domain.SubnetChange{Op: domain.LoginRulesChanged}
+	close(listener.changes)
+	application.RunListener(context.Background(), listener)
+
+	assert.Len(t, application.geoRules.matches(geo), 1)
+	assert.Len(t, application.loginRules.matches("admin"), 1)
+}
+
+func TestIPListsCache_ReappliesChangesReceivedDuringReload(t *testing.T) {
+	cache := newIPListsCache(time.Minute, "", "")
+	subnet := domain.Subnet{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"}
+
+	// Списки прочитаны из базы до добавления подсети, а уведомление о ней пришло до замены списков
+	version := cache.currentVersion()
+	require.NoError(t, cache.apply(domain.SubnetChange{Op: domain.SubnetUpserted, Subnet: subnet}))
+	require.NoError(t, cache.reload(nil, nil, nil, version))
+
+	status, _, err := cache.checkIP("10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.IPInBlacklist, status)
+	assert.Empty(t, cache.refresh)
+
+	cache.invalidate()
+	assert.Len(t, cache.refresh, 1)
+}
+
+type failingSubnetRepository struct {
+	memorySubnetRepository
+	err error
+}
+
+func (r *failingSubnetRepository) GetByListType(
+	listType domain.ListType, filter domain.SubnetFilter,
+) ([]domain.Subnet, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.memorySubnetRepository.GetByListType(listType, filter)
+}
+
+type failingStorage struct {
+	subnets *failingSubnetRepository
+}
+
+func (s *failingStorage) Subnet() storage.SubnetRepository   { return s.subnets }
+func (s *failingStorage) GeoRule() storage.GeoRuleRepository { return &memoryGeoRuleRepository{} }
+func (s *failingStorage) LoginRule() storage.LoginRuleRepository {
+	return &memoryLoginRuleRepository{}
+}
+func (s *failingStorage) Feed() storage.FeedRepository { return newMemoryFeedRepository() }
+func (s *failingStorage) Close() error                 { return nil }
+
+func TestCheckAuth_ServesLastListsWhileDatabaseIsDown(t *testing.T) {
+	rateLimiter, err := ratelimit.NewMemoryRateLimiter(ratelimit.NewMemoryBackend(ratelimit.MemoryConfig{}),
+		ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	require.NoError(t, err)
+
+	subnets := &failingSubnetRepository{}
+	require.NoError(t, subnets.Create(&domain.Subnet{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"}))
+
+	application := New(&recordingLogger{}, &failingStorage{subnets: subnets},
+		CacheConf{TTL: time.Minute, MaxStaleness: time.Hour}, rateLimiter, nil)
+	request := domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"}
+
+	_, err = application.CheckAuth(request)
+	require.ErrorIs(t, err, domain.ErrIPListsUnavailable)
+
+	require.NoError(t, application.LoadIPLists())
+
+	subnets.err = errors.New("connection refused")
+	require.Error(t, application.LoadIPLists())
+
+	response, err := application.CheckAuth(request)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+
+	// Копия старше MaxStaleness больше не используется
+	application.cache.mu.Lock()
+	application.cache.lastLoaded = time.Now().Add(-2 * time.Hour)
+	application.cache.mu.Unlock()
+
+	_, err = application.CheckAuth(request)
+	require.ErrorIs(t, err, domain.ErrIPListsUnavailable)
+	assert.Greater(t, application.CacheAge(), time.Hour)
+}
+
+func TestRunCacheRefresher_ReloadsOnRequest(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	request := domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		application.RunCacheRefresher(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Подсеть добавлена в базу в обход этого экземпляра, например, во время разрыва соединения слушателя
+	require.NoError(t, application.storage.Subnet().Create(&domain.Subnet{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"}))
+	application.applySubnetChange(domain.SubnetChange{Op: domain.SubnetResync})
+
+	require.Eventually(t, func() bool {
+		response, err := application.CheckAuth(request)
+		return err == nil && response.Reason == domain.DenialBlacklist
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLoadSnapshot_ServesListsWithoutDatabase(t *testing.T) {
+	rateLimiter, err := ratelimit.NewMemoryRateLimiter(ratelimit.NewMemoryBackend(ratelimit.MemoryConfig{}),
+		ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	require.NoError(t, err)
+
+	cacheConf := CacheConf{TTL: time.Minute, SnapshotPath: filepath.Join(t.TempDir(), "snapshot", "ip_lists.json")}
+
+	// Первый запуск: списки загружаются из базы и сохраняются в снимок
+	subnets := &failingSubnetRepository{}
+	require.NoError(t, subnets.Create(&domain.Subnet{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"}))
+	require.NoError(t, subnets.Create(&domain.Subnet{ListType: domain.Whitelist, CIDR: "192.168.0.0/16"}))
+	previous := New(&recordingLogger{}, &failingStorage{subnets: subnets}, cacheConf, rateLimiter, nil)
+	require.NoError(t, previous.LoadIPLists())
+
+	// Второй запуск: базы нет, списки берутся из снимка
+	subnets.err = errors.New("connection refused")
+	application := New(&recordingLogger{}, &failingStorage{subnets: subnets}, cacheConf, rateLimiter, nil)
+	require.NoError(t, application.LoadSnapshot())
+	require.Error(t, application.LoadIPLists())
+
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.168.0.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	// База снова доступна: снимок заменяется актуальными списками
+	subnets.err = nil
+	require.NoError(t, subnets.Delete(domain.Blacklist, "10.0.0.0/8"))
+	require.NoError(t, application.LoadIPLists())
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+}
+
+func TestLoadSnapshot_RejectsCorruptedSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip_lists.json")
+	cache := newIPListsCache(time.Minute, path, "")
+	require.NoError(t, cache.saveSnapshot([]domain.Subnet{{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"}}, nil, nil))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	tampered := strings.Replace(string(data), "10.0.0.0/8", "10.0.0.0/9", 1)
+	require.NoError(t, os.WriteFile(path, []byte(tampered), 0o600))
+
+	_, err = newIPListsCache(time.Minute, path, "").loadSnapshot()
+	require.ErrorIs(t, err, errInvalidSnapshot)
+
+	unsupported := strings.Replace(string(data), `"version":1`, `"version":2`, 1)
+	require.NoError(t, os.WriteFile(path, []byte(unsupported), 0o600))
+
+	_, err = newIPListsCache(time.Minute, path, "").loadSnapshot()
+	require.ErrorIs(t, err, errInvalidSnapshot)
+
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	cache = newIPListsCache(time.Minute, path, "")
+	_, err = cache.loadSnapshot()
+	require.NoError(t, err)
+
+	status, match, err := cache.checkIP("10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, domain.IPInBlacklist, status)
+	assert.Equal(t, "10.0.0.0/8", match.CIDR)
+}
+
+func TestGetSubnetsByListType_FiltersByTag(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+
+	tagged := &domain.Subnet{
+		ListType:  domain.Blacklist,
+		CIDR:      "198.51.100.0/24",
+		Comment:   "credential stuffing",
+		CreatedBy: "admin",
+		Tags:      []string{"abuse", "botnet"},
+	}
+	createSubnet(t, application, tagged)
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "203.0.113.0/24"})
+	assert.False(t, tagged.CreatedAt.IsZero())
+
+	page, err := application.GetSubnetsByListType(domain.Blacklist, domain.SubnetFilter{Tag: "botnet"})
+	require.NoError(t, err)
+	require.Len(t, page.Subnets, 1)
+	assert.Equal(t, "198.51.100.0/24", page.Subnets[0].CIDR)
+	assert.Equal(t, "credential stuffing", page.Subnets[0].Comment)
+	assert.Equal(t, "admin", page.Subnets[0].CreatedBy)
+
+	page, err = application.GetSubnetsByListType(domain.Blacklist, domain.SubnetFilter{})
+	require.NoError(t, err)
+	assert.Len(t, page.Subnets, 2)
+}
+
+func TestCreateSubnet_DetectsOverlaps(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "10.20.0.0/16"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "192.168.0.0/16"})
+
+	// Чёрный список внутри белого молча отменил бы его
+	_, err := application.CreateSubnet(&domain.Subnet{ListType: domain.Blacklist, CIDR: "10.20.30.0/24"}, false)
+	var overlapErr *domain.SubnetOverlapError
+	require.ErrorAs(t, err, &overlapErr)
+	require.ErrorIs(t, err, domain.ErrSubnetOverlap)
+	require.Len(t, overlapErr.Overlaps, 1)
+	assert.Equal(t, domain.OverlapConflict, overlapErr.Overlaps[0].Kind)
+	assert.Equal(t, "10.20.0.0/16", overlapErr.Overlaps[0].Other.CIDR)
+
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.20.30.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	overlaps, err := application.CreateSubnet(&domain.Subnet{ListType: domain.Blacklist, CIDR: "192.168.1.0/24"}, true)
+	require.NoError(t, err)
+	require.Len(t, overlaps, 1)
+	assert.Equal(t, domain.OverlapRedundant, overlaps[0].Kind)
+
+	overlaps, err = application.CreateSubnet(&domain.Subnet{ListType: domain.Blacklist, CIDR: "192.0.0.0/8"}, true)
+	require.NoError(t, err)
+	require.Len(t, overlaps, 2)
+	assert.Equal(t, domain.OverlapCovers, overlaps[0].Kind)
+
+	// Повторное добавление той же подсети - обновление, а не пересечение
+	overlaps, err = application.CreateSubnet(&domain.Subnet{ListType: domain.Whitelist, CIDR: "10.20.0.0/16"}, false)
+	require.NoError(t, err)
+	assert.Empty(t, overlaps)
+}
+
+func TestGetConflicts(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "10.20.0.0/16"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "10.20.30.0/24"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "10.20.30.128/25"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "172.16.0.0/12"})
+
+	conflicts, err := application.GetConflicts()
+	require.NoError(t, err)
+	require.Len(t, conflicts, 3)
+
+	kinds := map[domain.OverlapKind]int{}
+	for _, conflict := range conflicts {
+		kinds[conflict.Kind]++
+		if conflict.Kind == domain.OverlapRedundant {
+			assert.Equal(t, "10.20.30.128/25", conflict.Subnet.CIDR)
+			assert.Equal(t, "10.20.30.0/24", conflict.Other.CIDR)
+		}
+	}
+	assert.Equal(t, map[domain.OverlapKind]int{domain.OverlapConflict: 2, domain.OverlapRedundant: 1}, kinds)
+}
+
+func TestExplain(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "10.0.0.0/8"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "10.20.30.0/24"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "10.20.0.0/16"})
+
+	_, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.168.0.1"})
+	require.NoError(t, err)
+
+	response, err := application.Explain(domain.ExplainRequest{IP: "10.20.30.40", Login: "user"})
+	require.NoError(t, err)
+
+	// Чёрный список побеждает, хотя подсеть белого списка специфичнее
+	assert.Equal(t, domain.IPInBlacklist, response.ListStatus)
+	assert.Equal(t, []domain.ListMatch{
+		{ListType: domain.Whitelist, CIDR: "10.20.30.0/24"},
+		{ListType: domain.Blacklist, CIDR: "10.20.0.0/16", Winner: true},
+		{ListType: domain.Whitelist, CIDR: "10.0.0.0/8"},
+	}, response.Matches)
+
+	require.Len(t, response.Buckets, 2)
+	assert.Equal(t, "login", response.Buckets[0].Bucket)
+	assert.InDelta(t, 9, response.Buckets[0].Available, 0.01)
+	assert.Equal(t, "ip", response.Buckets[1].Bucket)
+	assert.InDelta(t, 10, response.Buckets[1].Available, 0.01)
+
+	// Объяснение ничего не списывает
+	again, err := application.Explain(domain.ExplainRequest{IP: "10.20.30.40", Login: "user"})
+	require.NoError(t, err)
+	assert.Equal(t, response.Buckets, again.Buckets)
+
+	response, err = application.Explain(domain.ExplainRequest{IP: "172.16.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.IPNotInList, response.ListStatus)
+	assert.Empty(t, response.Matches)
+}
+
+func TestExplain_GreylistBuckets(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{
+		LoginLimit:         10,
+		PasswordLimit:      10,
+		IPLimit:            10,
+		Window:             60,
+		GreylistLoginLimit: 2,
+		GreylistIPLimit:    3,
+	})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Greylist, CIDR: "192.0.2.0/24"})
+
+	for i := 0; i < 3; i++ {
+		_, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.0.2.1"})
+		require.NoError(t, err)
+	}
+
+	// Отказ показывают бакеты серого списка, а не обычные
+	response, err := application.Explain(domain.ExplainRequest{IP: "192.0.2.1", Login: "user"})
+	require.NoError(t, err)
+	require.Len(t, response.Buckets, 2)
+	assert.Equal(t, 2, response.Buckets[0].Capacity)
+	assert.InDelta(t, 0, response.Buckets[0].Available, 0.01)
+	assert.Equal(t, 3, response.Buckets[1].Capacity)
+	assert.InDelta(t, 1, response.Buckets[1].Available, 0.01)
+}
+
+func TestExplain_TrustLevelBuckets(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	createSubnet(t, application, &domain.Subnet{
+		ListType: domain.Whitelist, CIDR: "10.1.0.0/16", Trust: domain.TrustBypassAll,
+	})
+	createSubnet(t, application, &domain.Subnet{
+		ListType: domain.Whitelist, CIDR: "10.2.0.0/16", Trust: domain.TrustBypassIP,
+	})
+	createSubnet(t, application, &domain.Subnet{
+		ListType: domain.Whitelist, CIDR: "10.3.0.0/16", Trust: domain.TrustRelaxed,
+	})
+
+	response, err := application.Explain(domain.ExplainRequest{IP: "10.3.0.1", Login: "user"})
+	require.NoError(t, err)
+	require.Len(t, response.Buckets, 2)
+	assert.Equal(t, 10*domain.RelaxedLimitMultiplier, response.Buckets[0].Capacity)
+	assert.Equal(t, 10*domain.RelaxedLimitMultiplier, response.Buckets[1].Capacity)
+
+	// Бакет IP подсети с bypass_ip не проверяется и не показывается
+	response, err = application.Explain(domain.ExplainRequest{IP: "10.2.0.1", Login: "user"})
+	require.NoError(t, err)
+	require.Len(t, response.Buckets, 1)
+	assert.Equal(t, "login", response.Buckets[0].Bucket)
+
+	response, err = application.Explain(domain.ExplainRequest{IP: "10.1.0.1", Login: "user"})
+	require.NoError(t, err)
+	assert.Empty(t, response.Buckets)
+}
+
+func TestImportSubnets(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "172.16.0.0/12", Comment: "old"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "192.168.0.0/16"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "203.0.113.0/24"})
+
+	imported := []domain.Subnet{
+		{CIDR: "10.0.0.0/8"},
+		{CIDR: "172.16.0.0/12", Comment: "new"},
+		{CIDR: "198.51.100.7"},
+		{CIDR: "198.51.100.7/32", Comment: "last wins"},
+	}
+
+	result, err := application.ImportSubnets(domain.Blacklist, imported,
+		domain.ImportOptions{Mode: domain.ImportReplace, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"198.51.100.7/32"}, result.Added)
+	assert.Equal(t, []string{"172.16.0.0/12"}, result.Updated)
+	assert.Equal(t, []string{"192.168.0.0/16"}, result.Removed)
+	assert.Equal(t, 1, result.Unchanged)
+
+	// Пробный импорт ничего не меняет
+	subnets, err := application.ExportSubnets(domain.Blacklist)
+	require.NoError(t, err)
+	require.Len(t, subnets, 3)
+
+	result, err = application.ImportSubnets(domain.Blacklist, imported, domain.ImportOptions{Mode: domain.ImportMerge})
+	require.NoError(t, err)
+	assert.Empty(t, result.Removed)
+
+	subnets, err = application.ExportSubnets(domain.Blacklist)
+	require.NoError(t, err)
+	require.Len(t, subnets, 4)
+
+	_, err = application.ImportSubnets(domain.Blacklist, imported, domain.ImportOptions{Mode: domain.ImportReplace})
+	require.NoError(t, err)
+
+	subnets, err = application.ExportSubnets(domain.Blacklist)
+	require.NoError(t, err)
+	comments := map[string]string{}
+	for _, subnet := range subnets {
+		comments[subnet.CIDR] = subnet.Comment
+	}
+	assert.Equal(t, map[string]string{
+		"10.0.0.0/8":      "",
+		"172.16.0.0/12":   "new",
+		"198.51.100.7/32": "last wins",
+	}, comments)
+
+	// Белый список не затронут, а кэш перечитан после импорта
+	whitelist, err := application.ExportSubnets(domain.Whitelist)
+	require.NoError(t, err)
+	require.Len(t, whitelist, 1)
+
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "198.51.100.7"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.168.1.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+}
+
+func TestSyncFeed(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "198.51.100.0/24", Comment: "manual"})
+
+	var mu sync.Mutex
+	body := "1.10.16.0/20 ; SBL256894\n2.56.192.0/22 ; SBL459831\n198.51.100.0/24 ; SBL1\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	drop := feed.Feed{Name: "spamhaus-drop", URL: server.URL, Format: feed.FormatSpamhaus, Interval: time.Hour}
+	application.feeds = []feed.Feed{drop}
+
+	application.syncFeed(context.Background(), server.Client(), drop)
+
+	statuses, err := application.FeedStatuses()
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Empty(t, statuses[0].Error)
+	assert.Equal(t, 3, statuses[0].Entries)
+	assert.Equal(t, 2, statuses[0].Added)
+
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "2.56.192.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+
+	// Диапазон пропал из фида и удаляется из списка, а подсеть, добавленная вручную, остаётся
+	mu.Lock()
+	body = "1.10.16.0/20 ; SBL256894\n"
+	mu.Unlock()
+	application.syncFeed(context.Background(), server.Client(), drop)
+
+	statuses, err = application.FeedStatuses()
+	require.NoError(t, err)
+	assert.Equal(t, 1, statuses[0].Removed)
+
+	subnets, err := application.ExportSubnets(domain.Blacklist)
+	require.NoError(t, err)
+	sources := map[string]string{}
+	for _, subnet := range subnets {
+		sources[subnet.CIDR] = subnet.Source
+	}
+	assert.Equal(t, map[string]string{"1.10.16.0/20": "feed:spamhaus-drop", "198.51.100.0/24": ""}, sources)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "2.56.192.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	// Пустой ответ не удаляет подсети фида
+	mu.Lock()
+	body = ""
+	mu.Unlock()
+	application.syncFeed(context.Background(), server.Client(), drop)
+
+	statuses, err = application.FeedStatuses()
+	require.NoError(t, err)
+	assert.Equal(t, feed.ErrEmptyFeed.Error(), statuses[0].Error)
+	assert.NotNil(t, statuses[0].LastSync)
+	subnets, err = application.ExportSubnets(domain.Blacklist)
+	require.NoError(t, err)
+	assert.Len(t, subnets, 2)
+}
+
+func TestSyncFeedIfDue_OneInstancePerInterval(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte("1.10.16.0/20 ; SBL256894\n"))
+	}))
+	defer server.Close()
+
+	rateLimiter, err := ratelimit.NewMemoryRateLimiter(ratelimit.NewMemoryBackend(ratelimit.MemoryConfig{}),
+		ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	require.NoError(t, err)
+	store := newMemoryStorage()
+	first := New(&recordingLogger{}, store, CacheConf{TTL: time.Minute}, rateLimiter, nil)
+	second := New(&recordingLogger{}, store, CacheConf{TTL: time.Minute}, rateLimiter, nil)
+
+	drop := feed.Feed{Name: "spamhaus-drop", URL: server.URL, Format: feed.FormatSpamhaus, Interval: time.Hour}
+	first.feeds = []feed.Feed{drop}
+	second.feeds = []feed.Feed{drop}
+
+	first.syncFeedIfDue(context.Background(), server.Client(), drop)
+	// Второй экземпляр видит в базе недавнюю синхронизацию и не скачивает фид повторно
+	second.syncFeedIfDue(context.Background(), server.Client(), drop)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// Пока фид синхронизирует другой экземпляр, очередь пропускается
+	unlock, acquired, err := store.Feed().Lock(context.Background(), drop.Name)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	status, err := second.storedFeedStatus(drop.Name)
+	require.NoError(t, err)
+	stale := time.Now().Add(-2 * time.Hour)
+	status.LastAttempt = &stale
+	require.NoError(t, store.Feed().SaveStatus(status))
+	second.syncFeedIfDue(context.Background(), server.Client(), drop)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	unlock()
+	second.syncFeedIfDue(context.Background(), server.Client(), drop)
+	assert.Equal(t, int32(2), fetches.Load())
+
+	// Состояние общее: оба экземпляра показывают одну и ту же синхронизацию
+	firstStatuses, err := first.FeedStatuses()
+	require.NoError(t, err)
+	secondStatuses, err := second.FeedStatuses()
+	require.NoError(t, err)
+	assert.Equal(t, firstStatuses, secondStatuses)
+	assert.Equal(t, 1, firstStatuses[0].Entries)
+}
+
+func TestCheckAuth_GeoRules(t *testing.T) {
+	application, logger := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	application.geo = memoryGeoResolver{
+		"203.0.113.1":  {Country: "KP", ASN: 131279, ASOrg: "Ryugyong-dong"},
+		"203.0.113.2":  {Country: "KP", ASN: 13335, ASOrg: "CLOUDFLARENET"},
+		"198.51.100.1": {Country: "NL", ASN: 14061, ASOrg: "DIGITALOCEAN-ASN"},
+		"192.0.2.1":    {Country: "KP", ASN: 131279},
+	}
+
+	require.NoError(t, application.CreateGeoRule(&domain.GeoRule{
+		ListType: domain.Blacklist, Kind: domain.GeoRuleCountry, Value: "kp",
+	}))
+	require.NoError(t, application.CreateGeoRule(&domain.GeoRule{
+		ListType: domain.Blacklist, Kind: domain.GeoRuleASN, Value: "AS14061",
+	}))
+	require.NoError(t, application.CreateGeoRule(&domain.GeoRule{
+		ListType: domain.Whitelist, Kind: domain.GeoRuleASN, Value: "13335",
+	}))
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "192.0.2.0/24"})
+
+	err := application.CreateGeoRule(&domain.GeoRule{
+		ListType: domain.Blacklist, Kind: domain.GeoRuleCountry, Value: "RUS",
+	})
+	require.ErrorIs(t, err, domain.ErrInvalidGeoRule)
+
+	tests := []struct {
+		ip     string
+		reason domain.DenialReason
+		match  string
+	}{
+		{ip: "203.0.113.1", reason: domain.DenialGeoBlacklist, match: "country:KP"},
+		{ip: "198.51.100.1", reason: domain.DenialGeoBlacklist, match: "asn:AS14061"},
+		// ASN из белого списка отменяет блокировку страны, но лимиты продолжают действовать
+		{ip: "203.0.113.2"},
+		// Подсеть белого списка точнее правила по стране
+		{ip: "192.0.2.1", match: "192.0.2.0/24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: tt.ip})
+			require.NoError(t, err)
+			assert.Equal(t, tt.reason == "", response.OK)
+			assert.Equal(t, tt.reason, response.Reason)
+			assert.Equal(t, tt.match, response.Match)
+		})
+	}
+
+	assert.True(t, slices.ContainsFunc(logger.lines, func(line string) bool {
+		return strings.Contains(line, "IP blocked by geo rule") && strings.Contains(line, "country:KP")
+	}))
+
+	explained, err := application.Explain(domain.ExplainRequest{IP: "203.0.113.2"})
+	require.NoError(t, err)
+	assert.Equal(t, &domain.GeoInfo{Country: "KP", ASN: 13335, ASOrg: "CLOUDFLARENET"}, explained.Geo)
+	assert.Equal(t, []domain.GeoRuleMatch{
+		{ListType: domain.Blacklist, Rule: "country:KP"},
+		{ListType: domain.Whitelist, Rule: "asn:AS13335"},
+	}, explained.GeoRules)
+
+	require.NoError(t, application.DeleteGeoRule(domain.Blacklist, domain.GeoRuleCountry, "KP"))
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "203.0.113.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+}
+
+func TestCheckAuth_WhitelistTrustLevels(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 2, PasswordLimit: 100, IPLimit: 1, Window: 60})
+	createSubnet(t, application, &domain.Subnet{
+		ListType: domain.Whitelist, CIDR: "10.1.0.0/16", Trust: domain.TrustBypassAll,
+	})
+	createSubnet(t, application, &domain.Subnet{
+		ListType: domain.Whitelist, CIDR: "10.2.0.0/16", Trust: domain.TrustBypassIP,
+	})
+	createSubnet(t, application, &domain.Subnet{
+		ListType: domain.Whitelist, CIDR: "10.3.0.0/16", Trust: domain.TrustRelaxed,
+	})
+
+	// allowed возвращает, сколько попыток подряд пропущено, пока не сработал лимит
+	allowed := func(login, ip string, attempts int) int {
+		t.Helper()
+		for i := 0; i < attempts; i++ {
+			response, err := application.CheckAuth(domain.AuthRequest{Login: login, Password: "guess", IP: ip})
+			require.NoError(t, err)
+			if !response.OK {
+				return i
+			}
+		}
+		return attempts
+	}
+
+	assert.Equal(t, 50, allowed("bypass-all", "10.1.0.1", 50))
+
+	// Бакет IP не проверяется, но перебор одного логина по-прежнему ограничен
+	assert.Equal(t, 2, allowed("office-user1", "10.2.0.1", 50))
+	assert.Equal(t, 2, allowed("office-user2", "10.2.0.1", 50))
+
+	assert.Equal(t, 10, allowed("relaxed-user", "10.3.0.1", 50))
+
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "office-user1", Password: "pass", IP: "10.2.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialLoginLimit, response.Reason)
+}
+
+func TestIPListsCache_Precedence(t *testing.T) {
+	blacklist := []domain.Subnet{{ListType: domain.Blacklist, CIDR: "10.0.0.0/8"}}
+	whitelist := []domain.Subnet{{ListType: domain.Whitelist, CIDR: "10.1.0.0/16", Trust: domain.TrustBypassIP}}
+
+	tests := []struct {
+		precedence domain.ListPrecedence
+		ip         string
+		status     domain.IPListStatus
+		match      string
+	}{
+		{precedence: domain.PrecedenceBlacklistFirst, ip: "10.1.0.1", status: domain.IPInBlacklist, match: "10.0.0.0/8"},
+		{precedence: domain.PrecedenceMostSpecific, ip: "10.1.0.1", status: domain.IPInWhitelist, match: "10.1.0.0/16"},
+		{precedence: domain.PrecedenceMostSpecific, ip: "10.2.0.1", status: domain.IPInBlacklist, match: "10.0.0.0/8"},
+		{precedence: domain.PrecedenceWhitelistFirst, ip: "10.1.0.1", status: domain.IPInWhitelist, match: "10.1.0.0/16"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.precedence)+" "+tt.ip, func(t *testing.T) {
+			cache := newIPListsCache(time.Minute, "", tt.precedence)
+			require.NoError(t, cache.reload(blacklist, whitelist, nil, 0))
+
+			status, match, err := cache.checkIP(tt.ip)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.match, match.CIDR)
+			if status == domain.IPInWhitelist {
+				assert.Equal(t, domain.TrustBypassIP, match.Trust)
+			}
+		})
+	}
+}
+
+func TestCheckAuth_Greylist(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{
+		LoginLimit:         10,
+		PasswordLimit:      10,
+		IPLimit:            10,
+		Window:             60,
+		GreylistLoginLimit: 2,
+		GreylistIPLimit:    3,
+	})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Greylist, CIDR: "192.0.2.0/24"})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "192.0.2.128/25"})
+
+	// Подсеть чёрного списка важнее серого
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.0.2.200"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialBlacklist, response.Reason)
+
+	for i := 0; i < 2; i++ {
+		response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.0.2.1"})
+		require.NoError(t, err)
+		assert.True(t, response.OK)
+		assert.Equal(t, "192.0.2.0/24", response.Match)
+	}
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.False(t, response.OK)
+	assert.Equal(t, domain.DenialLoginLimit, response.Reason)
+
+	// Отклонённая попытка не расходует бакет IP, поэтому свободен ещё один токен
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "other", Password: "pass2", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "third", Password: "pass3", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialIPLimit, response.Reason)
+
+	// Вне серого списка действуют обычные лимиты и свои бакеты
+	for i := 0; i < 10; i++ {
+		response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+		require.NoError(t, err)
+		assert.True(t, response.OK)
+	}
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialLoginLimit, response.Reason)
+
+	explained, err := application.Explain(domain.ExplainRequest{IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.IPInGreylist, explained.ListStatus)
+}
+
+func TestCheckAuth_LoginRules(t *testing.T) {
+	application, logger := newTestApp(t, ratelimit.Config{LoginLimit: 2, PasswordLimit: 100, IPLimit: 100, Window: 60})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "10.1.0.0/16"})
+
+	require.NoError(t, application.CreateLoginRule(&domain.LoginRule{ListType: domain.Blacklist, Pattern: " admin "}))
+	require.NoError(t, application.CreateLoginRule(&domain.LoginRule{
+		ListType: domain.Whitelist, Kind: domain.LoginRuleGlob, Pattern: "probe-*",
+	}))
+	require.NoError(t, application.CreateLoginRule(&domain.LoginRule{
+		ListType: domain.Blacklist, Kind: domain.LoginRuleRegex, Pattern: "probe-(test|dev)",
+	}))
+
+	err := application.CreateLoginRule(&domain.LoginRule{
+		ListType: domain.Blacklist, Kind: domain.LoginRuleRegex, Pattern: "probe-(",
+	})
+	require.ErrorIs(t, err, domain.ErrInvalidLoginRule)
+	err = application.CreateLoginRule(&domain.LoginRule{ListType: domain.Blacklist, Kind: "prefix", Pattern: "a"})
+	require.ErrorIs(t, err, domain.ErrInvalidLoginRule)
+
+	// Логин из чёрного списка отклоняется даже из подсети белого списка
+	for _, ip := range []string{"192.0.2.1", "10.1.0.1"} {
+		response, err := application.CheckAuth(domain.AuthRequest{Login: "admin", Password: "pass", IP: ip})
+		require.NoError(t, err)
+		assert.False(t, response.OK)
+		assert.Equal(t, domain.DenialLoginBlacklist, response.Reason)
+		assert.Equal(t, "exact:admin", response.Match)
+	}
+
+	// Регулярное выражение совпадает только со всем логином, чёрный список важнее белого
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "probe-dev", Password: "pass", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "regex:probe-(test|dev)", response.Match)
+
+	// Сервисная учётка не ограничена бакетом логина
+	for i := 0; i < 5; i++ {
+		response, err = application.CheckAuth(domain.AuthRequest{Login: "probe-eu1", Password: "pass", IP: "192.0.2.1"})
+		require.NoError(t, err)
+		assert.True(t, response.OK)
+	}
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "probe-dev-eu1", Password: "pass", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	for i := 0; i < 2; i++ {
+		response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.0.2.1"})
+		require.NoError(t, err)
+		assert.True(t, response.OK)
+	}
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.DenialLoginLimit, response.Reason)
+
+	assert.True(t, slices.ContainsFunc(logger.lines, func(line string) bool {
+		return strings.Contains(line, "Login blocked by blacklist") && strings.Contains(line, "exact:admin")
+	}))
+
+	explained, err := application.Explain(domain.ExplainRequest{Login: "probe-test"})
+	require.NoError(t, err)
+	assert.Equal(t, []domain.LoginRuleMatch{
+		{ListType: domain.Blacklist, Rule: "regex:probe-(test|dev)", Winner: true},
+		{ListType: domain.Whitelist, Rule: "glob:probe-*"},
+	}, explained.LoginRules)
+
+	rules, err := application.GetLoginRules(domain.Whitelist)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "glob:probe-*", rules[0].String())
+
+	require.NoError(t, application.DeleteLoginRule(domain.Blacklist, "", "admin"))
+	require.ErrorIs(t, application.DeleteLoginRule(domain.Blacklist, "", "admin"), domain.ErrLoginRuleNotFound)
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "admin", Password: "pass", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+}
+
+func TestCreateSubnetRange(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Whitelist, CIDR: "203.0.113.64/26"})
+
+	ipRange, err := domain.ParseIPRange("203.0.113.17-203.0.113.70")
+	require.NoError(t, err)
+	template := domain.Subnet{ListType: domain.Blacklist, Comment: "abuse report", Tags: []string{"abuse"}}
+
+	_, _, err = application.CreateSubnetRange(template, ipRange, false)
+	var overlapErr *domain.SubnetOverlapError
+	require.ErrorAs(t, err, &overlapErr)
+	// Пересечения собираются по всем подсетям диапазона
+	require.Len(t, overlapErr.Overlaps, 3)
+	assert.Equal(t, "203.0.113.64/30", overlapErr.Overlaps[0].Subnet.CIDR)
+	assert.Equal(t, domain.OverlapConflict, overlapErr.Overlaps[0].Kind)
+
+	page, err := application.GetSubnetsByListType(domain.Blacklist, domain.SubnetFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Subnets, "nothing is added when the range is refused")
+
+	created, overlaps, err := application.CreateSubnetRange(template, ipRange, true)
+	require.NoError(t, err)
+	assert.Len(t, overlaps, 3)
+	cidrs := make([]string, len(created))
+	for i, subnet := range created {
+		cidrs[i] = subnet.CIDR
+		assert.Equal(t, "abuse report", subnet.Comment)
+	}
+	assert.Equal(t, []string{
+		"203.0.113.17/32", "203.0.113.18/31", "203.0.113.20/30", "203.0.113.24/29",
+		"203.0.113.32/27", "203.0.113.64/30", "203.0.113.68/31", "203.0.113.70/32",
+	}, cidrs)
+
+	for _, ip := range []string{"203.0.113.17", "203.0.113.40", "203.0.113.70"} {
+		response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: ip})
+		require.NoError(t, err)
+		assert.Equal(t, domain.DenialBlacklist, response.Reason, ip)
+	}
+	response, err := application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "203.0.113.16"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: "203.0.113.0/32"})
+	deleted, err := application.DeleteSubnetRange(domain.Blacklist, ipRange)
+	require.NoError(t, err)
+	assert.Len(t, deleted, len(created))
+
+	page, err = application.GetSubnetsByListType(domain.Blacklist, domain.SubnetFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Subnets, 1)
+	assert.Equal(t, "203.0.113.0/32", page.Subnets[0].CIDR)
+
+	response, err = application.CheckAuth(domain.AuthRequest{Login: "user", Password: "pass", IP: "203.0.113.40"})
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+
+	_, err = application.DeleteSubnetRange(domain.Blacklist, ipRange)
+	require.ErrorIs(t, err, domain.ErrSubnetNotFound)
+}
+
+func TestGetSubnetsByListType_Pages(t *testing.T) {
+	application, _ := newTestApp(t, ratelimit.Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	for _, cidr := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "192.0.2.0/24", "2001:db8::/32"} {
+		createSubnet(t, application, &domain.Subnet{ListType: domain.Blacklist, CIDR: cidr})
+	}
+	require.NoError(t, application.storage.Subnet().Import(domain.Blacklist, "feed:drop",
+		[]domain.Subnet{{CIDR: "10.1.2.128/25"}, {CIDR: "198.51.100.0/24"}}, false))
+
+	var cidrs []string
+	filter := domain.SubnetFilter{Limit: 3}
+	for pages := 1; ; pages++ {
+		page, err := application.GetSubnetsByListType(domain.Blacklist, filter)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Subnets), 3)
+		for _, subnet := range page.Subnets {
+			cidrs = append(cidrs, subnet.CIDR)
+		}
+		if page.NextCursor == "" {
+			assert.Equal(t, 3, pages)
+			break
+		}
+
+		filter.After, err = domain.DecodeSubnetCursor(page.NextCursor)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{
+		"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.128/25", "192.0.2.0/24", "198.51.100.0/24", "2001:db8::/32",
+	}, cidrs)
+
+	filterCIDRs := func(filter domain.SubnetFilter) []string {
+		t.Helper()
+		page, err := application.GetSubnetsByListType(domain.Blacklist, filter)
+		require.NoError(t, err)
+		assert.Empty(t, page.NextCursor)
+		cidrs := []string{}
+		for _, subnet := range page.Subnets {
+			cidrs = append(cidrs, subnet.CIDR)
+		}
+		return cidrs
+	}
+
+	assert.Equal(t, []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"},
+		filterCIDRs(domain.SubnetFilter{Contains: netip.MustParseAddr("10.1.2.3")}))
+	assert.Equal(t, []string{"10.1.0.0/16", "10.1.2.0/24", "10.1.2.128/25"},
+		filterCIDRs(domain.SubnetFilter{Within: netip.MustParsePrefix("10.1.0.0/16")}))
+	assert.Equal(t, []string{"10.1.2.128/25", "198.51.100.0/24"},
+		filterCIDRs(domain.SubnetFilter{Source: "feed:drop"}))
+	assert.Equal(t, []string{"10.1.2.0/24"},
+		filterCIDRs(domain.SubnetFilter{Source: domain.SourceManual, Within: netip.MustParsePrefix("10.1.2.0/24")}))
+	assert.Empty(t, filterCIDRs(domain.SubnetFilter{CreatedSince: time.Now().Add(time.Hour)}))
+	assert.Len(t, filterCIDRs(domain.SubnetFilter{CreatedSince: time.Now().Add(-time.Hour)}), 7)
+
+	_, err := domain.DecodeSubnetCursor("not a cursor")
+	require.ErrorIs(t, err, domain.ErrInvalidCursor)
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// Explain показывает, какие подсети списков и бакеты влияют на решение по IP и логину.
+// Ничего не списывает и не меняет.
+func (a *App) Explain(req domain.ExplainRequest) (domain.ExplainResponse, error) {
+	if req.IP == "" && req.Login == "" {
+		return domain.ExplainResponse{}, fmt.Errorf("either ip or login must be provided")
+	}
+
+	response := domain.ExplainResponse{
+		IP:         req.IP,
+		Login:      req.Login,
+		Precedence: string(a.cache.precedence),
+		Matches:    []domain.ListMatch{},
+		ListsAge:   int(a.cache.age().Round(time.Second).Seconds()),
+	}
+
+	if req.IP != "" {
+		matches, status, err := a.cache.explainIP(req.IP)
+		if err != nil {
+			return domain.ExplainResponse{}, err
+		}
+		if matches != nil {
+			response.Matches = matches
+		}
+		response.ListStatus = status
+
+		if a.geo != nil {
+			geo := a.lookupGeo(req.IP)
+			response.Geo = &geo
+			response.GeoRules = explainGeoRules(a.geoRules.matches(geo))
+		}
+	}
+
+	if req.Login != "" {
+		response.LoginRules = explainLoginRules(a.loginRules.matches(req.Login))
+	}
+
+	buckets, err := a.GetBuckets(domain.BucketsRequest{Login: req.Login, IP: req.IP})
+	if err != nil {
+		return domain.ExplainResponse{}, err
+	}
+	response.Buckets = buckets.Buckets
+	response.Bans = buckets.Bans
+
+	return response, nil
+}
+
+func explainGeoRules(rules []domain.GeoRule) []domain.GeoRuleMatch {
+	winner, blocked := blockingRule(rules)
+
+	matches := make([]domain.GeoRuleMatch, len(rules))
+	for i, rule := range rules {
+		matches[i] = domain.GeoRuleMatch{
+			ListType: rule.ListType,
+			Rule:     rule.String(),
+			Winner:   blocked && rule == winner,
+		}
+	}
+
+	return matches
+}
+
+func explainLoginRules(rules []domain.LoginRule) []domain.LoginRuleMatch {
+	matches := make([]domain.LoginRuleMatch, len(rules))
+	for i, rule := range rules {
+		matches[i] = domain.LoginRuleMatch{
+			ListType: rule.ListType,
+			Rule:     rule.String(),
+			// Первое правило определяет решение: правила чёрного списка идут раньше
+			Winner: i == 0,
+		}
+	}
+
+	return matches
+}
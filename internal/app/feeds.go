@@ -0,0 +1,204 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/gomonov/otus-go-project/internal/feed"
+)
+
+// feedFetchTimeout ограничивает скачивание и разбор одного фида.
+const feedFetchTimeout = 2 * time.Minute
+
+// RunFeedSync синхронизирует фиды в чёрный список: сразу и дальше каждый feed.Interval, пока не завершится
+// контекст. Подсети, пропавшие из фида, удаляются из списка, подсети, добавленные вручную, не трогаются.
+// Из всех экземпляров сервиса фид за период синхронизирует один, остальные пропускают свою очередь.
+func (a *App) RunFeedSync(ctx context.Context, feeds []feed.Feed) {
+	a.feedsMu.Lock()
+	a.feeds = slices.Clone(feeds)
+	a.feedsMu.Unlock()
+
+	client := &http.Client{Timeout: feedFetchTimeout}
+	for _, f := range feeds {
+		go a.runFeed(ctx, client, f)
+	}
+}
+
+func (a *App) runFeed(ctx context.Context, client *http.Client, f feed.Feed) {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+
+	for {
+		a.syncFeedIfDue(ctx, client, f)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncFeedIfDue синхронизирует фид, если его не синхронизирует сейчас и не синхронизировал недавно
+// другой экземпляр сервиса. Недавно - меньше чем за 9/10 периода: таймеры экземпляров не совпадают,
+// и без запаса фид скачивался бы каждым из них по очереди.
+func (a *App) syncFeedIfDue(ctx context.Context, client *http.Client, f feed.Feed) {
+	unlock, acquired, err := a.storage.Feed().Lock(ctx, f.Name)
+	if err != nil {
+		a.logger.Error("Failed to lock feed sync", "feed", f.Name, "error", err.Error())
+		return
+	}
+	if !acquired {
+		a.logger.Debug("Feed is being synced by another instance", "feed", f.Name)
+		return
+	}
+	defer unlock()
+
+	status, err := a.storedFeedStatus(f.Name)
+	if err != nil {
+		a.logger.Error("Failed to read feed status", "feed", f.Name, "error", err.Error())
+		return
+	}
+	if status.LastAttempt != nil && time.Since(*status.LastAttempt) < f.Interval-f.Interval/10 {
+		a.logger.Debug("Feed was recently synced by another instance", "feed", f.Name,
+			"last_attempt", status.LastAttempt)
+		return
+	}
+
+	a.syncFeed(ctx, client, f)
+}
+
+func (a *App) syncFeed(ctx context.Context, client *http.Client, f feed.Feed) {
+	status, err := a.storedFeedStatus(f.Name)
+	if err != nil {
+		a.logger.Error("Failed to read feed status", "feed", f.Name, "error", err.Error())
+		return
+	}
+
+	started := time.Now()
+	result, parsed, err := a.fetchAndImportFeed(ctx, client, f)
+
+	status.LastAttempt = &started
+	if err != nil {
+		status.Error = err.Error()
+		a.logger.Error("Feed sync failed", "feed", f.Name, "url", f.URL, "error", err.Error())
+	} else {
+		status.LastSync = &started
+		status.Error = ""
+		status.Entries = len(parsed.Subnets)
+		status.Invalid = parsed.Invalid
+		status.Added = len(result.Added)
+		status.Updated = len(result.Updated)
+		status.Removed = len(result.Removed)
+
+		a.logger.Info("Feed synced",
+			"feed", f.Name,
+			"entries", status.Entries,
+			"invalid", status.Invalid,
+			"added", status.Added,
+			"updated", status.Updated,
+			"removed", status.Removed)
+	}
+
+	if err := a.storage.Feed().SaveStatus(status); err != nil {
+		a.logger.Error("Failed to save feed status", "feed", f.Name, "error", err.Error())
+	}
+}
+
+// storedFeedStatus возвращает сохранённое состояние фида или пустое, если фид ещё не синхронизировался.
+func (a *App) storedFeedStatus(name string) (domain.FeedStatus, error) {
+	statuses, err := a.storage.Feed().GetStatuses()
+	if err != nil {
+		return domain.FeedStatus{}, err
+	}
+
+	for _, status := range statuses {
+		if status.Name == name {
+			return status, nil
+		}
+	}
+
+	return domain.FeedStatus{Name: name}, nil
+}
+
+func (a *App) fetchAndImportFeed(
+	ctx context.Context, client *http.Client, f feed.Feed,
+) (domain.ImportResult, feed.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, feedFetchTimeout)
+	defer cancel()
+
+	data, err := feed.Fetch(ctx, client, f.URL)
+	if err != nil {
+		return domain.ImportResult{}, feed.Result{}, err
+	}
+
+	parsed, err := feed.Parse(f.Format, data)
+	if err != nil {
+		return domain.ImportResult{}, parsed, err
+	}
+	for i := range parsed.Subnets {
+		parsed.Subnets[i].CreatedBy = f.Source()
+	}
+
+	current, err := a.storage.Subnet().GetByListType(domain.Blacklist, domain.SubnetFilter{})
+	if err != nil {
+		return domain.ImportResult{}, parsed, fmt.Errorf("failed to read blacklist: %w", err)
+	}
+
+	result := diffSubnets(current, parsed.Subnets, f.Source(), true)
+	if len(result.Added)+len(result.Updated)+len(result.Removed) == 0 {
+		return result, parsed, nil
+	}
+
+	if err := a.storage.Subnet().Import(domain.Blacklist, f.Source(), parsed.Subnets, true); err != nil {
+		return domain.ImportResult{}, parsed, fmt.Errorf("failed to import feed: %w", err)
+	}
+
+	if err := a.LoadIPLists(); err != nil {
+		a.logger.Warn("Failed to reload IP lists after feed sync", "feed", f.Name, "error", err.Error())
+		a.cache.invalidate()
+	}
+
+	return result, parsed, nil
+}
+
+// FeedStatuses возвращает состояние синхронизации настроенных фидов, упорядоченное по имени.
+// Состояние хранится в базе, поэтому одинаково на всех экземплярах сервиса.
+func (a *App) FeedStatuses() ([]domain.FeedStatus, error) {
+	a.feedsMu.Lock()
+	feeds := slices.Clone(a.feeds)
+	a.feedsMu.Unlock()
+	if len(feeds) == 0 {
+		return []domain.FeedStatus{}, nil
+	}
+
+	stored, err := a.storage.Feed().GetStatuses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed statuses: %w", err)
+	}
+	storedByName := make(map[string]domain.FeedStatus, len(stored))
+	for _, status := range stored {
+		storedByName[status.Name] = status
+	}
+
+	statuses := make([]domain.FeedStatus, 0, len(feeds))
+	for _, f := range feeds {
+		status := storedByName[f.Name]
+		status.Name = f.Name
+		status.URL = f.URL
+		status.Format = string(f.Format)
+		status.Source = f.Source()
+		status.Interval = int(f.Interval.Seconds())
+		statuses = append(statuses, status)
+	}
+	slices.SortFunc(statuses, func(x, y domain.FeedStatus) int {
+		return strings.Compare(x.Name, y.Name)
+	})
+
+	return statuses, nil
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"net/netip"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// CreateGeoRule добавляет правило по стране или ASN и сразу применяет его на этом экземпляре.
+// Остальные экземпляры перечитают правила, получив уведомление об изменении.
+func (a *App) CreateGeoRule(rule *domain.GeoRule) error {
+	value, err := domain.NormalizeGeoRule(rule.Kind, rule.Value)
+	if err != nil {
+		return err
+	}
+	rule.Value = value
+
+	a.logger.Info("Creating geo rule: ", rule.String(), " for list: ", rule.ListType)
+	if err := a.storage.GeoRule().Create(rule); err != nil {
+		return err
+	}
+
+	a.reloadGeoRules()
+	return nil
+}
+
+func (a *App) DeleteGeoRule(listType domain.ListType, kind domain.GeoRuleKind, value string) error {
+	value, err := domain.NormalizeGeoRule(kind, value)
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("Deleting geo rule: ", kind, ":", value, " from list: ", listType)
+	if err := a.storage.GeoRule().Delete(listType, kind, value); err != nil {
+		return err
+	}
+
+	a.reloadGeoRules()
+	return nil
+}
+
+func (a *App) GetGeoRules(listType domain.ListType) ([]domain.GeoRule, error) {
+	rules, err := a.storage.GeoRule().GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]domain.GeoRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.ListType == listType {
+			filtered = append(filtered, rule)
+		}
+	}
+
+	return filtered, nil
+}
+
+// LoadGeoRules загружает правила по странам и ASN в кэш.
+func (a *App) LoadGeoRules() error {
+	rules, err := a.storage.GeoRule().GetAll()
+	if err != nil {
+		return err
+	}
+
+	a.geoRules.reload(rules)
+	a.logger.Debug("Geo rules cache reloaded", "count", len(rules))
+	return nil
+}
+
+func (a *App) reloadGeoRules() {
+	if err := a.LoadGeoRules(); err != nil {
+		a.logger.Error("Failed to reload geo rules", "error", err.Error())
+	}
+}
+
+// lookupGeo определяет страну и ASN IP. Без базы GeoIP или при ошибке возвращает пустой GeoInfo,
+// и правила по странам и ASN к IP не применяются.
+func (a *App) lookupGeo(ip string) domain.GeoInfo {
+	if a.geo == nil {
+		return domain.GeoInfo{}
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return domain.GeoInfo{}
+	}
+
+	info, err := a.geo.Lookup(addr)
+	if err != nil {
+		a.logger.Error("GeoIP lookup failed", "ip", ip, "error", err.Error())
+		return domain.GeoInfo{}
+	}
+
+	return info
+}
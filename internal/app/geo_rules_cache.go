@@ -0,0 +1,84 @@
+package app
+
+import (
+	"net/netip"
+	"strconv"
+	"sync"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// GeoResolver определяет страну и ASN адреса, например по базе MaxMind (см. geoip.Reader).
+type GeoResolver interface {
+	Lookup(addr netip.Addr) (domain.GeoInfo, error)
+}
+
+type geoRuleKey struct {
+	listType domain.ListType
+	kind     domain.GeoRuleKind
+	value    string
+}
+
+// geoRulesCache хранит правила по странам и ASN. Как и списки подсетей, правила перечитываются
+// по уведомлению об их изменении (App.RunListener) и App.RunCacheRefresher. Пока правила не загружены
+// из базы, IP по ним не блокируются.
+type geoRulesCache struct {
+	mu    sync.RWMutex
+	rules map[geoRuleKey]domain.GeoRule
+}
+
+func newGeoRulesCache() *geoRulesCache {
+	return &geoRulesCache{rules: make(map[geoRuleKey]domain.GeoRule)}
+}
+
+func (c *geoRulesCache) reload(rules []domain.GeoRule) {
+	loaded := make(map[geoRuleKey]domain.GeoRule, len(rules))
+	for _, rule := range rules {
+		loaded[geoRuleKey{listType: rule.ListType, kind: rule.Kind, value: rule.Value}] = rule
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = loaded
+}
+
+// matches возвращает правила, подходящие к стране и ASN: сначала чёрного списка, затем белого.
+func (c *geoRulesCache) matches(info domain.GeoInfo) []domain.GeoRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var matches []domain.GeoRule
+	for _, listType := range []domain.ListType{domain.Blacklist, domain.Whitelist} {
+		keys := make([]geoRuleKey, 0, 2)
+		if info.Country != "" {
+			keys = append(keys, geoRuleKey{listType: listType, kind: domain.GeoRuleCountry, value: info.Country})
+		}
+		if info.ASN != 0 {
+			asn := strconv.FormatUint(uint64(info.ASN), 10)
+			keys = append(keys, geoRuleKey{listType: listType, kind: domain.GeoRuleASN, value: asn})
+		}
+
+		for _, key := range keys {
+			if rule, ok := c.rules[key]; ok {
+				matches = append(matches, rule)
+			}
+		}
+	}
+
+	return matches
+}
+
+// blockingRule возвращает правило чёрного списка, по которому IP блокируется. Правило белого списка
+// отменяет блокировку.
+func blockingRule(matches []domain.GeoRule) (domain.GeoRule, bool) {
+	for _, rule := range matches {
+		if rule.ListType == domain.Whitelist {
+			return domain.GeoRule{}, false
+		}
+	}
+	if len(matches) == 0 {
+		return domain.GeoRule{}, false
+	}
+
+	return matches[0], true
+}
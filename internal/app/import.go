@@ -0,0 +1,135 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// ImportSubnets добавляет подсети в список одной транзакцией. Подсети с повторяющимся CIDR
+// схлопываются, действует последняя. С DryRun только возвращает изменения, которые внёс бы импорт.
+func (a *App) ImportSubnets(
+	listType domain.ListType, subnets []domain.Subnet, opts domain.ImportOptions,
+) (domain.ImportResult, error) {
+	imported, err := dedupeSubnets(listType, subnets)
+	if err != nil {
+		return domain.ImportResult{}, err
+	}
+
+	current, err := a.storage.Subnet().GetByListType(listType, domain.SubnetFilter{})
+	if err != nil {
+		return domain.ImportResult{}, fmt.Errorf("failed to read %s: %w", listType, err)
+	}
+
+	result := diffSubnets(current, imported, "", opts.Mode == domain.ImportReplace)
+	result.Mode = opts.Mode
+	result.DryRun = opts.DryRun
+
+	a.logger.Info("Importing subnets",
+		"list", listType,
+		"mode", opts.Mode,
+		"dry_run", opts.DryRun,
+		"added", len(result.Added),
+		"updated", len(result.Updated),
+		"removed", len(result.Removed),
+		"unchanged", result.Unchanged)
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if err := a.storage.Subnet().Import(listType, "", imported, opts.Mode == domain.ImportReplace); err != nil {
+		return domain.ImportResult{}, fmt.Errorf("failed to import %s: %w", listType, err)
+	}
+
+	// Список меняется целиком, поэтому кэш перечитывается, а не обновляется по одной подсети
+	if err := a.LoadIPLists(); err != nil {
+		a.logger.Warn("Failed to reload IP lists after import", "error", err.Error())
+		a.cache.invalidate()
+	}
+
+	return result, nil
+}
+
+// ExportSubnets возвращает все действующие подсети списка.
+func (a *App) ExportSubnets(listType domain.ListType) ([]domain.Subnet, error) {
+	return a.storage.Subnet().GetByListType(listType, domain.SubnetFilter{})
+}
+
+func dedupeSubnets(listType domain.ListType, subnets []domain.Subnet) ([]domain.Subnet, error) {
+	index := make(map[string]int, len(subnets))
+	deduped := make([]domain.Subnet, 0, len(subnets))
+
+	for _, subnet := range subnets {
+		cidr, err := domain.CanonicalCIDR(subnet.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		subnet.CIDR = cidr
+
+		trust, err := domain.NormalizeTrustLevel(listType, string(subnet.Trust))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cidr, err)
+		}
+		subnet.Trust = trust
+
+		if i, ok := index[cidr]; ok {
+			deduped[i] = subnet
+			continue
+		}
+		index[cidr] = len(deduped)
+		deduped = append(deduped, subnet)
+	}
+
+	return deduped, nil
+}
+
+// diffSubnets считает изменения, которые внесёт storage.SubnetRepository.Import подсетей imported
+// от источника source.
+func diffSubnets(current, imported []domain.Subnet, source string, replace bool) domain.ImportResult {
+	result := domain.ImportResult{Added: []string{}, Updated: []string{}, Removed: []string{}}
+
+	existing := make(map[string]domain.Subnet, len(current))
+	for _, subnet := range current {
+		existing[subnet.CIDR] = subnet
+	}
+
+	for _, subnet := range imported {
+		old, ok := existing[subnet.CIDR]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, subnet.CIDR)
+		case source != "" && old.Source != source:
+			// Подсеть добавлена вручную или другим фидом и остаётся как есть
+			result.Unchanged++
+		case old.Source != source || subnetChanged(old, subnet):
+			result.Updated = append(result.Updated, subnet.CIDR)
+		default:
+			result.Unchanged++
+		}
+		delete(existing, subnet.CIDR)
+	}
+
+	if replace {
+		for cidr, subnet := range existing {
+			if subnet.Source == source {
+				result.Removed = append(result.Removed, cidr)
+			}
+		}
+		slices.Sort(result.Removed)
+	}
+
+	return result
+}
+
+func subnetChanged(old, subnet domain.Subnet) bool {
+	if old.Comment != subnet.Comment || old.Trust != subnet.Trust || !slices.Equal(old.Tags, subnet.Tags) {
+		return true
+	}
+	if (old.ExpiresAt == nil) != (subnet.ExpiresAt == nil) {
+		return true
+	}
+
+	return old.ExpiresAt != nil && !old.ExpiresAt.Equal(*subnet.ExpiresAt)
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,104 +11,364 @@ import (
 	"github.com/yl2chen/cidranger"
 )
 
+// maxRecentChanges - сколько последних изменений помнит кэш, чтобы применить их поверх перечитанных списков.
+const maxRecentChanges = 1024
+
+// IPListsCache хранит списки в префиксных деревьях. Списки перечитываются фоновой горутиной
+// (см. App.RunCacheRefresher), а при недоступности базы продолжает отдаваться последняя загруженная копия.
 type IPListsCache struct {
 	mu            sync.RWMutex
 	blacklist     cidranger.Ranger
 	whitelist     cidranger.Ranger
+	greylist      cidranger.Ranger
 	lastLoaded    time.Time
-	ttl           time.Duration
+	maxStaleness  time.Duration
 	isInitialized bool
+	precedence    domain.ListPrecedence
+
+	// snapshotPath - файл снимка списков для старта без базы. Пустой путь отключает снимки.
+	snapshotPath string
+
+	// version растёт с каждым изменением, полученным от слушателя. recent - последние из них.
+	version uint64
+	recent  []versionedChange
+
+	// refresh просит фоновую горутину перечитать списки, не дожидаясь очередного периода.
+	refresh chan struct{}
 }
 
-func newIPListsCache(ttl time.Duration) *IPListsCache {
+type versionedChange struct {
+	version uint64
+	change  domain.SubnetChange
+}
+
+func newIPListsCache(maxStaleness time.Duration, snapshotPath string, precedence domain.ListPrecedence) *IPListsCache {
+	if precedence == "" {
+		precedence = domain.PrecedenceBlacklistFirst
+	}
+
 	return &IPListsCache{
 		blacklist:     cidranger.NewPCTrieRanger(),
 		whitelist:     cidranger.NewPCTrieRanger(),
-		ttl:           ttl,
+		greylist:      cidranger.NewPCTrieRanger(),
+		maxStaleness:  maxStaleness,
 		isInitialized: false,
+		precedence:    precedence,
+		snapshotPath:  snapshotPath,
+		refresh:       make(chan struct{}, 1),
 	}
 }
 
-func (c *IPListsCache) needsReload() bool {
+// age возвращает, сколько времени прошло с последней успешной загрузки списков.
+func (c *IPListsCache) age() time.Duration {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if !c.isInitialized {
-		return true
+		return 0
 	}
 
-	return time.Since(c.lastLoaded) > c.ttl
+	return time.Since(c.lastLoaded)
 }
 
-func (c *IPListsCache) reload(blacklist, whitelist []domain.Subnet) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// currentVersion запоминается перед чтением списков из базы и передаётся в reload.
+func (c *IPListsCache) currentVersion() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.version
+}
 
+// reload заменяет списки прочитанными из базы. Деревья строятся без блокировки и подменяются целиком,
+// поэтому проверки не ждут загрузки. Изменения, полученные после версии since, могли не попасть
+// в прочитанные списки, поэтому применяются поверх них.
+func (c *IPListsCache) reload(blacklist, whitelist, greylist []domain.Subnet, since uint64) error {
 	newBlacklist := cidranger.NewPCTrieRanger()
 	newWhitelist := cidranger.NewPCTrieRanger()
+	newGreylist := cidranger.NewPCTrieRanger()
 
 	for _, subnet := range blacklist {
-		_, network, err := net.ParseCIDR(subnet.CIDR)
+		entry, err := newListEntry(subnet)
 		if err != nil {
 			return fmt.Errorf("invalid CIDR in blacklist: %s, error: %w", subnet.CIDR, err)
 		}
-		if err := newBlacklist.Insert(cidranger.NewBasicRangerEntry(*network)); err != nil {
+		if err := newBlacklist.Insert(entry); err != nil {
 			return fmt.Errorf("failed to insert into blacklist: %w", err)
 		}
 	}
 
 	for _, subnet := range whitelist {
-		_, network, err := net.ParseCIDR(subnet.CIDR)
+		entry, err := newListEntry(subnet)
 		if err != nil {
 			return fmt.Errorf("invalid CIDR in whitelist: %s, error: %w", subnet.CIDR, err)
 		}
-		if err := newWhitelist.Insert(cidranger.NewBasicRangerEntry(*network)); err != nil {
+		if err := newWhitelist.Insert(entry); err != nil {
 			return fmt.Errorf("failed to insert into whitelist: %w", err)
 		}
 	}
 
+	for _, subnet := range greylist {
+		entry, err := newListEntry(subnet)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR in greylist: %s, error: %w", subnet.CIDR, err)
+		}
+		if err := newGreylist.Insert(entry); err != nil {
+			return fmt.Errorf("failed to insert into greylist: %w", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, recent := range c.recent {
+		if recent.version <= since {
+			continue
+		}
+		if err := applyChange(newBlacklist, newWhitelist, newGreylist, recent.change); err != nil {
+			return err
+		}
+	}
+
 	c.blacklist = newBlacklist
 	c.whitelist = newWhitelist
+	c.greylist = newGreylist
 	c.lastLoaded = time.Now()
 	c.isInitialized = true
 
+	// Часть изменений после since уже вытеснена из recent: списки нужно перечитать ещё раз
+	if len(c.recent) > 0 && c.recent[0].version > since+1 {
+		c.requestRefresh()
+	}
+
+	return nil
+}
+
+// apply применяет изменение списка, не перечитывая списки целиком.
+func (c *IPListsCache) apply(change domain.SubnetChange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	c.recent = append(c.recent, versionedChange{version: c.version, change: change})
+	if len(c.recent) > maxRecentChanges {
+		c.recent = c.recent[len(c.recent)-maxRecentChanges:]
+	}
+
+	if !c.isInitialized {
+		return nil
+	}
+
+	if err := applyChange(c.blacklist, c.whitelist, c.greylist, change); err != nil {
+		// Кэш мог разойтись с базой: перечитаем списки
+		c.requestRefresh()
+		return err
+	}
+
 	return nil
 }
 
-func (c *IPListsCache) checkIP(ipStr string) (domain.IPListStatus, error) {
+// invalidate просит перечитать списки, не дожидаясь очередного периода.
+func (c *IPListsCache) invalidate() {
+	c.requestRefresh()
+}
+
+func (c *IPListsCache) requestRefresh() {
+	select {
+	case c.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func applyChange(blacklist, whitelist, greylist cidranger.Ranger, change domain.SubnetChange) error {
+	var ranger cidranger.Ranger
+	switch change.Subnet.ListType {
+	case domain.Blacklist:
+		ranger = blacklist
+	case domain.Whitelist:
+		ranger = whitelist
+	case domain.Greylist:
+		ranger = greylist
+	default:
+		return fmt.Errorf("unknown list type: %s", change.Subnet.ListType)
+	}
+
+	entry, err := newListEntry(change.Subnet)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR in %s: %s, error: %w", change.Subnet.ListType, change.Subnet.CIDR, err)
+	}
+
+	switch change.Op {
+	case domain.SubnetUpserted:
+		return ranger.Insert(entry)
+	case domain.SubnetDeleted:
+		_, err := ranger.Remove(entry.network)
+		return err
+	case domain.SubnetResync, domain.GeoRulesChanged, domain.LoginRulesChanged:
+	}
+
+	return nil
+}
+
+// checkIP возвращает статус IP и подсеть списка, определившую решение.
+func (c *IPListsCache) checkIP(ipStr string) (domain.IPListStatus, domain.ListMatch, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.isInitialized {
+		return domain.IPNotInList, domain.ListMatch{}, fmt.Errorf("%w: not initialized", domain.ErrIPListsUnavailable)
+	}
+
+	if age := time.Since(c.lastLoaded); c.maxStaleness > 0 && age > c.maxStaleness {
+		return domain.IPNotInList, domain.ListMatch{}, fmt.Errorf("%w: last loaded %s ago",
+			domain.ErrIPListsUnavailable, age.Round(time.Second))
+	}
+
+	matches, err := c.containingNetworks(ipStr)
+	if err != nil {
+		return domain.IPNotInList, domain.ListMatch{}, err
+	}
+
+	winner := precedenceWinner(matches, c.precedence)
+	if winner < 0 {
+		return domain.IPNotInList, domain.ListMatch{}, nil
+	}
+	matches[winner].Winner = true
+
+	return listStatuses[matches[winner].ListType], matches[winner], nil
+}
+
+// explainIP возвращает все действующие подсети списков, содержащие IP, и отмечает победившую.
+// В отличие от checkIP, отвечает и по устаревшей копии списков.
+func (c *IPListsCache) explainIP(ipStr string) ([]domain.ListMatch, domain.IPListStatus, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	if !c.isInitialized {
-		return domain.IPNotInList, fmt.Errorf("IP lists not initialized")
+		return nil, domain.IPNotInList, fmt.Errorf("%w: not initialized", domain.ErrIPListsUnavailable)
 	}
 
+	matches, err := c.containingNetworks(ipStr)
+	if err != nil {
+		return nil, domain.IPNotInList, err
+	}
+
+	winner := precedenceWinner(matches, c.precedence)
+	if winner < 0 {
+		return matches, domain.IPNotInList, nil
+	}
+	matches[winner].Winner = true
+
+	return matches, listStatuses[matches[winner].ListType], nil
+}
+
+var listStatuses = map[domain.ListType]domain.IPListStatus{
+	domain.Blacklist: domain.IPInBlacklist,
+	domain.Whitelist: domain.IPInWhitelist,
+	domain.Greylist:  domain.IPInGreylist,
+}
+
+// listOrders - в каком порядке списки проверяются при каждом ListPrecedence. Серый список строже белого,
+// поэтому при blacklist-first он важнее белого, а при whitelist-first - уступает обоим.
+var listOrders = map[domain.ListPrecedence][]domain.ListType{
+	domain.PrecedenceBlacklistFirst: {domain.Blacklist, domain.Greylist, domain.Whitelist},
+	domain.PrecedenceWhitelistFirst: {domain.Whitelist, domain.Blacklist, domain.Greylist},
+}
+
+// precedenceWinner возвращает индекс подсети, определяющей решение, или -1. matches отсортированы,
+// как их возвращает containingNetworks, поэтому внутри списка всегда побеждает самая специфичная подсеть.
+func precedenceWinner(matches []domain.ListMatch, precedence domain.ListPrecedence) int {
+	if len(matches) == 0 {
+		return -1
+	}
+
+	// При most-specific порядка списков нет: побеждает первая, то есть самая узкая подсеть
+	for _, listType := range listOrders[precedence] {
+		for i, match := range matches {
+			if match.ListType == listType {
+				return i
+			}
+		}
+	}
+
+	return 0
+}
+
+// containingNetworks возвращает действующие подсети списков, содержащие IP, от более специфичных
+// к менее специфичным, при равных префиксах - чёрный, серый, белый. Вызывается под блокировкой.
+func (c *IPListsCache) containingNetworks(ipStr string) ([]domain.ListMatch, error) {
 	ip := net.ParseIP(ipStr)
 	if ip == nil {
-		return domain.IPNotInList, fmt.Errorf("invalid IP address: %s", ipStr)
+		return nil, fmt.Errorf("invalid IP address: %s", ipStr)
 	}
 
-	if ip.To4() == nil {
-		return domain.IPNotInList, fmt.Errorf("only IPv4 addresses are supported")
+	type containingNetwork struct {
+		match  domain.ListMatch
+		prefix int
 	}
 
-	inBlacklist, err := c.blacklist.Contains(ip)
-	if err != nil {
-		return domain.IPNotInList, fmt.Errorf("blacklist check failed: %w", err)
+	now := time.Now()
+	var networks []containingNetwork
+	for _, list := range []struct {
+		listType domain.ListType
+		ranger   cidranger.Ranger
+	}{
+		{listType: domain.Blacklist, ranger: c.blacklist},
+		{listType: domain.Greylist, ranger: c.greylist},
+		{listType: domain.Whitelist, ranger: c.whitelist},
+	} {
+		entries, err := list.ranger.ContainingNetworks(ip)
+		if err != nil {
+			return nil, fmt.Errorf("%s check failed: %w", list.listType, err)
+		}
+
+		for _, entry := range entries {
+			match := domain.ListMatch{ListType: list.listType}
+			if listed, ok := entry.(*listEntry); ok {
+				if listed.expired(now) {
+					continue
+				}
+				match.ExpiresAt = listed.expiresAt
+				match.Trust = listed.trust
+			}
+			network := entry.Network()
+			match.CIDR = network.String()
+			prefix, _ := network.Mask.Size()
+			networks = append(networks, containingNetwork{match: match, prefix: prefix})
+		}
 	}
 
-	if inBlacklist {
-		return domain.IPInBlacklist, nil
+	sort.SliceStable(networks, func(i, j int) bool {
+		return networks[i].prefix > networks[j].prefix
+	})
+
+	matches := make([]domain.ListMatch, len(networks))
+	for i, network := range networks {
+		matches[i] = network.match
 	}
 
-	inWhitelist, err := c.whitelist.Contains(ip)
+	return matches, nil
+}
+
+// listEntry - подсеть списка в кэше. Записи с истёкшим сроком не учитываются, даже если ещё не удалены из базы.
+type listEntry struct {
+	network   net.IPNet
+	expiresAt *time.Time
+	trust     domain.TrustLevel
+}
+
+func newListEntry(subnet domain.Subnet) (*listEntry, error) {
+	_, network, err := net.ParseCIDR(subnet.CIDR)
 	if err != nil {
-		return domain.IPNotInList, fmt.Errorf("whitelist check failed: %w", err)
+		return nil, err
 	}
 
-	if inWhitelist {
-		return domain.IPInWhitelist, nil
-	}
+	return &listEntry{network: *network, expiresAt: subnet.ExpiresAt, trust: subnet.Trust}, nil
+}
+
+func (e *listEntry) Network() net.IPNet {
+	return e.network
+}
 
-	return domain.IPNotInList, nil
+func (e *listEntry) expired(now time.Time) bool {
+	return e.expiresAt != nil && !e.expiresAt.After(now)
 }
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// snapshotVersion - версия формата файла снимка. Снимки другой версии не загружаются.
+const snapshotVersion = 1
+
+var errInvalidSnapshot = errors.New("invalid IP lists snapshot")
+
+// ipListsSnapshot - снимок списков на диске. Checksum - SHA-256 от Lists в том виде, в каком они записаны в файл.
+type ipListsSnapshot struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Checksum  string          `json:"checksum"`
+	Lists     json.RawMessage `json:"lists"`
+}
+
+type snapshotLists struct {
+	Blacklist []snapshotSubnet `json:"blacklist"`
+	Whitelist []snapshotSubnet `json:"whitelist"`
+	Greylist  []snapshotSubnet `json:"greylist,omitempty"`
+}
+
+type snapshotSubnet struct {
+	CIDR      string     `json:"cidr"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Trust отсутствует в снимках, записанных до появления уровней доверия, что равносильно bypass_all.
+	Trust domain.TrustLevel `json:"trust,omitempty"`
+}
+
+// saveSnapshot записывает списки в файл снимка. Файл подменяется целиком,
+// чтобы при сбое во время записи остался предыдущий снимок.
+func (c *IPListsCache) saveSnapshot(blacklist, whitelist, greylist []domain.Subnet) error {
+	if c.snapshotPath == "" {
+		return nil
+	}
+
+	lists, err := json.Marshal(snapshotLists{
+		Blacklist: toSnapshotSubnets(blacklist),
+		Whitelist: toSnapshotSubnets(whitelist),
+		Greylist:  toSnapshotSubnets(greylist),
+	})
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.Sum256(lists)
+	data, err := json.Marshal(ipListsSnapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now(),
+		Checksum:  hex.EncodeToString(checksum[:]),
+		Lists:     lists,
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(c.snapshotPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), c.snapshotPath)
+}
+
+// loadSnapshot загружает списки из файла снимка, если списки ещё не загружены из базы.
+// Возвращает время создания снимка.
+func (c *IPListsCache) loadSnapshot() (time.Time, error) {
+	data, err := os.ReadFile(c.snapshotPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var snapshot ipListsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", errInvalidSnapshot, err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return time.Time{}, fmt.Errorf("%w: unsupported version %d", errInvalidSnapshot, snapshot.Version)
+	}
+
+	checksum := sha256.Sum256(snapshot.Lists)
+	if hex.EncodeToString(checksum[:]) != snapshot.Checksum {
+		return time.Time{}, fmt.Errorf("%w: checksum mismatch", errInvalidSnapshot)
+	}
+
+	var lists snapshotLists
+	if err := json.Unmarshal(snapshot.Lists, &lists); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", errInvalidSnapshot, err)
+	}
+
+	c.mu.RLock()
+	initialized := c.isInitialized
+	c.mu.RUnlock()
+	if initialized {
+		return snapshot.CreatedAt, nil
+	}
+
+	err = c.reload(
+		fromSnapshotSubnets(domain.Blacklist, lists.Blacklist),
+		fromSnapshotSubnets(domain.Whitelist, lists.Whitelist),
+		fromSnapshotSubnets(domain.Greylist, lists.Greylist),
+		0,
+	)
+
+	return snapshot.CreatedAt, err
+}
+
+func toSnapshotSubnets(subnets []domain.Subnet) []snapshotSubnet {
+	result := make([]snapshotSubnet, 0, len(subnets))
+	for _, subnet := range subnets {
+		result = append(result, snapshotSubnet{CIDR: subnet.CIDR, ExpiresAt: subnet.ExpiresAt, Trust: subnet.Trust})
+	}
+
+	return result
+}
+
+func fromSnapshotSubnets(listType domain.ListType, subnets []snapshotSubnet) []domain.Subnet {
+	result := make([]domain.Subnet, 0, len(subnets))
+	for _, subnet := range subnets {
+		result = append(result, domain.Subnet{
+			ListType:  listType,
+			CIDR:      subnet.CIDR,
+			ExpiresAt: subnet.ExpiresAt,
+			Trust:     subnet.Trust,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/gomonov/otus-go-project/internal/storage"
+)
+
+// RunListener применяет к кэшу изменения списков и правил, сделанные любым экземпляром сервиса,
+// пока не завершится контекст или слушатель. Перечитывание по CacheTTL остаётся страховкой.
+func (a *App) RunListener(ctx context.Context, listener storage.SubnetListener) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-listener.Changes():
+			if !ok {
+				a.logger.Warn("Subnet listener stopped, falling back to cache TTL")
+				return
+			}
+			a.applySubnetChange(change)
+		}
+	}
+}
+
+func (a *App) applySubnetChange(change domain.SubnetChange) {
+	switch change.Op {
+	case domain.SubnetResync:
+		a.logger.Info("Subnet listener reconnected or lists imported, IP lists cache will be reloaded")
+		a.cache.invalidate()
+		// За время разрыва могли потеряться и уведомления об изменении правил
+		a.reloadGeoRules()
+		a.reloadLoginRules()
+		return
+	case domain.GeoRulesChanged:
+		a.logger.Debug("Geo rules changed, reloading")
+		a.reloadGeoRules()
+		return
+	case domain.LoginRulesChanged:
+		a.logger.Debug("Login rules changed, reloading")
+		a.reloadLoginRules()
+		return
+	case domain.SubnetUpserted, domain.SubnetDeleted:
+	}
+
+	if err := a.cache.apply(change); err != nil {
+		a.logger.Error("Failed to apply subnet change",
+			"op", change.Op,
+			"list", change.Subnet.ListType,
+			"cidr", change.Subnet.CIDR,
+			"error", err.Error())
+		return
+	}
+
+	a.logger.Debug("Subnet change applied",
+		"op", change.Op,
+		"list", change.Subnet.ListType,
+		"cidr", change.Subnet.CIDR)
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// CreateLoginRule добавляет правило списка логинов и сразу применяет его на этом экземпляре.
+// Остальные экземпляры перечитают правила, получив уведомление об изменении.
+func (a *App) CreateLoginRule(rule *domain.LoginRule) error {
+	kind, pattern, err := domain.NormalizeLoginRule(rule.Kind, rule.Pattern)
+	if err != nil {
+		return err
+	}
+	rule.Kind, rule.Pattern = kind, pattern
+
+	a.logger.Info("Creating login rule: ", rule.String(), " for list: ", rule.ListType)
+	if err := a.storage.LoginRule().Create(rule); err != nil {
+		return err
+	}
+
+	a.reloadLoginRules()
+	return nil
+}
+
+func (a *App) DeleteLoginRule(listType domain.ListType, kind domain.LoginRuleKind, pattern string) error {
+	kind, pattern, err := domain.NormalizeLoginRule(kind, pattern)
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("Deleting login rule: ", kind, ":", pattern, " from list: ", listType)
+	if err := a.storage.LoginRule().Delete(listType, kind, pattern); err != nil {
+		return err
+	}
+
+	a.reloadLoginRules()
+	return nil
+}
+
+func (a *App) GetLoginRules(listType domain.ListType) ([]domain.LoginRule, error) {
+	rules, err := a.storage.LoginRule().GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]domain.LoginRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.ListType == listType {
+			filtered = append(filtered, rule)
+		}
+	}
+
+	return filtered, nil
+}
+
+// LoadLoginRules загружает списки логинов в кэш.
+func (a *App) LoadLoginRules() error {
+	rules, err := a.storage.LoginRule().GetAll()
+	if err != nil {
+		return err
+	}
+
+	if err := a.loginRules.reload(rules); err != nil {
+		return err
+	}
+	a.logger.Debug("Login rules cache reloaded", "count", len(rules))
+	return nil
+}
+
+func (a *App) reloadLoginRules() {
+	if err := a.LoadLoginRules(); err != nil {
+		a.logger.Error("Failed to reload login rules", "error", err.Error())
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sync"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// loginRulesCache хранит списки логинов. Точные правила ищутся по карте, шаблоны проверяются по очереди,
+// поэтому их не должно быть много. Правила перечитываются по уведомлению об их изменении (App.RunListener)
+// и App.RunCacheRefresher.
+type loginRulesCache struct {
+	mu       sync.RWMutex
+	exact    map[domain.ListType]map[string]domain.LoginRule
+	patterns []loginPattern
+}
+
+type loginPattern struct {
+	rule  domain.LoginRule
+	regex *regexp.Regexp
+}
+
+func (p loginPattern) match(login string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(login)
+	}
+
+	matched, _ := path.Match(p.rule.Pattern, login)
+	return matched
+}
+
+func newLoginRulesCache() *loginRulesCache {
+	return &loginRulesCache{exact: make(map[domain.ListType]map[string]domain.LoginRule)}
+}
+
+func (c *loginRulesCache) reload(rules []domain.LoginRule) error {
+	exact := make(map[domain.ListType]map[string]domain.LoginRule)
+	var patterns []loginPattern
+
+	for _, rule := range rules {
+		switch rule.Kind {
+		case domain.LoginRuleExact:
+			if exact[rule.ListType] == nil {
+				exact[rule.ListType] = make(map[string]domain.LoginRule)
+			}
+			exact[rule.ListType][rule.Pattern] = rule
+		case domain.LoginRuleGlob:
+			patterns = append(patterns, loginPattern{rule: rule})
+		case domain.LoginRuleRegex:
+			regex, err := domain.CompileLoginRegex(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid login rule %s: %w", rule.String(), err)
+			}
+			patterns = append(patterns, loginPattern{rule: rule, regex: regex})
+		default:
+			return fmt.Errorf("unknown login rule kind: %s", rule.Kind)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exact = exact
+	c.patterns = patterns
+
+	return nil
+}
+
+// matches возвращает правила, подходящие к логину: сначала чёрного списка, затем белого.
+func (c *loginRulesCache) matches(login string) []domain.LoginRule {
+	if login == "" {
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var matches []domain.LoginRule
+	for _, listType := range []domain.ListType{domain.Blacklist, domain.Whitelist} {
+		if rule, ok := c.exact[listType][login]; ok {
+			matches = append(matches, rule)
+		}
+		for _, pattern := range c.patterns {
+			if pattern.rule.ListType == listType && pattern.match(login) {
+				matches = append(matches, pattern.rule)
+			}
+		}
+	}
+
+	return matches
+}
+
+// loginStatus возвращает список, в который попал логин, и определившее это правило.
+// Как и у подсетей по умолчанию, чёрный список важнее белого.
+func loginStatus(matches []domain.LoginRule) (domain.ListType, domain.LoginRule, bool) {
+	if len(matches) == 0 {
+		return "", domain.LoginRule{}, false
+	}
+
+	return matches[0].ListType, matches[0], true
+}
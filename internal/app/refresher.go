@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// LoadIPLists загружает списки в кэш. Вызывается при старте, дальше списки перечитывает RunCacheRefresher.
+func (a *App) LoadIPLists() error {
+	version := a.cache.currentVersion()
+
+	blacklist, err := a.storage.Subnet().GetByListType(domain.Blacklist, domain.SubnetFilter{})
+	if err != nil {
+		return err
+	}
+
+	whitelist, err := a.storage.Subnet().GetByListType(domain.Whitelist, domain.SubnetFilter{})
+	if err != nil {
+		return err
+	}
+
+	greylist, err := a.storage.Subnet().GetByListType(domain.Greylist, domain.SubnetFilter{})
+	if err != nil {
+		return err
+	}
+
+	if err := a.cache.reload(blacklist, whitelist, greylist, version); err != nil {
+		return err
+	}
+
+	a.logger.Debug("IP lists cache reloaded",
+		"blacklist_count", len(blacklist),
+		"whitelist_count", len(whitelist),
+		"greylist_count", len(greylist))
+
+	if err := a.cache.saveSnapshot(blacklist, whitelist, greylist); err != nil {
+		a.logger.Warn("Failed to save IP lists snapshot", "error", err.Error())
+	}
+
+	return nil
+}
+
+// LoadSnapshot загружает списки из снимка на диске, чтобы отвечать на проверки, пока база недоступна.
+// Вызывается при старте до LoadIPLists. Возраст копии отсчитывается от загрузки снимка,
+// поэтому без базы снимок используется не дольше CacheConf.MaxStaleness.
+func (a *App) LoadSnapshot() error {
+	if a.cacheConf.SnapshotPath == "" {
+		return nil
+	}
+
+	createdAt, err := a.cache.loadSnapshot()
+	if errors.Is(err, os.ErrNotExist) {
+		a.logger.Info("IP lists snapshot not found", "path", a.cacheConf.SnapshotPath)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("IP lists loaded from snapshot",
+		"path", a.cacheConf.SnapshotPath,
+		"created_at", createdAt.Format(time.RFC3339))
+
+	return nil
+}
+
+// RunCacheRefresher перечитывает списки, правила по странам и ASN и списки логинов раз в CacheConf.TTL
+// и по запросу кэша, пока не завершится контекст.
+// Если база недоступна, продолжает отдаваться последняя загруженная копия, но не дольше CacheConf.MaxStaleness.
+func (a *App) RunCacheRefresher(ctx context.Context) {
+	ticker := time.NewTicker(a.cacheConf.TTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-a.cache.refresh:
+		}
+
+		if err := a.LoadIPLists(); err != nil {
+			a.logger.Error("Failed to reload IP lists, serving the last loaded copy",
+				"age", a.cache.age().Round(time.Second),
+				"error", err.Error())
+		}
+		if err := a.LoadGeoRules(); err != nil {
+			a.logger.Error("Failed to reload geo rules, serving the last loaded copy", "error", err.Error())
+		}
+		if err := a.LoadLoginRules(); err != nil {
+			a.logger.Error("Failed to reload login rules, serving the last loaded copy", "error", err.Error())
+		}
+	}
+}
+
+// CacheAge возвращает возраст загруженной копии списков.
+func (a *App) CacheAge() time.Duration {
+	return a.cache.age()
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// RunExpirySweeper периодически удаляет подсети с истёкшим сроком действия, пока не завершится контекст.
+// Кэш не учитывает такие подсети и до удаления.
+func (a *App) RunExpirySweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.sweepExpiredSubnets()
+		}
+	}
+}
+
+func (a *App) sweepExpiredSubnets() {
+	deleted, err := a.storage.Subnet().DeleteExpired(time.Now())
+	if err != nil {
+		a.logger.Error("Failed to delete expired subnets", "error", err.Error())
+		return
+	}
+
+	if deleted > 0 {
+		a.logger.Info("Expired subnets deleted", "count", deleted)
+	}
+}
@@ -20,8 +20,22 @@ func Execute(baseURL string, args []string) error {
 		return HandleBlacklistCommand(client, commandArgs)
 	case "whitelist":
 		return HandleWhitelistCommand(client, commandArgs)
+	case "greylist":
+		return HandleGreylistCommand(client, commandArgs)
+	case "explain":
+		return HandleExplainCommand(client, commandArgs)
+	case "conflicts":
+		return HandleConflictsCommand(client, commandArgs)
+	case "geo":
+		return HandleGeoCommand(client, commandArgs)
+	case "logins":
+		return HandleLoginsCommand(client, commandArgs)
+	case "feeds":
+		return HandleFeedsCommand(client, commandArgs)
 	case "reset":
 		return HandleResetCommand(client, commandArgs)
+	case "buckets":
+		return HandleBucketsCommand(client, commandArgs)
 	case "help", "--help", "-h":
 		printUsage()
 		return nil
@@ -41,25 +55,118 @@ Flags:
 
 Commands:
   blacklist
-    add <cidr>     Add subnet to blacklist
-    remove <cidr>  Remove subnet from blacklist
-    list           List all subnets in blacklist
+    add <cidr|range> [--ttl <duration>] [--comment <text>] [--tag <tag>]... [--force]
+                   Add subnet to blacklist, temporarily if --ttl is set.
+                   A range (start-end or 203.0.113.*) is added as the minimal set
+                   of covering subnets in one transaction.
+                   Overlapping subnets are refused unless --force is set
+    remove <cidr|range>
+                   Remove subnet, or all subnets added for the range, from blacklist
+    list [--tag <tag>] [--contains <ip>] [--limit <n>] [--page <cursor>]
+                   List subnets in blacklist by pages of --limit (default 100),
+                   only tagged ones if --tag is set, only containing the IP if
+                   --contains is set. --page continues from the previous page
+    import <file> [--format text|csv|json] [--replace] [--dry-run]
+                   Import subnets in one transaction. The format is taken from the
+                   file extension unless --format is set. --replace removes subnets
+                   missing from the file, --dry-run only shows the changes
+    export [--format text|csv|json] [--output <file>]
+                   Export subnets in a format accepted by import (default text)
 
   whitelist
-    add <cidr>     Add subnet to whitelist
-    remove <cidr>  Remove subnet from whitelist
-    list           List all subnets in whitelist
+    add <cidr|range> [--ttl <duration>] [--comment <text>] [--tag <tag>]... [--trust <level>] [--force]
+                   Add subnet or range to whitelist, temporarily if --ttl is set.
+                   Overlapping subnets are refused unless --force is set.
+                   --trust bypass_all (default) skips all rate limits, bypass_ip
+                   skips only the IP bucket, relaxed multiplies limits by 10
+    remove <cidr|range>
+                   Remove subnet or range from whitelist
+    list [--tag <tag>] [--contains <ip>] [--limit <n>] [--page <cursor>]
+                   List subnets in whitelist, same as for blacklist
+    import <file> [--format text|csv|json] [--replace] [--dry-run]
+                   Import subnets in one transaction. The format is taken from the
+                   file extension unless --format is set. --replace removes subnets
+                   missing from the file, --dry-run only shows the changes
+    export [--format text|csv|json] [--output <file>]
+                   Export subnets in a format accepted by import (default text)
+
+  greylist
+    add <cidr|range> [--ttl <duration>] [--comment <text>] [--tag <tag>]... [--force]
+                   Add subnet or range to greylist: logins from it are not blocked but
+                   checked with stricter rate limits
+    remove <cidr|range>
+                   Remove subnet or range from greylist
+    list [--tag <tag>] [--contains <ip>] [--limit <n>] [--page <cursor>]
+                   List subnets in greylist, same as for blacklist
+    import <file> [--format text|csv|json] [--replace] [--dry-run]
+                   Import subnets in one transaction, same as for blacklist
+    export [--format text|csv|json] [--output <file>]
+                   Export subnets in a format accepted by import (default text)
+
+  geo blacklist|whitelist
+    add <country|asn> <value> [--comment <text>]
+                   Block logins from a country or ASN. Geo whitelist rules only
+                   exempt from geo blacklist rules, they do not skip rate limits.
+                   Subnet lists are checked first
+    remove <country|asn> <value>
+                   Remove country or ASN rule
+    list           List country and ASN rules
+
+  logins blacklist|whitelist
+    add <login> [--glob|--regex] [--comment <text>]
+                   Blacklisted logins are refused from any IP. Whitelisted logins
+                   (service accounts) skip the login bucket, other limits apply.
+                   The pattern is an exact login unless --glob or --regex is set
+    remove <login> [--glob|--regex]
+                   Remove login rule
+    list           List login rules
+
+  conflicts        Show overlapping subnets within and across lists
+
+  feeds            Show threat feed synchronisation status
 
   reset [--login <login>] [--ip <ip>]
-                   Reset rate limit buckets
+                   Reset rate limit buckets and lift bans
+
+  buckets
+    show [--login <login>] [--password-hash <hash>] [--ip <ip>]
+                   Show rate limit buckets and bans without consuming tokens
+
+  explain [--ip <ip>] [--login <login>]
+                   Show list entries containing the IP, the winning one (*),
+                   and login and IP buckets without consuming tokens
 
   help             Show this help message
 
 Examples:
   cli -url http://localhost:8080 blacklist add 192.168.1.0/24
+  cli blacklist add 203.0.113.0/24 --ttl 24h
+  cli blacklist add 198.51.100.0/24 --comment "credential stuffing, ticket 4321" --tag abuse --tag botnet
+  cli blacklist add 203.0.113.17-203.0.113.90 --comment "abuse report 1234"
+  cli blacklist add 198.51.100.*
+  cli blacklist remove 203.0.113.17-203.0.113.90
   cli blacklist list
+  cli blacklist list --tag abuse
+  cli blacklist list --contains 203.0.113.40
+  cli blacklist list --limit 50 --page MjAzLjAuMTEzLjAvMjQ
+  cli blacklist import drop.txt --replace --dry-run
+  cli blacklist export --format csv --output blacklist.csv
   cli whitelist add 10.0.0.0/8
+  cli whitelist add 203.0.113.7          (a bare IP is stored as /32 or /128)
+  cli whitelist add 198.51.100.0/28 --trust bypass_ip --comment "office NAT"
+  cli blacklist add 10.20.30.0/24 --force
+  cli greylist add 192.0.2.0/24 --comment "VPN provider" --tag vpn
+  cli geo blacklist add country KP --comment "no customers there"
+  cli geo blacklist add asn AS14061
+  cli geo whitelist add asn 13335
+  cli logins blacklist add admin --comment "credential stuffing"
+  cli logins whitelist add 'probe-*' --glob --comment "monitoring"
+  cli logins whitelist add '^svc-[a-z]+$' --regex
+  cli conflicts
+  cli feeds
   cli reset --login user1
   cli reset --ip 192.168.1.100
-  cli reset --login user1 --ip 192.168.1.100`)
+  cli reset --login user1 --ip 192.168.1.100
+  cli buckets show --login user1 --ip 1.2.3.4
+  cli explain --ip 10.20.30.40 --login user1`)
 }
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,21 +16,80 @@ import (
 )
 
 type CreateSubnetRequest struct {
-	CIDR string `json:"cidr"`
+	CIDR      string   `json:"cidr,omitempty"`
+	Range     string   `json:"range,omitempty"`
+	TTL       string   `json:"ttl,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+	CreatedBy string   `json:"createdBy,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Trust     string   `json:"trust,omitempty"`
 }
 
 type DeleteSubnetRequest struct {
-	CIDR string `json:"cidr"`
+	CIDR  string `json:"cidr,omitempty"`
+	Range string `json:"range,omitempty"`
+}
+
+type DeleteSubnetResponse struct {
+	Message string `json:"message"`
+	CIDR    string `json:"cidr"`
 }
 
 type SubnetsListResponse struct {
-	Subnets []SubnetResponse `json:"subnets"`
-	Count   int              `json:"count"`
+	Subnets    []SubnetResponse `json:"subnets"`
+	Count      int              `json:"count"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// ListOptions - фильтры и страница списка подсетей. Пустые поля не передаются.
+type ListOptions struct {
+	Tag      string
+	Contains string
+	Limit    int
+	// Page - курсор страницы из NextCursor предыдущего ответа.
+	Page string
 }
 
 type SubnetResponse struct {
-	ListType domain.ListType `json:"listType"`
-	CIDR     string          `json:"cidr"`
+	ListType  domain.ListType   `json:"listType"`
+	CIDR      string            `json:"cidr"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+	Comment   string            `json:"comment,omitempty"`
+	CreatedBy string            `json:"createdBy,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Tags      []string          `json:"tags"`
+	Source    string            `json:"source,omitempty"`
+	Trust     string            `json:"trust,omitempty"`
+	Warnings  []OverlapResponse `json:"warnings,omitempty"`
+}
+
+type SubnetRangeResponse struct {
+	Range    string            `json:"range"`
+	Subnets  []SubnetResponse  `json:"subnets"`
+	Count    int               `json:"count"`
+	Warnings []OverlapResponse `json:"warnings,omitempty"`
+}
+
+type OverlapResponse struct {
+	Kind  string         `json:"kind"`
+	Other SubnetResponse `json:"other"`
+}
+
+type ConflictResponse struct {
+	Kind   string         `json:"kind"`
+	Subnet SubnetResponse `json:"subnet"`
+	Other  SubnetResponse `json:"other"`
+}
+
+type ConflictsResponse struct {
+	Conflicts []ConflictResponse `json:"conflicts"`
+	Count     int                `json:"count"`
+}
+
+type FeedsResponse struct {
+	Feeds []domain.FeedStatus `json:"feeds"`
+	Count int                 `json:"count"`
 }
 
 type ResetBucketsRequest struct {
@@ -40,8 +101,125 @@ type ResetBucketsResponse struct {
 	Reset bool `json:"reset"`
 }
 
+type BucketState struct {
+	Bucket    string  `json:"bucket"`
+	Algorithm string  `json:"algorithm"`
+	Available float64 `json:"available"`
+	Capacity  int     `json:"capacity"`
+	Window    int     `json:"window"`
+	FullIn    int     `json:"fullIn"`
+}
+
+type Ban struct {
+	Bucket    string    `json:"bucket"`
+	Level     int       `json:"level"`
+	Until     time.Time `json:"until"`
+	ExpiresIn int       `json:"expiresIn"`
+}
+
+type BucketsResponse struct {
+	Buckets []BucketState `json:"buckets"`
+	Bans    []Ban         `json:"bans"`
+}
+
+type ListMatch struct {
+	ListType  string     `json:"listType"`
+	CIDR      string     `json:"cidr"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Trust     string     `json:"trust,omitempty"`
+	Winner    bool       `json:"winner"`
+}
+
+type GeoRuleRequest struct {
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	Comment   string `json:"comment,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+}
+
+type GeoRuleResponse struct {
+	ListType  string    `json:"listType"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Rule      string    `json:"rule"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type GeoRulesListResponse struct {
+	Rules []GeoRuleResponse `json:"rules"`
+	Count int               `json:"count"`
+}
+
+type LoginRuleRequest struct {
+	Kind      string `json:"kind"`
+	Pattern   string `json:"pattern"`
+	Comment   string `json:"comment,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+}
+
+type LoginRuleResponse struct {
+	ListType  string    `json:"listType"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	Rule      string    `json:"rule"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type LoginRulesListResponse struct {
+	Rules []LoginRuleResponse `json:"rules"`
+	Count int                 `json:"count"`
+}
+
+type GeoInfo struct {
+	Country string `json:"country,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"asOrg,omitempty"`
+}
+
+type GeoMatch struct {
+	ListType string `json:"listType"`
+	Rule     string `json:"rule"`
+	Winner   bool   `json:"winner"`
+}
+
+type ExplainResponse struct {
+	IP         string        `json:"ip,omitempty"`
+	Login      string        `json:"login,omitempty"`
+	Precedence string        `json:"precedence,omitempty"`
+	Matches    []ListMatch   `json:"matches"`
+	ListStatus string        `json:"listStatus,omitempty"`
+	Geo        *GeoInfo      `json:"geo,omitempty"`
+	GeoRules   []GeoMatch    `json:"geoRules,omitempty"`
+	LoginRules []GeoMatch    `json:"loginRules,omitempty"`
+	ListsAge   int           `json:"listsAge"`
+	Buckets    []BucketState `json:"buckets"`
+	Bans       []Ban         `json:"bans"`
+}
+
+type ImportRequest struct {
+	Data      []byte
+	Format    string
+	Replace   bool
+	DryRun    bool
+	CreatedBy string
+}
+
+type ImportResponse struct {
+	Mode      string   `json:"mode"`
+	DryRun    bool     `json:"dryRun"`
+	Added     []string `json:"added"`
+	Updated   []string `json:"updated"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error    string            `json:"error"`
+	Overlaps []OverlapResponse `json:"overlaps,omitempty"`
 }
 
 type Client struct {
@@ -59,16 +237,26 @@ func NewClient(baseURL string) *Client {
 }
 
 func (c *Client) makeRequest(method, path string, body interface{}) ([]byte, error) {
+	if body == nil {
+		return c.makeRawRequest(method, path, "", nil)
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	return c.makeRawRequest(method, path, "application/json", jsonData)
+}
+
+// makeRawRequest отправляет тело как есть, без перевода в JSON.
+func (c *Client) makeRawRequest(method, path, contentType string, body []byte) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	var reqBody io.Reader
 	if body != nil {
-		jsonData, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		reqBody = bytes.NewBuffer(jsonData)
+		reqBody = bytes.NewReader(body)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
@@ -76,8 +264,8 @@ func (c *Client) makeRequest(method, path string, body interface{}) ([]byte, err
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
 	resp, err := c.client.Do(req)
@@ -94,7 +282,11 @@ func (c *Client) makeRequest(method, path string, body interface{}) ([]byte, err
 	if resp.StatusCode >= 400 {
 		var errorResp ErrorResponse
 		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != "" {
-			return nil, fmt.Errorf("server error (%d): %s", resp.StatusCode, errorResp.Error)
+			message := errorResp.Error
+			for _, overlap := range errorResp.Overlaps {
+				message += fmt.Sprintf("\n  - %s with %s %s", overlap.Kind, overlap.Other.ListType, overlap.Other.CIDR)
+			}
+			return nil, fmt.Errorf("server error (%d): %s", resp.StatusCode, message)
 		}
 		return nil, fmt.Errorf("server error (%d): %s", resp.StatusCode, string(respBody))
 	}
@@ -102,20 +294,100 @@ func (c *Client) makeRequest(method, path string, body interface{}) ([]byte, err
 	return respBody, nil
 }
 
-func (c *Client) AddToBlacklist(cidr string) error {
-	req := CreateSubnetRequest{CIDR: cidr}
-	_, err := c.makeRequest("POST", "/blacklist", req)
-	return err
+// AddToBlacklist добавляет подсеть. С force подсеть добавляется, даже если пересекается с другими.
+func (c *Client) AddToBlacklist(req CreateSubnetRequest, force bool) (*SubnetResponse, error) {
+	return c.addSubnet("/blacklist", req, force)
 }
 
-func (c *Client) RemoveFromBlacklist(cidr string) error {
-	req := DeleteSubnetRequest{CIDR: cidr}
-	_, err := c.makeRequest("DELETE", "/blacklist", req)
-	return err
+// RemoveFromBlacklist удаляет подсеть и возвращает её в том виде, в каком её хранит сервис.
+func (c *Client) RemoveFromBlacklist(cidr string) (string, error) {
+	return c.removeSubnet("/blacklist", cidr)
 }
 
-func (c *Client) GetBlacklist() (*SubnetsListResponse, error) {
-	respBody, err := c.makeRequest("GET", "/blacklist", nil)
+// AddRangeToBlacklist добавляет подсети, покрывающие диапазон "start-end" или "203.0.113.*".
+func (c *Client) AddRangeToBlacklist(req CreateSubnetRequest, force bool) (*SubnetRangeResponse, error) {
+	return c.addRange("/blacklist", req, force)
+}
+
+// RemoveRangeFromBlacklist удаляет подсети, которыми был добавлен диапазон.
+func (c *Client) RemoveRangeFromBlacklist(ipRange string) (*SubnetRangeResponse, error) {
+	return c.removeRange("/blacklist", ipRange)
+}
+
+// GetBlacklist возвращает страницу подсетей списка, отобранных по opts.
+func (c *Client) GetBlacklist(opts ListOptions) (*SubnetsListResponse, error) {
+	return c.getSubnets("/blacklist", opts)
+}
+
+// AddToWhitelist добавляет подсеть. С force подсеть добавляется, даже если пересекается с другими.
+func (c *Client) AddToWhitelist(req CreateSubnetRequest, force bool) (*SubnetResponse, error) {
+	return c.addSubnet("/whitelist", req, force)
+}
+
+// RemoveFromWhitelist удаляет подсеть и возвращает её в том виде, в каком её хранит сервис.
+func (c *Client) RemoveFromWhitelist(cidr string) (string, error) {
+	return c.removeSubnet("/whitelist", cidr)
+}
+
+// AddRangeToWhitelist добавляет подсети, покрывающие диапазон "start-end" или "203.0.113.*".
+func (c *Client) AddRangeToWhitelist(req CreateSubnetRequest, force bool) (*SubnetRangeResponse, error) {
+	return c.addRange("/whitelist", req, force)
+}
+
+// RemoveRangeFromWhitelist удаляет подсети, которыми был добавлен диапазон.
+func (c *Client) RemoveRangeFromWhitelist(ipRange string) (*SubnetRangeResponse, error) {
+	return c.removeRange("/whitelist", ipRange)
+}
+
+// GetWhitelist возвращает страницу подсетей списка, отобранных по opts.
+func (c *Client) GetWhitelist(opts ListOptions) (*SubnetsListResponse, error) {
+	return c.getSubnets("/whitelist", opts)
+}
+
+// AddToGreylist добавляет подсеть. С force подсеть добавляется, даже если пересекается с другими.
+func (c *Client) AddToGreylist(req CreateSubnetRequest, force bool) (*SubnetResponse, error) {
+	return c.addSubnet("/greylist", req, force)
+}
+
+// RemoveFromGreylist удаляет подсеть и возвращает её в том виде, в каком её хранит сервис.
+func (c *Client) RemoveFromGreylist(cidr string) (string, error) {
+	return c.removeSubnet("/greylist", cidr)
+}
+
+// AddRangeToGreylist добавляет подсети, покрывающие диапазон "start-end" или "203.0.113.*".
+func (c *Client) AddRangeToGreylist(req CreateSubnetRequest, force bool) (*SubnetRangeResponse, error) {
+	return c.addRange("/greylist", req, force)
+}
+
+// RemoveRangeFromGreylist удаляет подсети, которыми был добавлен диапазон.
+func (c *Client) RemoveRangeFromGreylist(ipRange string) (*SubnetRangeResponse, error) {
+	return c.removeRange("/greylist", ipRange)
+}
+
+// GetGreylist возвращает страницу подсетей списка, отобранных по opts.
+func (c *Client) GetGreylist(opts ListOptions) (*SubnetsListResponse, error) {
+	return c.getSubnets("/greylist", opts)
+}
+
+func (c *Client) getSubnets(path string, opts ListOptions) (*SubnetsListResponse, error) {
+	query := url.Values{}
+	if opts.Tag != "" {
+		query.Set("tag", opts.Tag)
+	}
+	if opts.Contains != "" {
+		query.Set("contains", opts.Contains)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Page != "" {
+		query.Set("cursor", opts.Page)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	respBody, err := c.makeRequest("GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -128,25 +400,220 @@ func (c *Client) GetBlacklist() (*SubnetsListResponse, error) {
 	return &response, nil
 }
 
-func (c *Client) AddToWhitelist(cidr string) error {
-	req := CreateSubnetRequest{CIDR: cidr}
-	_, err := c.makeRequest("POST", "/whitelist", req)
+func (c *Client) addSubnet(path string, req CreateSubnetRequest, force bool) (*SubnetResponse, error) {
+	if force {
+		path += "?force=true"
+	}
+
+	respBody, err := c.makeRequest("POST", path, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response SubnetResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *Client) addRange(path string, req CreateSubnetRequest, force bool) (*SubnetRangeResponse, error) {
+	if force {
+		path += "?force=true"
+	}
+
+	respBody, err := c.makeRequest("POST", path, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response SubnetRangeResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *Client) removeRange(path, ipRange string) (*SubnetRangeResponse, error) {
+	respBody, err := c.makeRequest("DELETE", path, DeleteSubnetRequest{Range: ipRange})
+	if err != nil {
+		return nil, err
+	}
+
+	var response SubnetRangeResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *Client) removeSubnet(path, cidr string) (string, error) {
+	respBody, err := c.makeRequest("DELETE", path, DeleteSubnetRequest{CIDR: cidr})
+	if err != nil {
+		return "", err
+	}
+
+	var response DeleteSubnetResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return response.CIDR, nil
+}
+
+// ImportBlacklist загружает файл со списком подсетей одной транзакцией.
+func (c *Client) ImportBlacklist(req ImportRequest) (*ImportResponse, error) {
+	return c.importSubnets("/blacklist/import", req)
+}
+
+// ImportWhitelist загружает файл со списком подсетей одной транзакцией.
+func (c *Client) ImportWhitelist(req ImportRequest) (*ImportResponse, error) {
+	return c.importSubnets("/whitelist/import", req)
+}
+
+// ImportGreylist загружает файл со списком подсетей одной транзакцией.
+func (c *Client) ImportGreylist(req ImportRequest) (*ImportResponse, error) {
+	return c.importSubnets("/greylist/import", req)
+}
+
+// ExportBlacklist возвращает список в формате text, csv или json, который принимает импорт.
+func (c *Client) ExportBlacklist(format string) ([]byte, error) {
+	return c.makeRequest("GET", "/blacklist/export?"+url.Values{"format": {format}}.Encode(), nil)
+}
+
+// ExportWhitelist возвращает список в формате text, csv или json, который принимает импорт.
+func (c *Client) ExportWhitelist(format string) ([]byte, error) {
+	return c.makeRequest("GET", "/whitelist/export?"+url.Values{"format": {format}}.Encode(), nil)
+}
+
+// ExportGreylist возвращает список в формате text, csv или json, который принимает импорт.
+func (c *Client) ExportGreylist(format string) ([]byte, error) {
+	return c.makeRequest("GET", "/greylist/export?"+url.Values{"format": {format}}.Encode(), nil)
+}
+
+func (c *Client) importSubnets(path string, req ImportRequest) (*ImportResponse, error) {
+	params := url.Values{"format": {req.Format}}
+	if req.Replace {
+		params.Set("mode", "replace")
+	}
+	if req.DryRun {
+		params.Set("dry_run", "true")
+	}
+	if req.CreatedBy != "" {
+		params.Set("created_by", req.CreatedBy)
+	}
+
+	respBody, err := c.makeRawRequest("POST", path+"?"+params.Encode(), importContentTypes[req.Format], req.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ImportResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+var importContentTypes = map[string]string{
+	"text": "text/plain",
+	"csv":  "text/csv",
+	"json": "application/json",
+}
+
+func (c *Client) GetFeeds() (*FeedsResponse, error) {
+	respBody, err := c.makeRequest("GET", "/feeds", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response FeedsResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// AddGeoRule добавляет правило по стране или ASN в список listType ("blacklist" или "whitelist").
+func (c *Client) AddGeoRule(listType string, req GeoRuleRequest) (*GeoRuleResponse, error) {
+	respBody, err := c.makeRequest("POST", "/geo/"+listType, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response GeoRuleResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *Client) RemoveGeoRule(listType, kind, value string) error {
+	_, err := c.makeRequest("DELETE", "/geo/"+listType, GeoRuleRequest{Kind: kind, Value: value})
 	return err
 }
 
-func (c *Client) RemoveFromWhitelist(cidr string) error {
-	req := DeleteSubnetRequest{CIDR: cidr}
-	_, err := c.makeRequest("DELETE", "/whitelist", req)
+func (c *Client) GetGeoRules(listType string) (*GeoRulesListResponse, error) {
+	respBody, err := c.makeRequest("GET", "/geo/"+listType, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response GeoRulesListResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// AddLoginRule добавляет правило в список логинов listType ("blacklist" или "whitelist").
+func (c *Client) AddLoginRule(listType string, req LoginRuleRequest) (*LoginRuleResponse, error) {
+	respBody, err := c.makeRequest("POST", "/logins/"+listType, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response LoginRuleResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *Client) RemoveLoginRule(listType, kind, pattern string) error {
+	_, err := c.makeRequest("DELETE", "/logins/"+listType, LoginRuleRequest{Kind: kind, Pattern: pattern})
 	return err
 }
 
-func (c *Client) GetWhitelist() (*SubnetsListResponse, error) {
-	respBody, err := c.makeRequest("GET", "/whitelist", nil)
+func (c *Client) GetLoginRules(listType string) (*LoginRulesListResponse, error) {
+	respBody, err := c.makeRequest("GET", "/logins/"+listType, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	var response SubnetsListResponse
+	var response LoginRulesListResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *Client) GetConflicts() (*ConflictsResponse, error) {
+	respBody, err := c.makeRequest("GET", "/lists/conflicts", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ConflictsResponse
 	if err := json.Unmarshal(respBody, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
@@ -172,3 +639,50 @@ func (c *Client) ResetBuckets(login, ip string) (*ResetBucketsResponse, error) {
 
 	return &response, nil
 }
+
+func (c *Client) GetBuckets(login, passwordHash, ip string) (*BucketsResponse, error) {
+	query := url.Values{}
+	if login != "" {
+		query.Set("login", login)
+	}
+	if passwordHash != "" {
+		query.Set("password_hash", passwordHash)
+	}
+	if ip != "" {
+		query.Set("ip", ip)
+	}
+
+	respBody, err := c.makeRequest("GET", "/buckets?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response BucketsResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+func (c *Client) Explain(ip, login string) (*ExplainResponse, error) {
+	query := url.Values{}
+	if ip != "" {
+		query.Set("ip", ip)
+	}
+	if login != "" {
+		query.Set("login", login)
+	}
+
+	respBody, err := c.makeRequest("GET", "/explain?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ExplainResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
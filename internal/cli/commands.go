@@ -2,6 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func HandleBlacklistCommand(client *Client, args []string) error {
@@ -10,7 +16,11 @@ func HandleBlacklistCommand(client *Client, args []string) error {
 		"blacklist",
 		client.AddToBlacklist,
 		client.RemoveFromBlacklist,
+		client.AddRangeToBlacklist,
+		client.RemoveRangeFromBlacklist,
 		client.GetBlacklist,
+		client.ImportBlacklist,
+		client.ExportBlacklist,
 	)
 }
 
@@ -20,52 +30,149 @@ func HandleWhitelistCommand(client *Client, args []string) error {
 		"whitelist",
 		client.AddToWhitelist,
 		client.RemoveFromWhitelist,
+		client.AddRangeToWhitelist,
+		client.RemoveRangeFromWhitelist,
 		client.GetWhitelist,
+		client.ImportWhitelist,
+		client.ExportWhitelist,
+	)
+}
+
+func HandleGreylistCommand(client *Client, args []string) error {
+	return handleListCommand(
+		args,
+		"greylist",
+		client.AddToGreylist,
+		client.RemoveFromGreylist,
+		client.AddRangeToGreylist,
+		client.RemoveRangeFromGreylist,
+		client.GetGreylist,
+		client.ImportGreylist,
+		client.ExportGreylist,
 	)
 }
 
 func handleListCommand(
 	args []string,
 	listType string,
-	addFunc func(string) error,
-	removeFunc func(string) error,
-	getFunc func() (*SubnetsListResponse, error),
+	addFunc func(CreateSubnetRequest, bool) (*SubnetResponse, error),
+	removeFunc func(string) (string, error),
+	addRangeFunc func(CreateSubnetRequest, bool) (*SubnetRangeResponse, error),
+	removeRangeFunc func(string) (*SubnetRangeResponse, error),
+	getFunc func(ListOptions) (*SubnetsListResponse, error),
+	importFunc func(ImportRequest) (*ImportResponse, error),
+	exportFunc func(format string) ([]byte, error),
 ) error {
 	if len(args) < 1 {
-		return fmt.Errorf("%s command requires subcommand: add, remove, list", listType)
+		return fmt.Errorf("%s command requires subcommand: add, remove, list, import, export", listType)
 	}
 
 	subcommand := args[0]
 	switch subcommand {
 	case "add":
 		if len(args) < 2 {
-			return fmt.Errorf("%s add requires CIDR argument", listType)
+			return fmt.Errorf("%s add requires CIDR or range argument", listType)
 		}
-		if err := addFunc(args[1]); err != nil {
+		req, ttl, force, err := parseAddFlags(args[2:])
+		if err != nil {
 			return err
 		}
-		fmt.Printf("Added %s to %s\n", args[1], listType)
+		req.CreatedBy = currentUser()
+		if isIPRange(args[1]) {
+			req.Range = args[1]
+			response, err := addRangeFunc(req, force)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Added %s to %s as %d subnets:\n", response.Range, listType, response.Count)
+			printRange(response)
+			return nil
+		}
+		req.CIDR = args[1]
+		response, err := addFunc(req, force)
+		if err != nil {
+			return err
+		}
+		if ttl > 0 {
+			fmt.Printf("Added %s to %s for %s\n", response.CIDR, listType, ttl)
+		} else {
+			fmt.Printf("Added %s to %s\n", response.CIDR, listType)
+		}
+		for _, warning := range response.Warnings {
+			fmt.Printf("Warning: %s with %s %s\n", warning.Kind, warning.Other.ListType, warning.Other.CIDR)
+		}
 		return nil
 
 	case "remove":
 		if len(args) < 2 {
-			return fmt.Errorf("%s remove requires CIDR argument", listType)
+			return fmt.Errorf("%s remove requires CIDR or range argument", listType)
+		}
+		if isIPRange(args[1]) {
+			response, err := removeRangeFunc(args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s from %s, %d subnets:\n", response.Range, listType, response.Count)
+			printRange(response)
+			return nil
 		}
-		if err := removeFunc(args[1]); err != nil {
+		cidr, err := removeFunc(args[1])
+		if err != nil {
 			return err
 		}
-		fmt.Printf("Removed %s from %s\n", args[1], listType)
+		fmt.Printf("Removed %s from %s\n", cidr, listType)
 		return nil
 
 	case "list":
-		response, err := getFunc()
+		opts, err := parseListFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		response, err := getFunc(opts)
 		if err != nil {
 			return err
 		}
 		fmt.Printf("%s (%d subnets):\n", listType, response.Count)
 		for _, subnet := range response.Subnets {
-			fmt.Printf("  - %s\n", subnet.CIDR)
+			printSubnet(subnet)
+		}
+		if response.NextCursor != "" {
+			fmt.Printf("More subnets, repeat with the same flags and --page %s\n", response.NextCursor)
+		}
+		return nil
+
+	case "import":
+		if len(args) < 2 {
+			return fmt.Errorf("%s import requires file argument", listType)
+		}
+		req, err := parseImportFlags(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		response, err := importFunc(req)
+		if err != nil {
+			return err
+		}
+		printImportResult(listType, response)
+		return nil
+
+	case "export":
+		format, output, err := parseExportFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		data, err := exportFunc(format)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			_, err = os.Stdout.Write(data)
+			return err
 		}
+		if err := os.WriteFile(output, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", output, err)
+		}
+		fmt.Printf("Exported %s to %s\n", listType, output)
 		return nil
 
 	default:
@@ -73,6 +180,410 @@ func handleListCommand(
 	}
 }
 
+// parseImportFlags читает файл импорта. Без --format формат определяется по расширению файла.
+func parseImportFlags(file string, args []string) (ImportRequest, error) {
+	req := ImportRequest{CreatedBy: currentUser()}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format":
+			if i+1 >= len(args) {
+				return req, fmt.Errorf("--format requires a value")
+			}
+			req.Format = args[i+1]
+			i++
+		case "--replace":
+			req.Replace = true
+		case "--dry-run", "-n":
+			req.DryRun = true
+		default:
+			return req, fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+
+	if req.Format == "" {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".csv":
+			req.Format = "csv"
+		case ".json":
+			req.Format = "json"
+		default:
+			req.Format = "text"
+		}
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return req, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	req.Data = data
+
+	return req, nil
+}
+
+func parseExportFlags(args []string) (string, string, error) {
+	format := "text"
+	var output string
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--format":
+			if i+1 >= len(args) {
+				return "", "", fmt.Errorf("--format requires a value")
+			}
+			format = args[i+1]
+			i++
+		case "--output", "-o":
+			if i+1 >= len(args) {
+				return "", "", fmt.Errorf("--output requires a value")
+			}
+			output = args[i+1]
+			i++
+		default:
+			return "", "", fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+
+	return format, output, nil
+}
+
+func printImportResult(listType string, response *ImportResponse) {
+	if response.DryRun {
+		fmt.Printf("Dry run, %s is not changed (mode %s):\n", listType, response.Mode)
+	} else {
+		fmt.Printf("Imported to %s (mode %s):\n", listType, response.Mode)
+	}
+	fmt.Printf("  added %d, updated %d, removed %d, unchanged %d\n",
+		len(response.Added), len(response.Updated), len(response.Removed), response.Unchanged)
+
+	for _, cidr := range response.Added {
+		fmt.Printf("  + %s\n", cidr)
+	}
+	for _, cidr := range response.Updated {
+		fmt.Printf("  ~ %s\n", cidr)
+	}
+	for _, cidr := range response.Removed {
+		fmt.Printf("  - %s\n", cidr)
+	}
+}
+
+// isIPRange отличает диапазон "start-end" или "203.0.113.*" от подсети: в CIDR нет ни дефиса, ни звёздочки.
+func isIPRange(arg string) bool {
+	return strings.ContainsAny(arg, "-*")
+}
+
+func printRange(response *SubnetRangeResponse) {
+	for _, subnet := range response.Subnets {
+		fmt.Printf("  - %s\n", subnet.CIDR)
+	}
+	for _, warning := range response.Warnings {
+		fmt.Printf("Warning: %s with %s %s\n", warning.Kind, warning.Other.ListType, warning.Other.CIDR)
+	}
+}
+
+func parseAddFlags(args []string) (CreateSubnetRequest, time.Duration, bool, error) {
+	var req CreateSubnetRequest
+	var ttl time.Duration
+	var force bool
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--ttl", "-t":
+			if i+1 >= len(args) {
+				return req, 0, false, fmt.Errorf("--ttl requires a value")
+			}
+			parsed, err := time.ParseDuration(args[i+1])
+			if err != nil || parsed <= 0 {
+				return req, 0, false, fmt.Errorf("invalid --ttl value: %s", args[i+1])
+			}
+			ttl = parsed
+			req.TTL = parsed.String()
+			i++
+		case "--comment", "-c":
+			if i+1 >= len(args) {
+				return req, 0, false, fmt.Errorf("--comment requires a value")
+			}
+			req.Comment = args[i+1]
+			i++
+		case "--tag":
+			if i+1 >= len(args) {
+				return req, 0, false, fmt.Errorf("--tag requires a value")
+			}
+			req.Tags = append(req.Tags, args[i+1])
+			i++
+		case "--trust":
+			if i+1 >= len(args) {
+				return req, 0, false, fmt.Errorf("--trust requires a value")
+			}
+			req.Trust = args[i+1]
+			i++
+		case "--force", "-f":
+			force = true
+		default:
+			return req, 0, false, fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+
+	return req, ttl, force, nil
+}
+
+func parseListFlags(args []string) (ListOptions, error) {
+	var opts ListOptions
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--tag":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("--tag requires a value")
+			}
+			opts.Tag = args[i+1]
+			i++
+		case "--contains":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("--contains requires a value")
+			}
+			opts.Contains = args[i+1]
+			i++
+		case "--limit", "-n":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("--limit requires a value")
+			}
+			limit, err := strconv.Atoi(args[i+1])
+			if err != nil || limit < 1 {
+				return opts, fmt.Errorf("invalid --limit value: %s", args[i+1])
+			}
+			opts.Limit = limit
+			i++
+		case "--page":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("--page requires a value")
+			}
+			opts.Page = args[i+1]
+			i++
+		default:
+			return opts, fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+
+	return opts, nil
+}
+
+func printSubnet(subnet SubnetResponse) {
+	fmt.Printf("  - %s", subnet.CIDR)
+	if len(subnet.Tags) > 0 {
+		fmt.Printf(" [%s]", strings.Join(subnet.Tags, ", "))
+	}
+	if subnet.ExpiresAt != nil {
+		fmt.Printf(" (expires %s)", subnet.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	if subnet.Trust != "" {
+		fmt.Printf(" (trust %s)", subnet.Trust)
+	}
+	fmt.Println()
+
+	if subnet.Comment != "" {
+		fmt.Printf("      %s\n", subnet.Comment)
+	}
+	if !subnet.CreatedAt.IsZero() {
+		fmt.Printf("      added %s", subnet.CreatedAt.Local().Format(time.RFC3339))
+		if subnet.CreatedBy != "" {
+			fmt.Printf(" by %s", subnet.CreatedBy)
+		}
+		if subnet.Source != "" {
+			fmt.Printf(", synced from %s", subnet.Source)
+		}
+		if subnet.UpdatedAt.After(subnet.CreatedAt) {
+			fmt.Printf(", updated %s", subnet.UpdatedAt.Local().Format(time.RFC3339))
+		}
+		fmt.Println()
+	}
+}
+
+// currentUser возвращает имя пользователя ОС, которое сервис сохраняет как автора записи.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+func HandleConflictsCommand(client *Client, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unknown flag: %s", args[0])
+	}
+
+	response, err := client.GetConflicts()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Conflicts (%d):\n", response.Count)
+	for _, conflict := range response.Conflicts {
+		fmt.Printf("  - %s: %s %s and %s %s\n", conflict.Kind,
+			conflict.Subnet.ListType, conflict.Subnet.CIDR, conflict.Other.ListType, conflict.Other.CIDR)
+	}
+	return nil
+}
+
+func HandleGeoCommand(client *Client, args []string) error {
+	if len(args) < 2 || (args[0] != "blacklist" && args[0] != "whitelist") {
+		return fmt.Errorf("geo command requires list and subcommand: geo blacklist|whitelist add|remove|list")
+	}
+
+	listType, subcommand := args[0], args[1]
+	switch subcommand {
+	case "add":
+		if len(args) < 4 {
+			return fmt.Errorf("geo %s add requires kind (country or asn) and value", listType)
+		}
+		req := GeoRuleRequest{Kind: args[2], Value: args[3], CreatedBy: currentUser()}
+		flags := args[4:]
+		for i := 0; i < len(flags); i++ {
+			switch flags[i] {
+			case "--comment", "-c":
+				if i+1 >= len(flags) {
+					return fmt.Errorf("--comment requires a value")
+				}
+				req.Comment = flags[i+1]
+				i++
+			default:
+				return fmt.Errorf("unknown flag: %s", flags[i])
+			}
+		}
+		response, err := client.AddGeoRule(listType, req)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added %s to geo %s\n", response.Rule, listType)
+		return nil
+
+	case "remove":
+		if len(args) < 4 {
+			return fmt.Errorf("geo %s remove requires kind (country or asn) and value", listType)
+		}
+		if err := client.RemoveGeoRule(listType, args[2], args[3]); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s:%s from geo %s\n", args[2], args[3], listType)
+		return nil
+
+	case "list":
+		response, err := client.GetGeoRules(listType)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("geo %s (%d rules):\n", listType, response.Count)
+		for _, rule := range response.Rules {
+			fmt.Printf("  - %s", rule.Rule)
+			if rule.Comment != "" {
+				fmt.Printf(" (%s)", rule.Comment)
+			}
+			fmt.Println()
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown geo %s subcommand: %s", listType, subcommand)
+	}
+}
+
+func HandleLoginsCommand(client *Client, args []string) error {
+	if len(args) < 2 || (args[0] != "blacklist" && args[0] != "whitelist") {
+		return fmt.Errorf("logins command requires list and subcommand: logins blacklist|whitelist add|remove|list")
+	}
+
+	listType, subcommand := args[0], args[1]
+	switch subcommand {
+	case "add", "remove":
+		if len(args) < 3 {
+			return fmt.Errorf("logins %s %s requires a login or pattern", listType, subcommand)
+		}
+		req := LoginRuleRequest{Kind: "exact", Pattern: args[2], CreatedBy: currentUser()}
+		flags := args[3:]
+		for i := 0; i < len(flags); i++ {
+			switch flags[i] {
+			case "--glob":
+				req.Kind = "glob"
+			case "--regex":
+				req.Kind = "regex"
+			case "--comment", "-c":
+				if subcommand != "add" {
+					return fmt.Errorf("unknown flag: %s", flags[i])
+				}
+				if i+1 >= len(flags) {
+					return fmt.Errorf("--comment requires a value")
+				}
+				req.Comment = flags[i+1]
+				i++
+			default:
+				return fmt.Errorf("unknown flag: %s", flags[i])
+			}
+		}
+
+		if subcommand == "remove" {
+			if err := client.RemoveLoginRule(listType, req.Kind, req.Pattern); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s:%s from logins %s\n", req.Kind, req.Pattern, listType)
+			return nil
+		}
+
+		response, err := client.AddLoginRule(listType, req)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added %s to logins %s\n", response.Rule, listType)
+		return nil
+
+	case "list":
+		response, err := client.GetLoginRules(listType)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("logins %s (%d rules):\n", listType, response.Count)
+		for _, rule := range response.Rules {
+			fmt.Printf("  - %s", rule.Rule)
+			if rule.Comment != "" {
+				fmt.Printf(" (%s)", rule.Comment)
+			}
+			fmt.Println()
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown logins %s subcommand: %s", listType, subcommand)
+	}
+}
+
+func HandleFeedsCommand(client *Client, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unknown flag: %s", args[0])
+	}
+
+	response, err := client.GetFeeds()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Feeds (%d):\n", response.Count)
+	for _, feed := range response.Feeds {
+		fmt.Printf("  - %s (%s, every %ds): %s\n", feed.Name, feed.Format, feed.Interval, feed.URL)
+		switch {
+		case feed.LastAttempt == nil:
+			fmt.Println("      not synced yet")
+		case feed.LastSync != nil:
+			fmt.Printf("      synced %s: %d entries (%d invalid lines skipped), +%d ~%d -%d\n",
+				feed.LastSync.Local().Format(time.RFC3339), feed.Entries, feed.Invalid,
+				feed.Added, feed.Updated, feed.Removed)
+		}
+		if feed.Error != "" {
+			fmt.Printf("      last attempt %s failed: %s\n", feed.LastAttempt.Local().Format(time.RFC3339), feed.Error)
+		}
+	}
+	return nil
+}
+
 func HandleResetCommand(client *Client, args []string) error {
 	var login, ip string
 
@@ -107,7 +618,7 @@ func HandleResetCommand(client *Client, args []string) error {
 	}
 
 	if response.Reset {
-		fmt.Printf("Buckets reset successfully")
+		fmt.Printf("Buckets and bans reset successfully")
 		if login != "" {
 			fmt.Printf(" for login '%s'", login)
 		}
@@ -120,3 +631,142 @@ func HandleResetCommand(client *Client, args []string) error {
 	}
 	return nil
 }
+
+func HandleBucketsCommand(client *Client, args []string) error {
+	if len(args) < 1 || args[0] != "show" {
+		return fmt.Errorf("buckets command requires subcommand: show")
+	}
+
+	var login, passwordHash, ip string
+
+	args = args[1:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--login", "-l":
+			if i+1 < len(args) {
+				login = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--login requires a value")
+			}
+		case "--password-hash", "-p":
+			if i+1 < len(args) {
+				passwordHash = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--password-hash requires a value")
+			}
+		case "--ip", "-i":
+			if i+1 < len(args) {
+				ip = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--ip requires a value")
+			}
+		default:
+			return fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+
+	if login == "" && passwordHash == "" && ip == "" {
+		return fmt.Errorf("buckets show requires --login, --password-hash or --ip")
+	}
+
+	response, err := client.GetBuckets(login, passwordHash, ip)
+	if err != nil {
+		return err
+	}
+
+	printBuckets(response.Buckets, response.Bans)
+	return nil
+}
+
+func printBuckets(buckets []BucketState, bans []Ban) {
+	for _, bucket := range buckets {
+		fmt.Printf("%s (%s): %.2f/%d available per %ds", bucket.Bucket, bucket.Algorithm,
+			bucket.Available, bucket.Capacity, bucket.Window)
+		if bucket.FullIn > 0 {
+			fmt.Printf(", full in %ds", bucket.FullIn)
+		}
+		fmt.Println()
+	}
+	for _, ban := range bans {
+		fmt.Printf("%s banned (level %d) until %s, %ds left\n", ban.Bucket, ban.Level,
+			ban.Until.Local().Format(time.RFC3339), ban.ExpiresIn)
+	}
+}
+
+func HandleExplainCommand(client *Client, args []string) error {
+	var login, ip string
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--login", "-l":
+			if i+1 < len(args) {
+				login = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--login requires a value")
+			}
+		case "--ip", "-i":
+			if i+1 < len(args) {
+				ip = args[i+1]
+				i++
+			} else {
+				return fmt.Errorf("--ip requires a value")
+			}
+		default:
+			return fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+
+	if login == "" && ip == "" {
+		return fmt.Errorf("explain command requires either --ip or --login")
+	}
+
+	response, err := client.Explain(ip, login)
+	if err != nil {
+		return err
+	}
+
+	if ip != "" {
+		fmt.Printf("IP %s: %s (precedence %s, lists loaded %ds ago)\n",
+			response.IP, response.ListStatus, response.Precedence, response.ListsAge)
+		for _, match := range response.Matches {
+			marker := " "
+			if match.Winner {
+				marker = "*"
+			}
+			fmt.Printf("  %s %s %s", marker, match.ListType, match.CIDR)
+			if match.ExpiresAt != nil {
+				fmt.Printf(" (expires %s)", match.ExpiresAt.Local().Format(time.RFC3339))
+			}
+			if match.Trust != "" {
+				fmt.Printf(" (trust %s)", match.Trust)
+			}
+			fmt.Println()
+		}
+		if response.Geo != nil {
+			fmt.Printf("  country %s, AS%d %s\n", response.Geo.Country, response.Geo.ASN, response.Geo.ASOrg)
+			for _, match := range response.GeoRules {
+				marker := " "
+				if match.Winner {
+					marker = "*"
+				}
+				fmt.Printf("  %s geo %s %s\n", marker, match.ListType, match.Rule)
+			}
+		}
+	}
+	if len(response.LoginRules) > 0 {
+		fmt.Printf("Login %s:\n", response.Login)
+		for _, match := range response.LoginRules {
+			marker := " "
+			if match.Winner {
+				marker = "*"
+			}
+			fmt.Printf("  %s logins %s %s\n", marker, match.ListType, match.Rule)
+		}
+	}
+	printBuckets(response.Buckets, response.Bans)
+	return nil
+}
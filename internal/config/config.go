@@ -15,7 +15,9 @@ type Config struct {
 	Storage    StorageConf
 	Migrations MigrationsConf
 	App        AppConf
+	RateLimit  RateLimitConf
 	Redis      RedisConf
+	Feeds      []FeedConf
 }
 
 type LoggerConf struct {
@@ -26,6 +28,11 @@ type LoggerConf struct {
 type ServerConf struct {
 	Host string
 	Port string
+
+	// MetricsHost и MetricsPort - отдельный адрес для метрик, не доступный клиентам API.
+	// Пустой MetricsPort отключает метрики.
+	MetricsHost string
+	MetricsPort string
 }
 
 type StorageConf struct {
@@ -40,10 +47,55 @@ type MigrationsConf struct {
 
 type AppConf struct {
 	CacheTTL      time.Duration
+	MaxStaleness  time.Duration
+	SnapshotPath  string
+	SweepInterval time.Duration
 	LoginLimit    int
 	PasswordLimit int
 	IPLimit       int
 	Window        int
+
+	// ListPrecedence - какой из списков важнее: blacklist-first, most-specific или whitelist-first.
+	ListPrecedence string
+
+	LoginAlgorithm    string
+	PasswordAlgorithm string
+	IPAlgorithm       string
+
+	// GreylistLoginLimit, GreylistPasswordLimit и GreylistIPLimit - лимиты для подсетей серого списка.
+	GreylistLoginLimit    int
+	GreylistPasswordLimit int
+	GreylistIPLimit       int
+
+	// GeoIPCountryDB и GeoIPASNDB - пути к базам MaxMind для правил по странам и ASN.
+	GeoIPCountryDB string
+	GeoIPASNDB     string
+}
+
+type RateLimitConf struct {
+	Backend               string
+	KeySecret             string
+	KeySecretFile         string
+	PreviousKeySecrets    []string
+	HashLogins            bool
+	IPv4Prefix            int
+	IPv6Prefix            int
+	MemoryShards          int
+	MemoryMaxKeys         int
+	MemoryCleanupInterval time.Duration
+
+	BanThreshold   int
+	BanPeriod      time.Duration
+	BanDurations   []time.Duration
+	BanForgetAfter time.Duration
+}
+
+// FeedConf - фид, который синхронизируется в чёрный список. Задаётся только в файле конфигурации.
+type FeedConf struct {
+	Name     string
+	URL      string
+	Format   string
+	Interval time.Duration
 }
 
 type RedisConf struct {
@@ -77,12 +129,35 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to decode config: %w", err)
 	}
 
+	if err := validate(&config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &config, nil
 }
 
+// validate проверяет периоды, с которыми работают фоновые задачи: time.NewTicker паникует на нулевом периоде.
+func validate(config *Config) error {
+	if config.App.CacheTTL <= 0 {
+		return fmt.Errorf("CacheTTL must be positive, got %s", config.App.CacheTTL)
+	}
+	if config.App.SweepInterval <= 0 {
+		return fmt.Errorf("SweepInterval must be positive, got %s", config.App.SweepInterval)
+	}
+	// MaxStaleness 0 снимает ограничение, меньшее CacheTTL значение делает копию устаревшей до её перечитывания
+	if config.App.MaxStaleness != 0 && config.App.MaxStaleness < config.App.CacheTTL {
+		return fmt.Errorf("MaxStaleness (%s) must be 0 or not less than CacheTTL (%s)",
+			config.App.MaxStaleness, config.App.CacheTTL)
+	}
+
+	return nil
+}
+
 func bindEnvVariables() {
 	viper.BindEnv("Server.Host", "ABF_SERVER_HOST")
 	viper.BindEnv("Server.Port", "ABF_SERVER_PORT")
+	viper.BindEnv("Server.MetricsHost", "ABF_SERVER_METRICS_HOST")
+	viper.BindEnv("Server.MetricsPort", "ABF_SERVER_METRICS_PORT")
 
 	viper.BindEnv("Storage.Dsn", "ABF_DATABASE_URL")
 	viper.BindEnv("Migrations.Dsn", "ABF_DATABASE_URL")
@@ -92,10 +167,36 @@ func bindEnvVariables() {
 	viper.BindEnv("Redis.DB", "ABF_REDIS_DB")
 
 	viper.BindEnv("App.CacheTTL", "ABF_CACHE_TTL")
+	viper.BindEnv("App.MaxStaleness", "ABF_CACHE_MAX_STALENESS")
+	viper.BindEnv("App.SnapshotPath", "ABF_SNAPSHOT_PATH")
+	viper.BindEnv("App.SweepInterval", "ABF_SWEEP_INTERVAL")
+	viper.BindEnv("App.ListPrecedence", "ABF_LIST_PRECEDENCE")
 	viper.BindEnv("App.LoginLimit", "ABF_LOGIN_LIMIT")
 	viper.BindEnv("App.PasswordLimit", "ABF_PASSWORD_LIMIT")
 	viper.BindEnv("App.IPLimit", "ABF_IP_LIMIT")
 	viper.BindEnv("App.Window", "ABF_WINDOW_SECONDS")
+	viper.BindEnv("App.LoginAlgorithm", "ABF_LOGIN_ALGORITHM")
+	viper.BindEnv("App.PasswordAlgorithm", "ABF_PASSWORD_ALGORITHM")
+	viper.BindEnv("App.IPAlgorithm", "ABF_IP_ALGORITHM")
+	viper.BindEnv("App.GreylistLoginLimit", "ABF_GREYLIST_LOGIN_LIMIT")
+	viper.BindEnv("App.GreylistPasswordLimit", "ABF_GREYLIST_PASSWORD_LIMIT")
+	viper.BindEnv("App.GreylistIPLimit", "ABF_GREYLIST_IP_LIMIT")
+	viper.BindEnv("App.GeoIPCountryDB", "ABF_GEOIP_COUNTRY_DB")
+	viper.BindEnv("App.GeoIPASNDB", "ABF_GEOIP_ASN_DB")
+
+	viper.BindEnv("RateLimit.Backend", "ABF_RATELIMIT_BACKEND")
+	viper.BindEnv("RateLimit.KeySecret", "ABF_RATELIMIT_KEY_SECRET")
+	viper.BindEnv("RateLimit.KeySecretFile", "ABF_RATELIMIT_KEY_SECRET_FILE")
+	viper.BindEnv("RateLimit.HashLogins", "ABF_RATELIMIT_HASH_LOGINS")
+	viper.BindEnv("RateLimit.IPv4Prefix", "ABF_RATELIMIT_IPV4_PREFIX")
+	viper.BindEnv("RateLimit.IPv6Prefix", "ABF_RATELIMIT_IPV6_PREFIX")
+	viper.BindEnv("RateLimit.MemoryShards", "ABF_RATELIMIT_MEMORY_SHARDS")
+	viper.BindEnv("RateLimit.MemoryMaxKeys", "ABF_RATELIMIT_MEMORY_MAX_KEYS")
+	viper.BindEnv("RateLimit.MemoryCleanupInterval", "ABF_RATELIMIT_MEMORY_CLEANUP_INTERVAL")
+	viper.BindEnv("RateLimit.BanThreshold", "ABF_RATELIMIT_BAN_THRESHOLD")
+	viper.BindEnv("RateLimit.BanPeriod", "ABF_RATELIMIT_BAN_PERIOD")
+	viper.BindEnv("RateLimit.BanDurations", "ABF_RATELIMIT_BAN_DURATIONS")
+	viper.BindEnv("RateLimit.BanForgetAfter", "ABF_RATELIMIT_BAN_FORGET_AFTER")
 
 	viper.BindEnv("Logger.Level", "ABF_LOGGER_LEVEL")
 	viper.BindEnv("Logger.FileName", "ABF_LOGGER_FILENAME")
@@ -107,13 +208,35 @@ func bindEnvVariables() {
 func setDefaults() {
 	viper.SetDefault("Server.Host", "127.0.0.1")
 	viper.SetDefault("Server.Port", "8080")
+	viper.SetDefault("Server.MetricsHost", "127.0.0.1")
+	viper.SetDefault("Server.MetricsPort", "9090")
 	viper.SetDefault("Redis.Address", "localhost:6379")
 	viper.SetDefault("Redis.DB", 0)
 	viper.SetDefault("App.LoginLimit", 10)
 	viper.SetDefault("App.PasswordLimit", 100)
 	viper.SetDefault("App.IPLimit", 1000)
 	viper.SetDefault("App.Window", 60)
+	viper.SetDefault("App.LoginAlgorithm", "token_bucket")
+	viper.SetDefault("App.PasswordAlgorithm", "token_bucket")
+	viper.SetDefault("App.IPAlgorithm", "token_bucket")
+	viper.SetDefault("App.GreylistLoginLimit", 3)
+	viper.SetDefault("App.GreylistPasswordLimit", 10)
+	viper.SetDefault("App.GreylistIPLimit", 20)
 	viper.SetDefault("App.CacheTTL", "10s")
+	viper.SetDefault("App.MaxStaleness", "5m")
+	viper.SetDefault("App.SnapshotPath", "data/ip_lists_snapshot.json")
+	viper.SetDefault("App.SweepInterval", "1m")
+	viper.SetDefault("App.ListPrecedence", "blacklist-first")
+	viper.SetDefault("RateLimit.Backend", "redis")
+	viper.SetDefault("RateLimit.IPv4Prefix", 32)
+	viper.SetDefault("RateLimit.IPv6Prefix", 64)
+	viper.SetDefault("RateLimit.MemoryShards", 64)
+	viper.SetDefault("RateLimit.MemoryMaxKeys", 1000000)
+	viper.SetDefault("RateLimit.MemoryCleanupInterval", "1m")
+	viper.SetDefault("RateLimit.BanThreshold", 0)
+	viper.SetDefault("RateLimit.BanPeriod", "10m")
+	viper.SetDefault("RateLimit.BanDurations", []string{"1m", "10m", "1h", "24h"})
+	viper.SetDefault("RateLimit.BanForgetAfter", "24h")
 	viper.SetDefault("Logger.Level", "INFO")
 	viper.SetDefault("Logger.FileName", "logs/app.log")
 	viper.SetDefault("Migrations.AutoMigrate", true)
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := AppConf{CacheTTL: 10 * time.Second, MaxStaleness: 5 * time.Minute, SweepInterval: time.Minute}
+
+	tests := []struct {
+		name    string
+		modify  func(conf *AppConf)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(*AppConf) {}},
+		{name: "unlimited staleness", modify: func(conf *AppConf) { conf.MaxStaleness = 0 }},
+		{name: "zero cache ttl", modify: func(conf *AppConf) { conf.CacheTTL = 0 }, wantErr: true},
+		{name: "negative cache ttl", modify: func(conf *AppConf) { conf.CacheTTL = -time.Second }, wantErr: true},
+		{name: "zero sweep interval", modify: func(conf *AppConf) { conf.SweepInterval = 0 }, wantErr: true},
+		{
+			name:    "staleness below cache ttl",
+			modify:  func(conf *AppConf) { conf.MaxStaleness = 5 * time.Second },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := valid
+			tt.modify(&conf)
+
+			err := validate(&Config{App: conf})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
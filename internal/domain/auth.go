@@ -5,6 +5,7 @@ type IPListStatus string
 const (
 	IPInBlacklist IPListStatus = "blacklist"
 	IPInWhitelist IPListStatus = "whitelist"
+	IPInGreylist  IPListStatus = "greylist"
 	IPNotInList   IPListStatus = "not_in_list"
 )
 
@@ -14,6 +15,28 @@ type AuthRequest struct {
 	IP       string `json:"ip"`
 }
 
+type DenialReason string
+
+const (
+	DenialBlacklist      DenialReason = "blacklist"
+	DenialGeoBlacklist   DenialReason = "geo_blacklist"
+	DenialLoginBlacklist DenialReason = "login_blacklist"
+	DenialLoginLimit     DenialReason = "login_limit"
+	DenialPasswordLimit  DenialReason = "password_limit"
+	DenialIPLimit        DenialReason = "ip_limit"
+	DenialLoginBan       DenialReason = "login_ban"
+	DenialIPBan          DenialReason = "ip_ban"
+)
+
+// AuthResponse - решение по попытке авторизации. Поля, кроме OK, необязательны,
+// чтобы ответ оставался совместимым с клиентами, читающими только OK.
 type AuthResponse struct {
-	OK bool `json:"ok"`
+	OK     bool         `json:"ok"`
+	Reason DenialReason `json:"reason,omitempty"`
+	// Match - подсеть из списка, правило по стране, ASN или логину или бакет, определившие решение.
+	Match string `json:"match,omitempty"`
+	// Remaining - сколько ещё попыток пропустит самый строгий из бакетов.
+	Remaining *int `json:"remaining,omitempty"`
+	// RetryAfter - через сколько секунд имеет смысл повторить попытку.
+	RetryAfter int `json:"retryAfter,omitempty"`
 }
@@ -0,0 +1,56 @@
+package domain
+
+import "time"
+
+type ExplainRequest struct {
+	IP    string `json:"ip"`
+	Login string `json:"login"`
+}
+
+// ListMatch - подсеть списка, содержащая IP.
+type ListMatch struct {
+	ListType  ListType   `json:"listType"`
+	CIDR      string     `json:"cidr"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Trust     TrustLevel `json:"trust,omitempty"`
+	// Winner - подсеть, определившая решение по спискам.
+	Winner bool `json:"winner"`
+}
+
+// GeoRuleMatch - правило по стране или ASN, подходящее к IP.
+type GeoRuleMatch struct {
+	ListType ListType `json:"listType"`
+	// Rule - правило в виде "country:RU" или "asn:AS13335".
+	Rule string `json:"rule"`
+	// Winner - правило чёрного списка, по которому IP блокируется.
+	Winner bool `json:"winner"`
+}
+
+// LoginRuleMatch - правило списка логинов, подходящее к логину.
+type LoginRuleMatch struct {
+	ListType ListType `json:"listType"`
+	// Rule - правило в виде "exact:admin" или "glob:svc-*".
+	Rule string `json:"rule"`
+	// Winner - правило, определившее решение по логину.
+	Winner bool `json:"winner"`
+}
+
+// ExplainResponse - всё, что влияет на решение по IP и логину. Ничего не меняет и не списывает.
+type ExplainResponse struct {
+	IP    string `json:"ip,omitempty"`
+	Login string `json:"login,omitempty"`
+	// Precedence - порядок, в котором применяются списки.
+	Precedence string `json:"precedence,omitempty"`
+	// Matches - подсети всех списков, содержащие IP, от более специфичных к менее специфичным.
+	Matches    []ListMatch  `json:"matches"`
+	ListStatus IPListStatus `json:"listStatus,omitempty"`
+	// Geo - страна и ASN IP. Правила по ним применяются, только если IP нет в списках подсетей.
+	Geo      *GeoInfo       `json:"geo,omitempty"`
+	GeoRules []GeoRuleMatch `json:"geoRules,omitempty"`
+	// LoginRules - правила списков логинов, подходящие к логину, сначала чёрного списка.
+	LoginRules []LoginRuleMatch `json:"loginRules,omitempty"`
+	// ListsAge - возраст загруженной копии списков в секундах.
+	ListsAge int           `json:"listsAge"`
+	Buckets  []BucketState `json:"buckets"`
+	Bans     []Ban         `json:"bans"`
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// FeedStatus - состояние синхронизации фида в чёрный список.
+type FeedStatus struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	// Source - источник, под которым подсети фида хранятся в списке.
+	Source string `json:"source"`
+	// Interval - период синхронизации в секундах.
+	Interval    int        `json:"interval"`
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	LastSync    *time.Time `json:"lastSync,omitempty"`
+	// Entries - число подсетей фида при последней успешной синхронизации, Invalid - пропущенных строк.
+	Entries int `json:"entries"`
+	Invalid int `json:"invalid"`
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+	// Error - ошибка последней попытки. Пусто, если она прошла успешно.
+	Error string `json:"error,omitempty"`
+}
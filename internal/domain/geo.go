@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GeoRuleKind - по чему правило сопоставляет IP.
+type GeoRuleKind string
+
+const (
+	// GeoRuleCountry - код страны ISO 3166-1 alpha-2, например "RU".
+	GeoRuleCountry GeoRuleKind = "country"
+	// GeoRuleASN - номер автономной системы без префикса AS, например "13335".
+	GeoRuleASN GeoRuleKind = "asn"
+)
+
+// GeoRule - правило чёрного или белого списка по стране или ASN. Правила белого списка не пропускают
+// IP без проверки лимитов, а только отменяют для него правила чёрного списка: например, разрешают ASN
+// партнёра из заблокированной страны.
+type GeoRule struct {
+	ListType  ListType
+	Kind      GeoRuleKind
+	Value     string
+	Comment   string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// String возвращает правило в виде, в котором оно попадает в ответы и логи: "country:RU" или "asn:AS13335".
+func (r GeoRule) String() string {
+	if r.Kind == GeoRuleASN {
+		return fmt.Sprintf("%s:AS%s", r.Kind, r.Value)
+	}
+
+	return fmt.Sprintf("%s:%s", r.Kind, r.Value)
+}
+
+// ErrInvalidGeoRule - неизвестный вид правила или некорректные страна или ASN.
+var ErrInvalidGeoRule = errors.New("invalid geo rule")
+
+var ErrGeoRuleNotFound = errors.New("geo rule not found")
+
+// NormalizeGeoRule проверяет правило и приводит значение к виду, в котором его хранит база:
+// код страны - в верхний регистр, у ASN убирается префикс AS.
+func NormalizeGeoRule(kind GeoRuleKind, value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	switch kind {
+	case GeoRuleCountry:
+		value = strings.ToUpper(value)
+		if len(value) != 2 || value[0] < 'A' || value[0] > 'Z' || value[1] < 'A' || value[1] > 'Z' {
+			return "", fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code, e.g. RU, got %q",
+				ErrInvalidGeoRule, value)
+		}
+		return value, nil
+	case GeoRuleASN:
+		number := strings.TrimPrefix(strings.ToUpper(value), "AS")
+		asn, err := strconv.ParseUint(number, 10, 32)
+		if err != nil || asn == 0 {
+			return "", fmt.Errorf("%w: asn must be a number, e.g. 13335 or AS13335, got %q", ErrInvalidGeoRule, value)
+		}
+		return strconv.FormatUint(asn, 10), nil
+	default:
+		return "", fmt.Errorf("%w: unknown kind %q, expected country or asn", ErrInvalidGeoRule, kind)
+	}
+}
+
+// GeoInfo - страна и автономная система IP. Пустые поля - IP не найден в базе или база не подключена.
+type GeoInfo struct {
+	Country string `json:"country,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"asOrg,omitempty"`
+}
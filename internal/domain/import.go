@@ -0,0 +1,41 @@
+package domain
+
+import "fmt"
+
+// ImportMode - что делать с подсетями списка, которых нет в импорте.
+type ImportMode string
+
+const (
+	// ImportMerge добавляет и обновляет подсети, не трогая остальные.
+	ImportMerge ImportMode = "merge"
+	// ImportReplace делает список равным импорту: подсети, которых нет в импорте, удаляются.
+	// Подсети фидов не удаляются, их удалит синхронизация фида.
+	ImportReplace ImportMode = "replace"
+)
+
+func ParseImportMode(mode string) (ImportMode, error) {
+	switch ImportMode(mode) {
+	case "", ImportMerge:
+		return ImportMerge, nil
+	case ImportReplace:
+		return ImportReplace, nil
+	default:
+		return "", fmt.Errorf("unknown import mode: %s", mode)
+	}
+}
+
+type ImportOptions struct {
+	Mode ImportMode
+	// DryRun только считает изменения, ничего не меняя.
+	DryRun bool
+}
+
+// ImportResult - изменения списка при импорте.
+type ImportResult struct {
+	Mode      ImportMode `json:"mode"`
+	DryRun    bool       `json:"dryRun"`
+	Added     []string   `json:"added"`
+	Updated   []string   `json:"updated"`
+	Removed   []string   `json:"removed"`
+	Unchanged int        `json:"unchanged"`
+}
@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ErrInvalidIPRange - строка не является диапазоном адресов.
+var ErrInvalidIPRange = errors.New("invalid IP range")
+
+// IPRange - диапазон адресов от Start до End включительно. В списках он хранится как набор
+// подсетей, которые его покрывают.
+type IPRange struct {
+	Start netip.Addr
+	End   netip.Addr
+}
+
+func (r IPRange) String() string {
+	return r.Start.String() + "-" + r.End.String()
+}
+
+// ParseIPRange разбирает диапазон вида "203.0.113.17-203.0.113.90" или маску IPv4 вида "203.0.113.*",
+// "10.20.*.*", в которой звёздочками заменены последние октеты.
+func ParseIPRange(s string) (IPRange, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "*") {
+		return parseIPWildcard(s)
+	}
+
+	startStr, endStr, found := strings.Cut(s, "-")
+	if !found {
+		return IPRange{}, fmt.Errorf("%w %q: expected start-end, e.g. 203.0.113.17-203.0.113.90, or 203.0.113.*",
+			ErrInvalidIPRange, s)
+	}
+
+	start, err := parseRangeAddr(startStr)
+	if err != nil {
+		return IPRange{}, fmt.Errorf("%w %q: %w", ErrInvalidIPRange, s, err)
+	}
+	end, err := parseRangeAddr(endStr)
+	if err != nil {
+		return IPRange{}, fmt.Errorf("%w %q: %w", ErrInvalidIPRange, s, err)
+	}
+
+	if start.Is4() != end.Is4() {
+		return IPRange{}, fmt.Errorf("%w %q: start and end must be of the same address family", ErrInvalidIPRange, s)
+	}
+	if end.Less(start) {
+		return IPRange{}, fmt.Errorf("%w %q: start is greater than end", ErrInvalidIPRange, s)
+	}
+
+	return IPRange{Start: start, End: end}, nil
+}
+
+func parseRangeAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if addr.Zone() != "" {
+		return netip.Addr{}, errors.New("addresses with a zone are not supported")
+	}
+
+	return addr.Unmap(), nil
+}
+
+func parseIPWildcard(s string) (IPRange, error) {
+	octets := strings.Split(s, ".")
+	if len(octets) != 4 {
+		return IPRange{}, fmt.Errorf("%w %q: wildcards are supported only in IPv4 addresses, e.g. 203.0.113.*",
+			ErrInvalidIPRange, s)
+	}
+
+	start, end := make([]string, 4), make([]string, 4)
+	wildcard := false
+	for i, octet := range octets {
+		switch {
+		case octet == "*":
+			wildcard = true
+			start[i], end[i] = "0", "255"
+		case wildcard:
+			return IPRange{}, fmt.Errorf("%w %q: only trailing octets can be replaced with *", ErrInvalidIPRange, s)
+		default:
+			start[i], end[i] = octet, octet
+		}
+	}
+
+	startAddr, err := netip.ParseAddr(strings.Join(start, "."))
+	if err != nil {
+		return IPRange{}, fmt.Errorf("%w %q: %w", ErrInvalidIPRange, s, err)
+	}
+	endAddr, err := netip.ParseAddr(strings.Join(end, "."))
+	if err != nil {
+		return IPRange{}, fmt.Errorf("%w %q: %w", ErrInvalidIPRange, s, err)
+	}
+
+	return IPRange{Start: startAddr, End: endAddr}, nil
+}
+
+// CIDRs возвращает минимальный набор подсетей, покрывающих диапазон, по возрастанию адресов.
+// Подсети записаны в том же виде, что и после CanonicalCIDR.
+func (r IPRange) CIDRs() []string {
+	var cidrs []string
+
+	start := r.Start
+	for {
+		// Самая широкая подсеть, которая начинается со start и не выходит за конец диапазона
+		bits := start.BitLen()
+		for bits > 0 {
+			wider := netip.PrefixFrom(start, bits-1)
+			if wider.Masked().Addr() != start || r.End.Less(lastAddr(wider)) {
+				break
+			}
+			bits--
+		}
+
+		prefix := netip.PrefixFrom(start, bits)
+		cidrs = append(cidrs, prefix.String())
+
+		last := lastAddr(prefix)
+		if last == r.End {
+			return cidrs
+		}
+		start = last.Next()
+	}
+}
+
+// lastAddr возвращает последний адрес подсети.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Addr()
+	bytes := addr.As16()
+
+	offset := 0
+	if addr.Is4() {
+		offset = 96
+	}
+	for i := offset + prefix.Bits(); i < 128; i++ {
+		bytes[i/8] |= 1 << (7 - i%8)
+	}
+
+	last := netip.AddrFrom16(bytes)
+	if addr.Is4() {
+		last = last.Unmap()
+	}
+
+	return last
+}
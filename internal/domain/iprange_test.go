@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPRange_CIDRs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input: "203.0.113.17-203.0.113.90",
+			expected: []string{
+				"203.0.113.17/32", "203.0.113.18/31", "203.0.113.20/30", "203.0.113.24/29",
+				"203.0.113.32/27", "203.0.113.64/28", "203.0.113.80/29", "203.0.113.88/31", "203.0.113.90/32",
+			},
+		},
+		{input: " 10.0.0.0 - 10.0.255.255 ", expected: []string{"10.0.0.0/16"}},
+		{input: "192.0.2.1-192.0.2.1", expected: []string{"192.0.2.1/32"}},
+		{input: "192.0.2.255-192.0.3.0", expected: []string{"192.0.2.255/32", "192.0.3.0/32"}},
+		{input: "0.0.0.0-255.255.255.255", expected: []string{"0.0.0.0/0"}},
+		{input: "255.255.255.254-255.255.255.255", expected: []string{"255.255.255.254/31"}},
+		{input: "::ffff:10.0.0.0-10.0.0.3", expected: []string{"10.0.0.0/30"}},
+		{input: "2001:db8::-2001:db8::ffff:ffff", expected: []string{"2001:db8::/96"}},
+		{input: "2001:db8::1-2001:db8::2", expected: []string{"2001:db8::1/128", "2001:db8::2/128"}},
+		{input: "203.0.113.*", expected: []string{"203.0.113.0/24"}},
+		{input: "10.20.*.*", expected: []string{"10.20.0.0/16"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ipRange, err := ParseIPRange(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ipRange.CIDRs())
+		})
+	}
+}
+
+func TestParseIPRange_Invalid(t *testing.T) {
+	inputs := []string{
+		"", "203.0.113.17", "203.0.113.90-203.0.113.17", "10.0.0.1-2001:db8::1", "10.0.0.1-", "a-b",
+		"10.*.0.1", "2001:db8::*", "10.0.*", "300.0.0.*", "fe80::1%eth0-fe80::2",
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseIPRange(input)
+			require.ErrorIs(t, err, ErrInvalidIPRange)
+		})
+	}
+}
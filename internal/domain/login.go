@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LoginRuleKind - как правило сопоставляет логин.
+type LoginRuleKind string
+
+const (
+	// LoginRuleExact - логин совпадает с шаблоном целиком, с учётом регистра.
+	LoginRuleExact LoginRuleKind = "exact"
+	// LoginRuleGlob - шаблон в синтаксисе path.Match, например "svc-*" или "probe-??".
+	LoginRuleGlob LoginRuleKind = "glob"
+	// LoginRuleRegex - регулярное выражение RE2, которому должен соответствовать весь логин.
+	LoginRuleRegex LoginRuleKind = "regex"
+)
+
+// LoginRule - правило чёрного или белого списка логинов. Логин из чёрного списка отклоняется
+// до проверки лимитов, логин из белого - не проверяется бакетом логина (например, сервисные
+// учётные записи мониторинга), но остальные лимиты для него действуют.
+type LoginRule struct {
+	ListType  ListType
+	Kind      LoginRuleKind
+	Pattern   string
+	Comment   string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// String возвращает правило в виде, в котором оно попадает в ответы и логи, например "glob:svc-*".
+func (r LoginRule) String() string {
+	return fmt.Sprintf("%s:%s", r.Kind, r.Pattern)
+}
+
+// ErrInvalidLoginRule - неизвестный вид правила, пустой или некорректный шаблон.
+var ErrInvalidLoginRule = errors.New("invalid login rule")
+
+var ErrLoginRuleNotFound = errors.New("login rule not found")
+
+const maxLoginPatternLength = 256
+
+// NormalizeLoginRule проверяет шаблон правила и убирает пробелы по краям. Пустой kind означает LoginRuleExact.
+func NormalizeLoginRule(kind LoginRuleKind, pattern string) (LoginRuleKind, string, error) {
+	pattern = strings.TrimSpace(pattern)
+	if kind == "" {
+		kind = LoginRuleExact
+	}
+
+	if pattern == "" {
+		return "", "", fmt.Errorf("%w: pattern must not be empty", ErrInvalidLoginRule)
+	}
+	if len(pattern) > maxLoginPatternLength {
+		return "", "", fmt.Errorf("%w: pattern is longer than %d characters", ErrInvalidLoginRule, maxLoginPatternLength)
+	}
+
+	switch kind {
+	case LoginRuleExact:
+	case LoginRuleGlob:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return "", "", fmt.Errorf("%w: invalid glob %q: %w", ErrInvalidLoginRule, pattern, err)
+		}
+	case LoginRuleRegex:
+		if _, err := CompileLoginRegex(pattern); err != nil {
+			return "", "", fmt.Errorf("%w: invalid regex %q: %w", ErrInvalidLoginRule, pattern, err)
+		}
+	default:
+		return "", "", fmt.Errorf("%w: unknown kind %q, expected exact, glob or regex", ErrInvalidLoginRule, kind)
+	}
+
+	return kind, pattern, nil
+}
+
+// CompileLoginRegex компилирует шаблон правила LoginRuleRegex. Шаблон привязывается к началу и концу
+// логина, чтобы "admin" не совпадал с "not-an-admin".
+func CompileLoginRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type ResetBucketsRequest struct {
 	Login string `json:"login"`
 	IP    string `json:"ip"`
@@ -8,3 +10,32 @@ type ResetBucketsRequest struct {
 type ResetBucketsResponse struct {
 	Reset bool `json:"reset"`
 }
+
+type BucketsRequest struct {
+	Login        string `json:"login"`
+	PasswordHash string `json:"passwordHash"`
+	IP           string `json:"ip"`
+}
+
+// BucketState - состояние бакета с учётом восстановления. Window и FullIn - в секундах.
+type BucketState struct {
+	Bucket    string  `json:"bucket"`
+	Algorithm string  `json:"algorithm"`
+	Available float64 `json:"available"`
+	Capacity  int     `json:"capacity"`
+	Window    int     `json:"window"`
+	FullIn    int     `json:"fullIn"`
+}
+
+// Ban - действующий бан логина или IP. ExpiresIn - в секундах.
+type Ban struct {
+	Bucket    string    `json:"bucket"`
+	Level     int       `json:"level"`
+	Until     time.Time `json:"until"`
+	ExpiresIn int       `json:"expiresIn"`
+}
+
+type BucketsResponse struct {
+	Buckets []BucketState `json:"buckets"`
+	Bans    []Ban         `json:"bans"`
+}
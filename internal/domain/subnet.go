@@ -1,17 +1,276 @@
 package domain
 
-import "errors"
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
 
 type ListType string
 
 const (
 	Blacklist ListType = "blacklist"
 	Whitelist ListType = "whitelist"
+	// Greylist - подозрительные подсети (хостинги, VPN): запросы из них не блокируются,
+	// но проверяются с отдельными, более строгими лимитами.
+	Greylist ListType = "greylist"
 )
 
 type Subnet struct {
 	ListType ListType
 	CIDR     string
+	// ExpiresAt - когда запись перестаёт действовать. nil у постоянных записей.
+	ExpiresAt *time.Time
+
+	// Comment - зачем подсеть добавлена в список, CreatedBy - кто её добавил.
+	Comment   string
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Tags      []string
+	// Source - откуда подсеть синхронизируется, например "feed:spamhaus-drop". Пусто у подсетей,
+	// добавленных вручную или импортом.
+	Source string
+	// Trust - уровень доверия подсети белого списка. Пусто у подсетей чёрного списка.
+	Trust TrustLevel
+}
+
+// SubnetFilter отбирает подсети списка. Пустые поля не ограничивают выборку.
+type SubnetFilter struct {
+	Tag string
+	// Source - источник подсетей, например "feed:spamhaus-drop". SourceManual отбирает подсети без источника.
+	Source string
+	// Contains - адрес, который должен входить в подсеть.
+	Contains netip.Addr
+	// Within - подсеть, в которую должна входить подсеть. Сама Within тоже подходит.
+	Within netip.Prefix
+	// CreatedSince отбирает подсети, добавленные не раньше этого момента.
+	CreatedSince time.Time
+
+	// Подсети упорядочены по CIDR. After - CIDR, после которого начинается выборка, Limit - сколько
+	// подсетей вернуть. 0 снимает ограничение.
+	After string
+	Limit int
+}
+
+// SourceManual в SubnetFilter.Source отбирает подсети, добавленные вручную или импортом.
+const SourceManual = "manual"
+
+// Matches сообщает, проходит ли подсеть фильтр. After и Limit не учитываются.
+func (f SubnetFilter) Matches(subnet Subnet) bool {
+	if f.Tag != "" && !slices.Contains(subnet.Tags, f.Tag) {
+		return false
+	}
+	if f.Source != "" && subnet.Source != f.StoredSource() {
+		return false
+	}
+	if !f.CreatedSince.IsZero() && subnet.CreatedAt.Before(f.CreatedSince) {
+		return false
+	}
+
+	if f.Contains.IsValid() || f.Within.IsValid() {
+		prefix, err := netip.ParsePrefix(subnet.CIDR)
+		if err != nil {
+			return false
+		}
+		if f.Contains.IsValid() && !prefix.Contains(f.Contains) {
+			return false
+		}
+		if f.Within.IsValid() && (prefix.Bits() < f.Within.Bits() || !f.Within.Contains(prefix.Addr())) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// StoredSource возвращает значение Source, с которым подсети хранятся в базе.
+func (f SubnetFilter) StoredSource() string {
+	if f.Source == SourceManual {
+		return ""
+	}
+	return f.Source
+}
+
+// SubnetsPage - страница подсетей списка. NextCursor пуст на последней странице.
+type SubnetsPage struct {
+	Subnets    []Subnet
+	NextCursor string
+}
+
+// ErrInvalidCursor - курсор страницы не получен из SubnetsPage.NextCursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeSubnetCursor возвращает курсор страницы, которая начинается после подсети cidr.
+func EncodeSubnetCursor(cidr string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cidr))
+}
+
+// DecodeSubnetCursor возвращает CIDR, после которого начинается страница.
+func DecodeSubnetCursor(cursor string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
+	}
+
+	cidr, err := CanonicalCIDR(string(decoded))
+	if err != nil {
+		return "", fmt.Errorf("%w %q", ErrInvalidCursor, cursor)
+	}
+
+	return cidr, nil
+}
+
+const maxTagLength = 64
+
+// NormalizeTags приводит теги к нижнему регистру, убирает пробелы по краям и повторы.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, errors.New("tag must not be empty")
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag is longer than %d characters: %s", maxTagLength, tag)
+		}
+		if strings.ContainsAny(tag, ", \t\n") {
+			return nil, fmt.Errorf("tag must not contain commas or whitespace: %s", tag)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+
+	return normalized, nil
+}
+
+// Expired сообщает, истёк ли срок действия записи к моменту now.
+func (s Subnet) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !s.ExpiresAt.After(now)
 }
 
 var ErrSubnetNotFound = errors.New("subnet not found")
+
+// ErrInvalidCIDR - строка не является подсетью или адресом.
+var ErrInvalidCIDR = errors.New("invalid CIDR")
+
+// CanonicalCIDR приводит подсеть к виду, в котором её хранит база: адрес без префикса
+// становится /32 или /128, адреса IPv6 записываются в сокращённой форме, IPv4, отображённые в IPv6,
+// становятся IPv4. Подсеть с ненулевыми битами адреса за префиксом отклоняется, так как непонятно,
+// имелась в виду подсеть или один адрес.
+func CanonicalCIDR(cidr string) (string, error) {
+	cidr = strings.TrimSpace(cidr)
+
+	var prefix netip.Prefix
+	if strings.Contains(cidr, "/") {
+		parsed, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return "", fmt.Errorf("%w %q: expected an address with a prefix length, e.g. 192.168.1.0/24", ErrInvalidCIDR, cidr)
+		}
+		prefix = parsed
+	} else {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return "", fmt.Errorf("%w %q: expected an IP address or a subnet, e.g. 192.168.1.0/24", ErrInvalidCIDR, cidr)
+		}
+		if addr.Zone() != "" {
+			return "", fmt.Errorf("%w %q: addresses with a zone are not supported", ErrInvalidCIDR, cidr)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+	canonical := netip.PrefixFrom(addr, bits)
+
+	if masked := canonical.Masked(); masked != canonical {
+		return "", fmt.Errorf("%w %q: address has bits set beyond the /%d prefix, did you mean %s?",
+			ErrInvalidCIDR, cidr, bits, masked)
+	}
+
+	return canonical.String(), nil
+}
+
+// ErrIPListsUnavailable - списки ещё не загружены или устарели сильнее допустимого, пока база недоступна.
+var ErrIPListsUnavailable = errors.New("IP lists unavailable")
+
+type SubnetChangeOp string
+
+const (
+	SubnetUpserted SubnetChangeOp = "upsert"
+	SubnetDeleted  SubnetChangeOp = "delete"
+	// SubnetResync - изменения могли быть потеряны (например, при переподключении), списки нужно перечитать.
+	SubnetResync SubnetChangeOp = "resync"
+	// GeoRulesChanged - изменились правила по странам и ASN, их нужно перечитать. Они приходят тем же путём,
+	// что и изменения подсетей, чтобы применяться на всех экземплярах так же быстро.
+	GeoRulesChanged SubnetChangeOp = "geo_rules"
+	// LoginRulesChanged - изменились списки логинов, их нужно перечитать.
+	LoginRulesChanged SubnetChangeOp = "login_rules"
+)
+
+// SubnetChange - изменение списка, сделанное любым экземпляром сервиса.
+type SubnetChange struct {
+	Op     SubnetChangeOp
+	Subnet Subnet
+}
+
+// OverlapKind - как пересекаются две подсети списков.
+type OverlapKind string
+
+const (
+	// OverlapConflict - подсети из разных списков пересекаются. В зависимости от ListPrecedence
+	// часть одного из списков перестаёт действовать.
+	OverlapConflict OverlapKind = "conflict"
+	// OverlapRedundant - подсеть того же списка уже покрыта более широкой.
+	OverlapRedundant OverlapKind = "redundant"
+	// OverlapCovers - подсеть покрывает более узкие подсети того же списка, и они становятся лишними.
+	OverlapCovers OverlapKind = "covers"
+)
+
+// SubnetOverlap - пересечение подсети Subnet с подсетью Other.
+type SubnetOverlap struct {
+	Kind   OverlapKind
+	Subnet Subnet
+	Other  Subnet
+}
+
+// NewSubnetOverlap определяет вид пересечения подсетей. Подсети должны пересекаться.
+func NewSubnetOverlap(subnet, other Subnet) SubnetOverlap {
+	overlap := SubnetOverlap{Kind: OverlapConflict, Subnet: subnet, Other: other}
+	if subnet.ListType != other.ListType {
+		return overlap
+	}
+
+	overlap.Kind = OverlapRedundant
+	subnetPrefix, subnetErr := netip.ParsePrefix(subnet.CIDR)
+	otherPrefix, otherErr := netip.ParsePrefix(other.CIDR)
+	if subnetErr == nil && otherErr == nil && otherPrefix.Bits() > subnetPrefix.Bits() {
+		overlap.Kind = OverlapCovers
+	}
+
+	return overlap
+}
+
+// ErrSubnetOverlap - добавляемая подсеть пересекается с подсетями списков.
+var ErrSubnetOverlap = errors.New("subnet overlaps existing entries")
+
+// SubnetOverlapError перечисляет пересечения, из-за которых подсеть не добавлена.
+type SubnetOverlapError struct {
+	Overlaps []SubnetOverlap
+}
+
+func (e *SubnetOverlapError) Error() string {
+	return fmt.Sprintf("subnet overlaps %d existing entries", len(e.Overlaps))
+}
+
+func (e *SubnetOverlapError) Is(target error) bool {
+	return target == ErrSubnetOverlap
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalCIDR(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "192.168.1.0/24", expected: "192.168.1.0/24"},
+		{input: " 10.0.0.0/8 ", expected: "10.0.0.0/8"},
+		{input: "192.168.1.1", expected: "192.168.1.1/32"},
+		{input: "2001:db8::1", expected: "2001:db8::1/128"},
+		{input: "2001:DB8:0:0::/32", expected: "2001:db8::/32"},
+		{input: "::ffff:10.0.0.0/104", expected: "10.0.0.0/8"},
+		{input: "::ffff:1.2.3.4", expected: "1.2.3.4/32"},
+		{input: "0.0.0.0/0", expected: "0.0.0.0/0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			cidr, err := CanonicalCIDR(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cidr)
+		})
+	}
+}
+
+func TestCanonicalCIDR_Invalid(t *testing.T) {
+	for _, input := range []string{"", "not-an-ip", "192.168.1.0/33", "300.1.1.1", "fe80::1%eth0", "10.0.0.0/8/8"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := CanonicalCIDR(input)
+			require.ErrorIs(t, err, ErrInvalidCIDR)
+		})
+	}
+
+	_, err := CanonicalCIDR("192.168.1.1/24")
+	require.ErrorIs(t, err, ErrInvalidCIDR)
+	assert.Contains(t, err.Error(), "did you mean 192.168.1.0/24")
+}
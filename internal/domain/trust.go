@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ListPrecedence - какая из подсетей списков, содержащих IP, определяет решение.
+type ListPrecedence string
+
+const (
+	// PrecedenceBlacklistFirst - любая подсеть чёрного списка важнее белого.
+	PrecedenceBlacklistFirst ListPrecedence = "blacklist-first"
+	// PrecedenceMostSpecific - решает самая узкая подсеть, при равных префиксах - чёрный список.
+	PrecedenceMostSpecific ListPrecedence = "most-specific"
+	// PrecedenceWhitelistFirst - любая подсеть белого списка важнее чёрного.
+	PrecedenceWhitelistFirst ListPrecedence = "whitelist-first"
+)
+
+// ParseListPrecedence проверяет порядок списков. Пустая строка означает PrecedenceBlacklistFirst.
+func ParseListPrecedence(precedence string) (ListPrecedence, error) {
+	switch ListPrecedence(precedence) {
+	case "":
+		return PrecedenceBlacklistFirst, nil
+	case PrecedenceBlacklistFirst, PrecedenceMostSpecific, PrecedenceWhitelistFirst:
+		return ListPrecedence(precedence), nil
+	default:
+		return "", fmt.Errorf("unknown list precedence: %s, expected blacklist-first, most-specific or whitelist-first",
+			precedence)
+	}
+}
+
+// TrustLevel - насколько подсеть белого списка освобождает от ограничений.
+type TrustLevel string
+
+const (
+	// TrustBypassAll - запросы разрешаются без проверки лимитов и банов.
+	TrustBypassAll TrustLevel = "bypass_all"
+	// TrustBypassIP - не проверяется только бакет IP. Подходит для NAT и офисных шлюзов:
+	// перебор паролей одного логина по-прежнему ограничен.
+	TrustBypassIP TrustLevel = "bypass_ip"
+	// TrustRelaxed - лимиты логина, пароля и IP увеличены в RelaxedLimitMultiplier раз.
+	TrustRelaxed TrustLevel = "relaxed"
+)
+
+// RelaxedLimitMultiplier - во сколько раз TrustRelaxed увеличивает лимиты.
+const RelaxedLimitMultiplier = 10
+
+// ErrInvalidTrustLevel - неизвестный уровень доверия или уровень доверия у подсети чёрного списка.
+var ErrInvalidTrustLevel = errors.New("invalid trust level")
+
+// NormalizeTrustLevel проверяет уровень доверия подсети списка listType. У подсетей белого списка
+// пустой уровень означает TrustBypassAll, у подсетей чёрного списка уровня нет.
+func NormalizeTrustLevel(listType ListType, trust string) (TrustLevel, error) {
+	if listType != Whitelist {
+		if trust != "" {
+			return "", fmt.Errorf("%w: only whitelist subnets have a trust level", ErrInvalidTrustLevel)
+		}
+		return "", nil
+	}
+
+	switch TrustLevel(trust) {
+	case "":
+		return TrustBypassAll, nil
+	case TrustBypassAll, TrustBypassIP, TrustRelaxed:
+		return TrustLevel(trust), nil
+	default:
+		return "", fmt.Errorf("%w: %s, expected bypass_all, bypass_ip or relaxed", ErrInvalidTrustLevel, trust)
+	}
+}
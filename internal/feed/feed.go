@@ -0,0 +1,183 @@
+package feed
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// Format - формат списка подсетей фида.
+type Format string
+
+const (
+	// FormatSpamhaus - Spamhaus DROP и EDROP: "1.10.16.0/20 ; SBL256894", комментарии после ";".
+	// Поддерживается и формат JSON Lines ({"cidr": "...", "sblid": "..."}), в котором Spamhaus отдаёт списки сейчас.
+	FormatSpamhaus Format = "spamhaus"
+	// FormatFireHOL - netset FireHOL: по адресу или подсети в строке, комментарии после "#".
+	FormatFireHOL Format = "firehol"
+	// FormatPlain - по подсети в строке, комментарии после "#" или ";".
+	FormatPlain Format = "plain"
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatSpamhaus, FormatFireHOL, FormatPlain:
+		return Format(format), nil
+	default:
+		return "", fmt.Errorf("unknown feed format: %s, expected spamhaus, firehol or plain", format)
+	}
+}
+
+// Feed - внешний список подсетей, который синхронизируется в чёрный список.
+type Feed struct {
+	Name string
+	// URL - адрес http(s) или путь к локальному файлу.
+	URL      string
+	Format   Format
+	Interval time.Duration
+}
+
+// Source - источник подсетей фида в storage.SubnetRepository.
+func (f Feed) Source() string {
+	return "feed:" + f.Name
+}
+
+// maxFeedSize ограничивает размер фида, чтобы ошибочный URL не занял всю память.
+const maxFeedSize = 64 << 20
+
+// Fetch скачивает фид по http(s) или читает его из файла.
+func Fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		data, err := os.ReadFile(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read feed: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch feed: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	if len(data) > maxFeedSize {
+		return nil, fmt.Errorf("feed is larger than %d bytes", maxFeedSize)
+	}
+
+	return data, nil
+}
+
+// ErrEmptyFeed - в фиде нет ни одной подсети. Скорее всего, он скачался не полностью,
+// и синхронизация удалила бы из списка все его подсети.
+var ErrEmptyFeed = errors.New("feed has no entries")
+
+// Result - разобранный фид.
+type Result struct {
+	Subnets []domain.Subnet
+	// Invalid - число строк, которые не удалось разобрать. Такие строки пропускаются.
+	Invalid int
+}
+
+// Parse разбирает фид. Подсети приводятся к виду domain.CanonicalCIDR, повторы отбрасываются.
+func Parse(format Format, data []byte) (Result, error) {
+	var result Result
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		cidr, comment, ok := parseLine(format, strings.TrimSpace(scanner.Text()))
+		if !ok {
+			continue
+		}
+
+		canonical, err := domain.CanonicalCIDR(cidr)
+		if err != nil {
+			result.Invalid++
+			continue
+		}
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+
+		result.Subnets = append(result.Subnets, domain.Subnet{CIDR: canonical, Comment: comment})
+	}
+	if err := scanner.Err(); err != nil {
+		return Result{}, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	if len(result.Subnets) == 0 {
+		return result, ErrEmptyFeed
+	}
+
+	return result, nil
+}
+
+// parseLine возвращает подсеть и комментарий строки. ok равен false у пустых строк и строк-комментариев.
+func parseLine(format Format, line string) (cidr, comment string, ok bool) {
+	if line == "" {
+		return "", "", false
+	}
+
+	switch format {
+	case FormatSpamhaus:
+		if strings.HasPrefix(line, "{") {
+			return parseSpamhausJSON(line)
+		}
+		cidr, comment, _ = strings.Cut(line, ";")
+	case FormatFireHOL:
+		cidr, comment, _ = strings.Cut(line, "#")
+	case FormatPlain:
+		cidr, comment, _ = strings.Cut(line, "#")
+		if before, after, found := strings.Cut(cidr, ";"); found {
+			cidr, comment = before, after
+		}
+	}
+
+	cidr = strings.TrimSpace(cidr)
+	if cidr == "" {
+		return "", "", false
+	}
+
+	return cidr, strings.TrimSpace(comment), true
+}
+
+func parseSpamhausJSON(line string) (cidr, comment string, ok bool) {
+	var entry struct {
+		CIDR  string `json:"cidr"`
+		SBLID string `json:"sblid"`
+	}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		// Строка посчитается некорректной при разборе подсети
+		return line, "", true
+	}
+	// Строка с метаданными списка ({"type": "metadata", ...}) не содержит cidr
+	if entry.CIDR == "" {
+		return "", "", false
+	}
+
+	return entry.CIDR, entry.SBLID, true
+}
@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		data    string
+		subnets []domain.Subnet
+		invalid int
+	}{
+		{
+			name:   "spamhaus drop",
+			format: FormatSpamhaus,
+			data: "; Spamhaus DROP List 2026/10/17 - (c) 2026 The Spamhaus Project\n" +
+				"; Last-Modified: Fri, 17 Oct 2026 10:00:00 GMT\n" +
+				"1.10.16.0/20 ; SBL256894\n" +
+				"2.56.192.0/22 ; SBL459831\n" +
+				"not-a-subnet ; SBL1\n",
+			subnets: []domain.Subnet{
+				{CIDR: "1.10.16.0/20", Comment: "SBL256894"},
+				{CIDR: "2.56.192.0/22", Comment: "SBL459831"},
+			},
+			invalid: 1,
+		},
+		{
+			name:   "spamhaus json",
+			format: FormatSpamhaus,
+			data: `{"cidr":"1.10.16.0/20","sblid":"SBL256894","rir":"apnic"}` + "\n" +
+				`{"cidr":"2001:db8::/32","sblid":"SBL1","rir":"ripencc"}` + "\n" +
+				`{"type":"metadata","timestamp":1760695200,"size":2}` + "\n",
+			subnets: []domain.Subnet{
+				{CIDR: "1.10.16.0/20", Comment: "SBL256894"},
+				{CIDR: "2001:db8::/32", Comment: "SBL1"},
+			},
+		},
+		{
+			name:   "firehol netset",
+			format: FormatFireHOL,
+			data: "#\n# firehol_level1\n#\n" +
+				"0.0.0.0/8\n" +
+				"5.188.10.7\n" +
+				"5.188.10.7/32\n",
+			subnets: []domain.Subnet{
+				{CIDR: "0.0.0.0/8"},
+				{CIDR: "5.188.10.7/32"},
+			},
+		},
+		{
+			name:   "plain",
+			format: FormatPlain,
+			data: "  192.0.2.0/24   # test net\n" +
+				"198.51.100.0/24; another\n" +
+				"198.51.100.1/24\n\n",
+			subnets: []domain.Subnet{
+				{CIDR: "192.0.2.0/24", Comment: "test net"},
+				{CIDR: "198.51.100.0/24", Comment: "another"},
+			},
+			invalid: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Parse(tt.format, []byte(tt.data))
+			require.NoError(t, err)
+			assert.Equal(t, tt.subnets, result.Subnets)
+			assert.Equal(t, tt.invalid, result.Invalid)
+		})
+	}
+}
+
+func TestParse_RejectsEmptyFeed(t *testing.T) {
+	_, err := Parse(FormatFireHOL, []byte("# nothing here\n\n"))
+	require.ErrorIs(t, err, ErrEmptyFeed)
+
+	_, err = Parse(FormatPlain, []byte("<html>Service Unavailable</html>\n"))
+	require.ErrorIs(t, err, ErrEmptyFeed)
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/drop.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("1.10.16.0/20 ; SBL256894\n"))
+	}))
+	defer server.Close()
+
+	data, err := Fetch(context.Background(), server.Client(), server.URL+"/drop.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "1.10.16.0/20 ; SBL256894\n", string(data))
+
+	_, err = Fetch(context.Background(), server.Client(), server.URL+"/missing.txt")
+	require.ErrorContains(t, err, "404")
+
+	path := filepath.Join(t.TempDir(), "feed.netset")
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.0/24\n"), 0o600))
+
+	data, err = Fetch(context.Background(), server.Client(), path)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.0/24\n", string(data))
+}
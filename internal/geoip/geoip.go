@@ -0,0 +1,87 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Reader определяет страну и ASN адреса по локальным базам MaxMind (.mmdb): GeoLite2/GeoIP2 Country или City
+// и GeoLite2 ASN. Страна и ASN могут быть и в одной базе, например в GeoIP2 Enterprise.
+type Reader struct {
+	databases []*maxminddb.Reader
+}
+
+// record - поля баз Country, City и ASN, нужные для правил. Отсутствующие в базе поля остаются пустыми.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// Open открывает базы по путям. Пустые пути пропускаются.
+func Open(paths ...string) (*Reader, error) {
+	reader := &Reader{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		database, err := maxminddb.Open(path)
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		reader.databases = append(reader.databases, database)
+	}
+
+	if len(reader.databases) == 0 {
+		return nil, errors.New("no GeoIP databases configured")
+	}
+
+	return reader, nil
+}
+
+// Lookup возвращает страну и ASN адреса. Адреса, которых нет в базах, возвращаются с пустыми полями.
+func (r *Reader) Lookup(addr netip.Addr) (domain.GeoInfo, error) {
+	var info domain.GeoInfo
+	ip := addr.Unmap().AsSlice()
+
+	for _, database := range r.databases {
+		var rec record
+		if err := database.Lookup(ip, &rec); err != nil {
+			return domain.GeoInfo{}, fmt.Errorf("failed to look up %s: %w", addr, err)
+		}
+
+		if info.Country == "" {
+			info.Country = rec.Country.ISOCode
+		}
+		// Для адресов анонимных прокси и спутниковых провайдеров страна не определена,
+		// но известна страна регистрации сети
+		if info.Country == "" {
+			info.Country = rec.RegisteredCountry.ISOCode
+		}
+		if info.ASN == 0 {
+			info.ASN, info.ASOrg = rec.ASN, rec.ASOrg
+		}
+	}
+
+	return info, nil
+}
+
+func (r *Reader) Close() error {
+	var errs []error
+	for _, database := range r.databases {
+		errs = append(errs, database.Close())
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+-- Алгоритмы лимитеров, общие для allow.lua и inspect.lua.
+--
+-- check каждого алгоритма не изменяет состояние и возвращает ok - пропустит ли лимитер запрос,
+-- available - сколько единиц доступно до списания (может быть дробным), retry_after - через сколько секунд
+-- лимитер пропустит запрос, если сейчас отказал, full_after - через сколько секунд лимитер полностью восстановится.
+-- Остальные поля передаются в commit, который списывает единицу.
+
+local function token_bucket_check(key, limit, window, now)
+	local state = redis.call('HMGET', key, 'allowance', 'timestamp')
+	local allowance = tonumber(state[1])
+	local timestamp = tonumber(state[2])
+	if allowance == nil or timestamp == nil then
+		allowance = limit
+		timestamp = now
+	end
+
+	local elapsed = math.max(now - timestamp, 0)
+	allowance = math.min(allowance + elapsed * limit / window, limit)
+
+	local retry_after = 0
+	if allowance < 1 then
+		retry_after = (1 - allowance) * window / limit
+	end
+
+	return {
+		ok = allowance >= 1,
+		available = allowance,
+		retry_after = retry_after,
+		full_after = (limit - allowance) * window / limit,
+		allowance = allowance,
+	}
+end
+
+local function token_bucket_commit(key, state, limit, window, now)
+	redis.call('HSET', key, 'allowance', string.format('%.6f', state.allowance - 1), 'timestamp', now)
+	redis.call('EXPIRE', key, window + 1)
+end
+
+local function fixed_window_check(key, limit, window, now)
+	local start = now - now % window
+	local state = redis.call('HMGET', key, 'start', 'count')
+	local count = tonumber(state[2]) or 0
+	if tonumber(state[1]) ~= start then
+		count = 0
+	end
+
+	local retry_after = 0
+	if count >= limit then
+		retry_after = start + window - now
+	end
+
+	local full_after = 0
+	if count > 0 then
+		full_after = start + window - now
+	end
+
+	return {
+		ok = count < limit,
+		available = limit - count,
+		retry_after = retry_after,
+		full_after = full_after,
+		start = start,
+		count = count,
+	}
+end
+
+local function fixed_window_commit(key, state, limit, window, now)
+	redis.call('HSET', key, 'start', state.start, 'count', state.count + 1)
+	redis.call('EXPIRE', key, state.start + window - now + 1)
+end
+
+local function sliding_window_check(key, limit, window, now)
+	local start = now - now % window
+	local state = redis.call('HMGET', key, 'start', 'current', 'previous')
+	local stored = tonumber(state[1])
+	local current = tonumber(state[2]) or 0
+	local previous = tonumber(state[3]) or 0
+
+	if stored == start - window then
+		previous = current
+		current = 0
+	elseif stored ~= start then
+		previous = 0
+		current = 0
+	end
+
+	local weight = (window - (now - start)) / window
+	local estimate = previous * weight + current
+	local ok = estimate + 1 <= limit
+
+	local retry_after = 0
+	if not ok and current + 1 <= limit then
+		-- Вес предыдущего окна должен снизиться настолько, чтобы оценка освободила место под запрос
+		retry_after = start + window * (1 - (limit - 1 - current) / previous) - now
+	elseif not ok then
+		-- Текущее окно исчерпано: в следующем окне его запросы станут предыдущими
+		retry_after = start + window + window * (1 - (limit - 1) / current) - now
+	end
+
+	local full_after = 0
+	if current > 0 then
+		full_after = start + 2 * window - now
+	elseif previous > 0 then
+		full_after = start + window - now
+	end
+
+	return {
+		ok = ok,
+		available = limit - estimate,
+		retry_after = retry_after,
+		full_after = full_after,
+		start = start,
+		current = current,
+		previous = previous,
+	}
+end
+
+local function sliding_window_commit(key, state, limit, window, now)
+	redis.call('HSET', key, 'start', state.start, 'current', state.current + 1, 'previous', state.previous)
+	redis.call('EXPIRE', key, state.start + 2 * window - now + 1)
+end
+
+local function sliding_log_check(key, limit, window, now)
+	local entries = redis.call('LRANGE', key, 0, -1)
+	local expired = 0
+	for _, entry in ipairs(entries) do
+		if tonumber(entry) > now - window then
+			break
+		end
+		expired = expired + 1
+	end
+
+	local count = #entries - expired
+	local retry_after = 0
+	if count >= limit then
+		-- Запрос пройдёт, когда из окна выйдет достаточно старых записей
+		retry_after = tonumber(entries[expired + count - limit + 1]) + window - now
+	end
+
+	local full_after = 0
+	if count > 0 then
+		full_after = tonumber(entries[#entries]) + window - now
+	end
+
+	return {
+		ok = count < limit,
+		available = limit - count,
+		retry_after = retry_after,
+		full_after = full_after,
+		expired = expired,
+	}
+end
+
+local function sliding_log_commit(key, state, limit, window, now)
+	if state.expired > 0 then
+		redis.call('LTRIM', key, state.expired, -1)
+	end
+	redis.call('RPUSH', key, now)
+	redis.call('EXPIRE', key, window + 1)
+end
+
+local function gcra_check(key, limit, window, now)
+	local interval = window / limit
+	local tat = math.max(tonumber(redis.call('GET', key)) or now, now)
+	local new_tat = tat + interval
+	local ok = new_tat - now <= window
+
+	local retry_after = 0
+	if not ok then
+		retry_after = new_tat - window - now
+	end
+
+	return {
+		ok = ok,
+		available = (window - (tat - now)) / interval,
+		retry_after = retry_after,
+		full_after = tat - now,
+		tat = new_tat,
+	}
+end
+
+local function gcra_commit(key, state, limit, window, now)
+	redis.call('SET', key, string.format('%.6f', state.tat))
+	redis.call('EXPIRE', key, math.ceil(state.tat - now) + 1)
+end
+
+-- type - тип ключа Redis, в котором алгоритм хранит состояние, field - поле, отличающее хеши разных алгоритмов.
+local algorithms = {
+	token_bucket = {type = 'hash', field = 'allowance', check = token_bucket_check, commit = token_bucket_commit},
+	fixed_window = {type = 'hash', field = 'count', check = fixed_window_check, commit = fixed_window_commit},
+	sliding_window = {type = 'hash', field = 'current', check = sliding_window_check, commit = sliding_window_commit},
+	sliding_log = {type = 'list', check = sliding_log_check, commit = sliding_log_commit},
+	gcra = {type = 'string', check = gcra_check, commit = gcra_commit},
+}
+
+-- После смены алгоритма в конфигурации по ключу может лежать состояние другого алгоритма.
+local function is_foreign_state(key, algorithm)
+	local kind = redis.call('TYPE', key).ok
+	if kind == 'none' then
+		return false
+	end
+	if kind ~= algorithm.type then
+		return true
+	end
+
+	return algorithm.type == 'hash' and redis.call('HEXISTS', key, algorithm.field) == 0
+end
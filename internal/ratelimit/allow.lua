@@ -0,0 +1,68 @@
+-- Атомарная проверка нескольких лимитеров за один вызов.
+-- KEYS - для каждого лимитера его ключ, за которым следуют ключи, полученные предыдущими секретами (см. keys.go).
+-- ARGV[1] - текущее unix-время в секундах, далее четвёрки algorithm, limit, window (сек), число предыдущих ключей.
+-- Сначала выполняется проверка всех лимитеров, и только если все разрешают запрос, списывается по одной единице.
+-- Возвращает {rejected, remaining, retry_after}: rejected - 0, если запрос разрешён, иначе 1-based индекс
+-- первого отклонившего лимитера; remaining - сколько запросов ещё пропустит самый строгий из лимитеров;
+-- retry_after - через сколько секунд отклонивший лимитер пропустит запрос.
+--
+-- Скрипт собирается из algorithms.lua и этого файла.
+
+-- После смены секрета состояние лежит под ключом, полученным предыдущим секретом, и переносится под текущий.
+local function migrate_previous_state(key, first, last)
+	if redis.call('EXISTS', key) == 1 then
+		return
+	end
+
+	for i = first, last do
+		if redis.call('EXISTS', KEYS[i]) == 1 then
+			redis.call('RENAME', KEYS[i], key)
+			return
+		end
+	end
+end
+
+local now = tonumber(ARGV[1])
+local limiters = {}
+local next_key = 1
+
+for arg = 2, #ARGV, 4 do
+	local algorithm = algorithms[ARGV[arg]]
+	if algorithm == nil then
+		return redis.error_reply('unknown rate limit algorithm: ' .. tostring(ARGV[arg]))
+	end
+
+	local key = KEYS[next_key]
+	local previous = tonumber(ARGV[arg + 3])
+	migrate_previous_state(key, next_key + 1, next_key + previous)
+	next_key = next_key + previous + 1
+
+	local limit = tonumber(ARGV[arg + 1])
+	local window = tonumber(ARGV[arg + 2])
+	-- Состояние другого алгоритма не интерпретируется, а отбрасывается
+	if is_foreign_state(key, algorithm) then
+		redis.call('DEL', key)
+	end
+	-- Нулевой лимит не пропускает ни одного запроса, формулы алгоритмов на него не рассчитаны
+	if limit < 1 then
+		return {#limiters + 1, 0, math.max(window, 1)}
+	end
+	local state = algorithm.check(key, limit, window, now)
+	if not state.ok then
+		return {#limiters + 1, 0, math.max(math.ceil(state.retry_after), 1)}
+	end
+
+	table.insert(limiters, {key = key, algorithm = algorithm, limit = limit, window = window, state = state})
+end
+
+local remaining = nil
+for _, limiter in ipairs(limiters) do
+	limiter.algorithm.commit(limiter.key, limiter.state, limiter.limit, limiter.window, now)
+
+	local left = math.floor(limiter.state.available - 1 + 1e-9)
+	if remaining == nil or left < remaining then
+		remaining = left
+	end
+end
+
+return {0, math.max(remaining or 0, 0), 0}
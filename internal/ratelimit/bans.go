@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrBanned = errors.New("banned")
+
+// BanConfig - прогрессивные баны логинов и IP, которые раз за разом исчерпывают свои бакеты.
+type BanConfig struct {
+	// Threshold - после скольких отказов за Period логин или IP банится. 0 отключает баны.
+	Threshold int
+	Period    time.Duration
+	// Durations - длительности первого, второго и следующих банов. Дальше повторяется последняя.
+	Durations []time.Duration
+	// ForgetAfter - через сколько после окончания бана уровень сбрасывается. По умолчанию - самая длинная из Durations.
+	ForgetAfter time.Duration
+}
+
+// Ban - действующий бан логина или IP.
+type Ban struct {
+	Bucket Bucket
+	// Level - номер бана подряд, от него зависит длительность.
+	Level int
+	Until time.Time
+}
+
+// BannedError сообщает, что логин или IP забанены, и когда бан закончится.
+type BannedError struct {
+	Ban
+	RetryAfter time.Duration
+}
+
+func (e *BannedError) Error() string {
+	return fmt.Sprintf("%s banned (level %d)", e.Bucket, e.Level)
+}
+
+func (e *BannedError) Is(target error) bool {
+	return target == ErrBanned
+}
+
+// banPolicy - BanConfig в секундах, в которых работают бэкенды.
+type banPolicy struct {
+	threshold   int
+	period      int64
+	forgetAfter int64
+	durations   []int64
+}
+
+func newBanPolicy(config BanConfig) (banPolicy, error) {
+	if config.Threshold <= 0 {
+		return banPolicy{}, nil
+	}
+	if config.Period < time.Second || len(config.Durations) == 0 {
+		return banPolicy{}, errors.New("ban period and durations are required when ban threshold is set")
+	}
+
+	policy := banPolicy{
+		threshold:   config.Threshold,
+		period:      int64(config.Period / time.Second),
+		forgetAfter: int64(config.ForgetAfter / time.Second),
+	}
+	for _, duration := range config.Durations {
+		if duration < time.Second {
+			return banPolicy{}, fmt.Errorf("ban duration is too short: %s", duration)
+		}
+		policy.durations = append(policy.durations, int64(duration/time.Second))
+		if config.ForgetAfter <= 0 {
+			policy.forgetAfter = max(policy.forgetAfter, int64(duration/time.Second))
+		}
+	}
+
+	return policy, nil
+}
+
+func (p banPolicy) enabled() bool {
+	return p.threshold > 0
+}
+
+// duration возвращает длительность бана уровня level (с 1) в секундах.
+func (p banPolicy) duration(level int) int64 {
+	return p.durations[min(level, len(p.durations))-1]
+}
+
+// banState - состояние бана в бэкенде. until в прошлом означает, что бан закончился, но уровень ещё помнится.
+type banState struct {
+	level int
+	until int64
+}
+
+// banKey и strikesKey получают ключи бана и счётчика отказов из ключа бакета.
+func banKey(bucketKey string) string {
+	return "ratelimit:ban:" + strings.TrimPrefix(bucketKey, "ratelimit:")
+}
+
+func strikesKey(bucketKey string) string {
+	return "ratelimit:strikes:" + strings.TrimPrefix(bucketKey, "ratelimit:")
+}
+
+// CheckBan возвращает *BannedError, если забанен логин или IP. Проверяется до бакетов.
+func (r *RateLimiter) CheckBan(ctx context.Context, login, ip string) error {
+	if !r.bans.enabled() {
+		return nil
+	}
+
+	bans, err := r.Bans(ctx, login, ip)
+	if err != nil {
+		return err
+	}
+	if len(bans) == 0 {
+		return nil
+	}
+
+	// Отвечаем баном, который закончится позже
+	ban := bans[0]
+	for _, other := range bans[1:] {
+		if other.Until.After(ban.Until) {
+			ban = other
+		}
+	}
+
+	retryAfter := time.Duration(ban.Until.Unix()-timeNow().Unix()) * time.Second
+	return &BannedError{Ban: ban, RetryAfter: retryAfter}
+}
+
+// Bans возвращает действующие баны логина и IP. Пустые значения пропускаются.
+func (r *RateLimiter) Bans(ctx context.Context, login, ip string) ([]Ban, error) {
+	var buckets []Bucket
+	var keys [][]string
+
+	if login != "" {
+		loginKey, previousLoginKeys := r.loginKey(login)
+		buckets = append(buckets, BucketLogin)
+		keys = append(keys, append([]string{loginKey}, previousLoginKeys...))
+	}
+	if ip != "" {
+		buckets = append(buckets, BucketIP)
+		keys = append(keys, []string{r.ipKey(ip)})
+	}
+
+	var banKeys []string
+	for _, subjectKeys := range keys {
+		for _, key := range subjectKeys {
+			banKeys = append(banKeys, banKey(key))
+		}
+	}
+	if len(banKeys) == 0 {
+		return nil, nil
+	}
+
+	now := timeNow().Unix()
+	states, err := r.backend.bans(ctx, banKeys, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bans: %w", err)
+	}
+
+	var bans []Ban
+	for i, subjectKeys := range keys {
+		var latest banState
+		for range subjectKeys {
+			if states[0].until > latest.until {
+				latest = states[0]
+			}
+			states = states[1:]
+		}
+
+		if latest.until > now {
+			bans = append(bans, Ban{Bucket: buckets[i], Level: latest.level, Until: time.Unix(latest.until, 0)})
+		}
+	}
+
+	return bans, nil
+}
+
+// strike засчитывает отказ бакета логина или IP. Если он привёл к бану, возвращает *BannedError.
+func (r *RateLimiter) strike(ctx context.Context, bucket Bucket, bucketKey string) error {
+	if !r.bans.enabled() || (bucket != BucketLogin && bucket != BucketIP) {
+		return nil
+	}
+
+	now := timeNow().Unix()
+	state, err := r.backend.strike(ctx, strikesKey(bucketKey), banKey(bucketKey), r.bans, now)
+	if err != nil {
+		return fmt.Errorf("failed to record rejection: %w", err)
+	}
+	if state.until <= now {
+		return nil
+	}
+
+	return &BannedError{
+		Ban:        Ban{Bucket: bucket, Level: state.level, Until: time.Unix(state.until, 0)},
+		RetryAfter: time.Duration(state.until-now) * time.Second,
+	}
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var banTestConfig = Config{
+	LoginLimit:    100,
+	PasswordLimit: 100,
+	IPLimit:       1,
+	Window:        60,
+	Bans: BanConfig{
+		Threshold: 3,
+		Period:    10 * time.Minute,
+		Durations: []time.Duration{time.Minute, 10 * time.Minute, time.Hour},
+	},
+}
+
+// exhaustIP исчерпывает бакет IP и возвращает ошибку отказа, на котором IP был забанен.
+func exhaustIP(t *testing.T, limiter *RateLimiter, ip string) *BannedError {
+	t.Helper()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		_, err := limiter.Check(ctx, fmt.Sprintf("user%d", i), "secret", ip)
+		var banErr *BannedError
+		if errors.As(err, &banErr) {
+			return banErr
+		}
+	}
+
+	require.Fail(t, "ip was not banned")
+	return nil
+}
+
+func TestRateLimiter_EscalatingBans(t *testing.T) {
+	for _, tb := range testBackends {
+		t.Run(tb.name, func(t *testing.T) {
+			freezeTime(t)
+			limiter, err := newRateLimiter(tb.setup(t)(), banTestConfig)
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			require.NoError(t, limiter.CheckBan(ctx, "user", "10.0.0.1"))
+
+			for _, expected := range []time.Duration{time.Minute, 10 * time.Minute, time.Hour, time.Hour} {
+				banErr := exhaustIP(t, limiter, "10.0.0.1")
+				assert.Equal(t, BucketIP, banErr.Bucket)
+				assert.Equal(t, expected, banErr.RetryAfter)
+
+				err := limiter.CheckBan(ctx, "user", "10.0.0.1")
+				require.ErrorIs(t, err, ErrBanned)
+				require.True(t, errors.As(err, &banErr))
+				assert.Equal(t, expected, banErr.RetryAfter)
+
+				// Бан касается только IP
+				require.NoError(t, limiter.CheckBan(ctx, "user", "10.0.0.2"))
+
+				advanceTime(expected)
+				require.NoError(t, limiter.CheckBan(ctx, "user", "10.0.0.1"))
+			}
+		})
+	}
+}
+
+func TestRateLimiter_BanLevelIsForgotten(t *testing.T) {
+	for _, tb := range testBackends {
+		t.Run(tb.name, func(t *testing.T) {
+			freezeTime(t)
+			config := banTestConfig
+			config.Bans.ForgetAfter = time.Hour
+			limiter, err := newRateLimiter(tb.setup(t)(), config)
+			require.NoError(t, err)
+
+			assert.Equal(t, time.Minute, exhaustIP(t, limiter, "10.0.0.1").RetryAfter)
+			advanceTime(time.Minute + time.Hour)
+
+			assert.Equal(t, time.Minute, exhaustIP(t, limiter, "10.0.0.1").RetryAfter)
+		})
+	}
+}
+
+func TestRateLimiter_ResetLiftsBan(t *testing.T) {
+	for _, tb := range testBackends {
+		t.Run(tb.name, func(t *testing.T) {
+			freezeTime(t)
+			limiter, err := newRateLimiter(tb.setup(t)(), banTestConfig)
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			exhaustIP(t, limiter, "10.0.0.1")
+
+			bans, err := limiter.Bans(ctx, "", "10.0.0.1")
+			require.NoError(t, err)
+			require.Len(t, bans, 1)
+			assert.Equal(t, Ban{Bucket: BucketIP, Level: 1, Until: timeNow().Add(time.Minute)}, bans[0])
+
+			require.NoError(t, limiter.ResetBuckets(ctx, "", "10.0.0.1"))
+
+			require.NoError(t, limiter.CheckBan(ctx, "", "10.0.0.1"))
+			bans, err = limiter.Bans(ctx, "", "10.0.0.1")
+			require.NoError(t, err)
+			assert.Empty(t, bans)
+
+			// Уровень тоже сброшен: следующий бан снова первый
+			assert.Equal(t, time.Minute, exhaustIP(t, limiter, "10.0.0.1").RetryAfter)
+		})
+	}
+}
+
+func TestRateLimiter_BansDisabledByDefault(t *testing.T) {
+	freezeTime(t)
+	limiter, _, _ := setupRateLimiter(t, Config{LoginLimit: 1, PasswordLimit: 100, IPLimit: 100, Window: 60})
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		_, err := limiter.Check(ctx, "user", "secret", "10.0.0.1")
+		require.NotErrorIs(t, err, ErrBanned)
+	}
+	require.NoError(t, limiter.CheckBan(ctx, "user", "10.0.0.1"))
+}
+
+func TestNewRateLimiter_InvalidBanConfig(t *testing.T) {
+	_, err := newRateLimiter(nil, Config{Window: 60, Bans: BanConfig{Threshold: 3}})
+	assert.Error(t, err)
+}
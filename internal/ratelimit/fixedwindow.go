@@ -0,0 +1,17 @@
+package ratelimit
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// FixedWindow считает запросы в окнах длиной window секунд, выровненных по unix-времени,
+// и сбрасывает счётчик в начале каждого окна.
+type FixedWindow struct {
+	baseLimiter
+}
+
+func NewFixedWindow(client *redis.Client, key string, limit, window int) *FixedWindow {
+	return &FixedWindow{
+		baseLimiter: newBaseLimiter(AlgorithmFixedWindow, newRedisBackend(client), key, limit, window),
+	}
+}
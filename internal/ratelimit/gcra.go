@@ -0,0 +1,17 @@
+package ratelimit
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// GCRA (generic cell rate algorithm) хранит только теоретическое время прибытия следующего запроса.
+// Запросы равномерно распределяются с интервалом window/limit, допускается всплеск до limit запросов.
+type GCRA struct {
+	baseLimiter
+}
+
+func NewGCRA(client *redis.Client, key string, limit, window int) *GCRA {
+	return &GCRA{
+		baseLimiter: newBaseLimiter(AlgorithmGCRA, newRedisBackend(client), key, limit, window),
+	}
+}
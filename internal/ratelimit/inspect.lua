@@ -0,0 +1,43 @@
+-- Просмотр состояния нескольких лимитеров без списания.
+-- KEYS и ARGV - как в allow.lua. Скрипт собирается из algorithms.lua и этого файла.
+-- Возвращает для каждого лимитера {available, full_after} строками, чтобы не терять дробную часть.
+
+-- Пока состояние не перенесено под ключ текущего секрета, читается ключ предыдущего.
+local function current_key(first, last)
+	for i = first, last do
+		if redis.call('EXISTS', KEYS[i]) == 1 then
+			return KEYS[i]
+		end
+	end
+
+	return KEYS[first]
+end
+
+local now = tonumber(ARGV[1])
+local result = {}
+local next_key = 1
+
+for arg = 2, #ARGV, 4 do
+	local algorithm = algorithms[ARGV[arg]]
+	if algorithm == nil then
+		return redis.error_reply('unknown rate limit algorithm: ' .. tostring(ARGV[arg]))
+	end
+
+	local previous = tonumber(ARGV[arg + 3])
+	local key = current_key(next_key, next_key + previous)
+	next_key = next_key + previous + 1
+
+	local limit = tonumber(ARGV[arg + 1])
+	local window = tonumber(ARGV[arg + 2])
+	local available, full_after = limit, 0
+	if limit < 1 then
+		available = 0
+	elseif not is_foreign_state(key, algorithm) then
+		local state = algorithm.check(key, limit, window, now)
+		available, full_after = math.max(state.available, 0), math.max(state.full_after, 0)
+	end
+
+	table.insert(result, {string.format('%.6f', available), string.format('%.6f', full_after)})
+end
+
+return result
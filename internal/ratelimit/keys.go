@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keyDigestSize - число байт HMAC, попадающих в ключ. 128 бит достаточно, чтобы исключить коллизии.
+const keyDigestSize = 16
+
+// keyHasher получает ключи бакетов из секретных значений (паролей и, при необходимости, логинов)
+// с помощью HMAC-SHA256, чтобы сами значения не попадали в хранилище.
+// Первый секрет - текущий, остальные - предыдущие. Бакеты, созданные с предыдущими секретами,
+// переносятся под ключ текущего секрета при первом обращении, поэтому смена секрета не сбрасывает лимиты.
+type keyHasher struct {
+	secrets [][]byte
+}
+
+func newKeyHasher(secrets []string) (*keyHasher, error) {
+	hasher := &keyHasher{}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		hasher.secrets = append(hasher.secrets, []byte(secret))
+	}
+
+	if len(hasher.secrets) == 0 {
+		secret := make([]byte, sha256.Size)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate key secret: %w", err)
+		}
+		hasher.secrets = append(hasher.secrets, secret)
+	}
+
+	return hasher, nil
+}
+
+// key возвращает ключ для текущего секрета и ключи для предыдущих.
+func (h *keyHasher) key(prefix, value string) (string, []string) {
+	previous := make([]string, 0, len(h.secrets)-1)
+	for _, secret := range h.secrets[1:] {
+		previous = append(previous, prefix+digest(secret, value))
+	}
+
+	return prefix + digest(h.secrets[0], value), previous
+}
+
+func digest(secret []byte, value string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:keyDigestSize])
+}
+
+// isKeyDigest проверяет, что значение имеет вид digest.
+func isKeyDigest(value string) bool {
+	decoded, err := hex.DecodeString(value)
+	return err == nil && len(decoded) == keyDigestSize
+}
+
+// LoadKeySecrets читает секреты из файла: первая непустая строка - текущий секрет,
+// следующие - предыдущие. Для смены секрета новый секрет дописывается в начало файла.
+func LoadKeySecrets(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key secret file: %w", err)
+	}
+
+	var secrets []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if secret := strings.TrimSpace(line); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	if len(secrets) == 0 {
+		return nil, errors.New("key secret file is empty")
+	}
+
+	return secrets, nil
+}
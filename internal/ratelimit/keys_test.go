@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHook запоминает все аргументы команд, отправленных в Redis.
+type recordingHook struct {
+	mu   sync.Mutex
+	args []string
+}
+
+func (h *recordingHook) record(cmd redis.Cmder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, arg := range cmd.Args() {
+		h.args = append(h.args, fmt.Sprint(arg))
+	}
+}
+
+func (h *recordingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *recordingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.record(cmd)
+		return next(ctx, cmd)
+	}
+}
+
+func (h *recordingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			h.record(cmd)
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func TestRateLimiter_SecretsNeverReachRedis(t *testing.T) {
+	const (
+		login    = "Alice.Secret-Login"
+		password = "Tr0ub4dor&3-very-secret"
+	)
+
+	limiter, mr, client := setupRateLimiter(t, Config{
+		LoginLimit:    2,
+		PasswordLimit: 2,
+		IPLimit:       10,
+		Window:        60,
+		KeySecrets:    []string{"current-secret"},
+		HashLogins:    true,
+	})
+	hook := &recordingHook{}
+	client.AddHook(hook)
+	ctx := context.Background()
+
+	var errs []error
+	for i := 0; i < 4; i++ {
+		if _, err := limiter.Check(ctx, login, password, "10.0.0.1"); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	require.NoError(t, limiter.ResetBuckets(ctx, login, "10.0.0.1"))
+	require.NotEmpty(t, errs)
+
+	for _, value := range []string{login, password} {
+		for _, arg := range hook.args {
+			assert.NotContains(t, arg, value, "secret value sent to Redis")
+		}
+		for _, key := range mr.Keys() {
+			assert.NotContains(t, key, value)
+		}
+		assert.NotContains(t, mr.Dump(), value)
+		for _, err := range errs {
+			assert.NotContains(t, err.Error(), value)
+		}
+	}
+}
+
+func TestRateLimiter_LoginsArePlainByDefault(t *testing.T) {
+	limiter, mr, _ := setupRateLimiter(t, Config{LoginLimit: 2, PasswordLimit: 2, IPLimit: 2, Window: 60})
+
+	require.NoError(t, checkErr(limiter.Check(context.Background(), "user1", "password", "10.0.0.1")))
+
+	assert.True(t, mr.Exists("ratelimit:login:user1"))
+	assert.False(t, mr.Exists("ratelimit:password:password"))
+}
+
+func TestRateLimiter_KeySecretRotation(t *testing.T) {
+	for _, tb := range testBackends {
+		t.Run(tb.name, func(t *testing.T) {
+			freezeTime(t)
+			replica := tb.setup(t)
+			ctx := context.Background()
+
+			config := Config{
+				LoginLimit:    10,
+				PasswordLimit: 3,
+				IPLimit:       10,
+				Window:        60,
+				KeySecrets:    []string{"old-secret"},
+			}
+
+			before, err := newRateLimiter(replica(), config)
+			require.NoError(t, err)
+			for i := 0; i < 2; i++ {
+				require.NoError(t, checkErr(before.Check(ctx, "user", "password", "10.0.0.1")))
+			}
+
+			// Новый секрет добавляется первым, старый остаётся предыдущим
+			config.KeySecrets = []string{"new-secret", "old-secret"}
+			after, err := newRateLimiter(replica(), config)
+			require.NoError(t, err)
+
+			require.NoError(t, checkErr(after.Check(ctx, "user", "password", "10.0.0.2")))
+			assert.ErrorIs(t, checkErr(after.Check(ctx, "user", "password", "10.0.0.3")), ErrLimitExceeded,
+				"bucket must keep its state through the secret change")
+		})
+	}
+}
+
+func TestRateLimiter_RandomSecretWhenNotConfigured(t *testing.T) {
+	first, err := newRateLimiter(NewMemoryBackend(MemoryConfig{}), Config{Window: 60})
+	require.NoError(t, err)
+	second, err := newRateLimiter(NewMemoryBackend(MemoryConfig{}), Config{Window: 60})
+	require.NoError(t, err)
+
+	firstKey, _ := first.hasher.key("ratelimit:password:", "password")
+	secondKey, _ := second.hasher.key("ratelimit:password:", "password")
+	assert.NotEqual(t, firstKey, secondKey)
+}
+
+func TestLoadKeySecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+	require.NoError(t, os.WriteFile(path, []byte("new-secret\n\n  old-secret  \n"), 0o600))
+
+	secrets, err := LoadKeySecrets(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new-secret", "old-secret"}, secrets)
+
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("\n", 3)), 0o600))
+	_, err = LoadKeySecrets(path)
+	assert.Error(t, err)
+}
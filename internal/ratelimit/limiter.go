@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Algorithm string
+
+const (
+	AlgorithmTokenBucket   Algorithm = "token_bucket"
+	AlgorithmSlidingLog    Algorithm = "sliding_log"
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	AlgorithmFixedWindow   Algorithm = "fixed_window"
+	AlgorithmGCRA          Algorithm = "gcra"
+)
+
+// ParseAlgorithm проверяет название алгоритма из конфигурации. Пустая строка означает token bucket.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch algorithm := Algorithm(name); algorithm {
+	case "":
+		return AlgorithmTokenBucket, nil
+	case AlgorithmTokenBucket, AlgorithmSlidingLog, AlgorithmSlidingWindow, AlgorithmFixedWindow, AlgorithmGCRA:
+		return algorithm, nil
+	default:
+		return "", fmt.Errorf("unknown rate limit algorithm: %s", name)
+	}
+}
+
+// Limiter ограничивает число запросов по одному ключу: не больше limit за window секунд.
+type Limiter interface {
+	Allow(ctx context.Context) (bool, error)
+	spec() limiterSpec
+}
+
+type limiterSpec struct {
+	algorithm Algorithm
+	key       string
+	// previousKeys - ключи того же бакета, полученные предыдущими секретами.
+	previousKeys []string
+	limit        int
+	window       int
+}
+
+// timeNow подменяется в тестах.
+var timeNow = time.Now
+
+// backend хранит состояние лимитеров и атомарно проверяет несколько лимитеров за раз.
+type backend interface {
+	// allowAll списывает по единице из каждого лимитера, только если все они разрешают запрос.
+	allowAll(ctx context.Context, specs []limiterSpec, now int64) (decision, error)
+	// inspect возвращает состояние лимитеров с учётом восстановления, ничего не списывая.
+	inspect(ctx context.Context, specs []limiterSpec, now int64) ([]inspection, error)
+	// strike засчитывает отказ и банит субъекта, если отказов за период набралось достаточно.
+	strike(ctx context.Context, strikesKey, banKey string, policy banPolicy, now int64) (banState, error)
+	bans(ctx context.Context, keys []string, now int64) ([]banState, error)
+	reset(ctx context.Context, keys []string) error
+}
+
+// inspection - состояние одного лимитера.
+type inspection struct {
+	// available - сколько единиц доступно сейчас.
+	available float64
+	// fullAfter - через сколько лимитер полностью восстановится.
+	fullAfter time.Duration
+}
+
+// NewLimiter создаёт лимитер с выбранным алгоритмом и состоянием в Redis.
+func NewLimiter(algorithm Algorithm, client *redis.Client, key string, limit, window int) (Limiter, error) {
+	return newLimiter(algorithm, newRedisBackend(client), key, limit, window)
+}
+
+func newLimiter(algorithm Algorithm, backend backend, key string, limit, window int) (Limiter, error) {
+	algorithm, err := ParseAlgorithm(string(algorithm))
+	if err != nil {
+		return nil, err
+	}
+
+	base := newBaseLimiter(algorithm, backend, key, limit, window)
+
+	switch algorithm {
+	case AlgorithmTokenBucket:
+		return &TokenBucket{baseLimiter: base}, nil
+	case AlgorithmSlidingLog:
+		return &SlidingWindowLog{baseLimiter: base}, nil
+	case AlgorithmSlidingWindow:
+		return &SlidingWindowCounter{baseLimiter: base}, nil
+	case AlgorithmFixedWindow:
+		return &FixedWindow{baseLimiter: base}, nil
+	case AlgorithmGCRA:
+		return &GCRA{baseLimiter: base}, nil
+	}
+
+	return nil, fmt.Errorf("unknown rate limit algorithm: %s", algorithm)
+}
+
+// decision - результат проверки набора лимитеров.
+type decision struct {
+	// rejected - индекс отклонившего лимитера или -1, если запрос разрешён.
+	rejected int
+	// remaining - сколько запросов ещё пропустит самый строгий из лимитеров.
+	remaining int
+	// retryAfter - через сколько отклонивший лимитер пропустит запрос.
+	retryAfter time.Duration
+}
+
+func allowAll(ctx context.Context, backend backend, limiters []Limiter) (decision, error) {
+	specs := make([]limiterSpec, 0, len(limiters))
+	for _, limiter := range limiters {
+		specs = append(specs, limiter.spec())
+	}
+
+	return backend.allowAll(ctx, specs, timeNow().Unix())
+}
+
+// baseLimiter - общая часть лимитеров. Сами алгоритмы реализованы бэкендами:
+// в algorithms.lua для Redis и в memory.go для хранения в памяти процесса.
+type baseLimiter struct {
+	backend   backend
+	algorithm Algorithm
+	key       string
+	limit     int
+	window    int
+}
+
+func newBaseLimiter(algorithm Algorithm, backend backend, key string, limit, window int) baseLimiter {
+	return baseLimiter{
+		backend:   backend,
+		algorithm: algorithm,
+		key:       key,
+		limit:     limit,
+		window:    window,
+	}
+}
+
+func (l *baseLimiter) Allow(ctx context.Context) (bool, error) {
+	decision, err := allowAll(ctx, l.backend, []Limiter{l})
+	if err != nil {
+		return false, err
+	}
+
+	return decision.rejected < 0, nil
+}
+
+func (l *baseLimiter) spec() limiterSpec {
+	return limiterSpec{
+		algorithm: l.algorithm,
+		key:       l.key,
+		limit:     l.limit,
+		window:    l.window,
+	}
+}
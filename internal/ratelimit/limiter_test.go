@@ -0,0 +1,319 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var algorithms = []Algorithm{
+	AlgorithmTokenBucket,
+	AlgorithmSlidingLog,
+	AlgorithmSlidingWindow,
+	AlgorithmFixedWindow,
+	AlgorithmGCRA,
+}
+
+var frozenTime time.Time
+
+// freezeTime фиксирует время, которое видят лимитеры. Сдвинуть его можно через advanceTime.
+func freezeTime(t *testing.T) {
+	t.Helper()
+
+	frozenTime = time.Unix(1_700_000_000, 0)
+	timeNow = func() time.Time { return frozenTime }
+	t.Cleanup(func() { timeNow = time.Now })
+}
+
+func advanceTime(d time.Duration) {
+	frozenTime = frozenTime.Add(d)
+}
+
+// testBackend создаёт бакенд для теста. replica возвращает бакенд, разделяющий состояние с первым,
+// как это делают разные экземпляры сервиса.
+type testBackend struct {
+	name  string
+	setup func(t *testing.T) (replica func() backend)
+}
+
+var testBackends = []testBackend{
+	{
+		name: "redis",
+		setup: func(t *testing.T) func() backend {
+			t.Helper()
+
+			mr, err := miniredis.Run()
+			require.NoError(t, err)
+			t.Cleanup(mr.Close)
+
+			return func() backend {
+				client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+				t.Cleanup(func() { client.Close() })
+				return newRedisBackend(client)
+			}
+		},
+	},
+	{
+		name: "memory",
+		setup: func(t *testing.T) func() backend {
+			t.Helper()
+
+			memory := NewMemoryBackend(MemoryConfig{Shards: 4, MaxKeys: 1000})
+			return func() backend { return memory }
+		},
+	},
+}
+
+// TestLimiterConformance - общий набор проверок, которому должен соответствовать каждый алгоритм на каждом бакенде.
+func TestLimiterConformance(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, algorithm Algorithm, replica func() backend)
+	}{
+		{"AllowsUpToLimit", testAllowsUpToLimit},
+		{"IndependentKeys", testIndependentKeys},
+		{"RecoversAfterWindow", testRecoversAfterWindow},
+		{"ConcurrentCallersNeverExceedLimit", testConcurrentCallers},
+		{"DiscardsStateOfOtherAlgorithm", testDiscardsStateOfOtherAlgorithm},
+		{"ReportsRemainingAndRetryAfter", testReportsRemainingAndRetryAfter},
+		{"InspectionDoesNotConsume", testInspectionDoesNotConsume},
+	}
+
+	for _, tb := range testBackends {
+		for _, algorithm := range algorithms {
+			for _, tt := range tests {
+				t.Run(tb.name+"/"+string(algorithm)+"/"+tt.name, func(t *testing.T) {
+					freezeTime(t)
+					tt.run(t, algorithm, tb.setup(t))
+				})
+			}
+		}
+	}
+}
+
+func newTestLimiter(t *testing.T, algorithm Algorithm, backend backend, key string, limit, window int) Limiter {
+	t.Helper()
+
+	limiter, err := newLimiter(algorithm, backend, key, limit, window)
+	require.NoError(t, err)
+
+	return limiter
+}
+
+func testAllowsUpToLimit(t *testing.T, algorithm Algorithm, replica func() backend) {
+	t.Helper()
+
+	limiter := newTestLimiter(t, algorithm, replica(), "test:limit", 5, 60)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		allowed, err := limiter.Allow(ctx)
+		require.NoError(t, err)
+		assert.True(t, allowed, "request %d should be allowed", i+1)
+	}
+
+	allowed, err := limiter.Allow(ctx)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func testIndependentKeys(t *testing.T, algorithm Algorithm, replica func() backend) {
+	t.Helper()
+
+	backend := replica()
+	first := newTestLimiter(t, algorithm, backend, "test:first", 1, 60)
+	second := newTestLimiter(t, algorithm, backend, "test:second", 1, 60)
+	ctx := context.Background()
+
+	allowed, err := first.Allow(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = first.Allow(ctx)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = second.Allow(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func testRecoversAfterWindow(t *testing.T, algorithm Algorithm, replica func() backend) {
+	t.Helper()
+
+	limiter := newTestLimiter(t, algorithm, replica(), "test:recover", 3, 10)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(ctx)
+		require.NoError(t, err)
+		require.True(t, allowed)
+	}
+
+	allowed, err := limiter.Allow(ctx)
+	require.NoError(t, err)
+	require.False(t, allowed)
+
+	// Через два окна лимит должен полностью восстановиться при любом алгоритме
+	advanceTime(20 * time.Second)
+
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(ctx)
+		require.NoError(t, err)
+		assert.True(t, allowed, "request %d after window should be allowed", i+1)
+	}
+}
+
+func testConcurrentCallers(t *testing.T, algorithm Algorithm, replica func() backend) {
+	t.Helper()
+
+	const (
+		limit    = 10
+		replicas = 4
+		callers  = 25
+	)
+
+	ctx := context.Background()
+	var allowedCount atomic.Int64
+	var wg sync.WaitGroup
+
+	for r := 0; r < replicas; r++ {
+		backend := replica()
+
+		for c := 0; c < callers; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				limiter, err := newLimiter(algorithm, backend, "test:concurrent", limit, 3600)
+				if !assert.NoError(t, err) {
+					return
+				}
+				allowed, err := limiter.Allow(ctx)
+				assert.NoError(t, err)
+				if allowed {
+					allowedCount.Add(1)
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int64(limit), allowedCount.Load())
+}
+
+func testDiscardsStateOfOtherAlgorithm(t *testing.T, algorithm Algorithm, replica func() backend) {
+	t.Helper()
+
+	backend := replica()
+	ctx := context.Background()
+
+	// Состояние, оставленное другими алгоритмами до смены конфигурации, не должно ломать проверку
+	for _, other := range algorithms {
+		if other == algorithm {
+			continue
+		}
+		_, err := newTestLimiter(t, other, backend, "test:switch", 1, 60).Allow(ctx)
+		require.NoError(t, err)
+	}
+
+	allowed, err := newTestLimiter(t, algorithm, backend, "test:switch", 1, 60).Allow(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func testReportsRemainingAndRetryAfter(t *testing.T, algorithm Algorithm, replica func() backend) {
+	t.Helper()
+
+	backend := replica()
+	ctx := context.Background()
+	spec := limiterSpec{algorithm: algorithm, key: "test:retry", limit: 3, window: 30}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		decision, err := backend.allowAll(ctx, []limiterSpec{spec}, timeNow().Unix())
+		require.NoError(t, err)
+		require.Equal(t, -1, decision.rejected)
+		assert.Equal(t, remaining, decision.remaining)
+		advanceTime(time.Second)
+	}
+
+	decision, err := backend.allowAll(ctx, []limiterSpec{spec}, timeNow().Unix())
+	require.NoError(t, err)
+	require.Equal(t, 0, decision.rejected)
+	require.Positive(t, decision.retryAfter)
+	assert.LessOrEqual(t, decision.retryAfter, 2*30*time.Second)
+
+	// За секунду до срока запрос всё ещё отклоняется, а в срок - проходит
+	advanceTime(decision.retryAfter - time.Second)
+	early, err := backend.allowAll(ctx, []limiterSpec{spec}, timeNow().Unix())
+	require.NoError(t, err)
+	if decision.retryAfter > time.Second {
+		assert.Equal(t, 0, early.rejected, "request before retry-after should be rejected")
+	}
+
+	advanceTime(time.Second)
+	decision, err = backend.allowAll(ctx, []limiterSpec{spec}, timeNow().Unix())
+	require.NoError(t, err)
+	assert.Equal(t, -1, decision.rejected, "request after retry-after should be allowed")
+}
+
+func testInspectionDoesNotConsume(t *testing.T, algorithm Algorithm, replica func() backend) {
+	t.Helper()
+
+	backend := replica()
+	ctx := context.Background()
+	spec := limiterSpec{algorithm: algorithm, key: "test:inspect", limit: 3, window: 30}
+
+	inspections, err := backend.inspect(ctx, []limiterSpec{spec}, timeNow().Unix())
+	require.NoError(t, err)
+	require.Len(t, inspections, 1)
+	assert.InDelta(t, 3, inspections[0].available, 1e-6)
+	assert.Zero(t, inspections[0].fullAfter)
+
+	decision, err := backend.allowAll(ctx, []limiterSpec{spec}, timeNow().Unix())
+	require.NoError(t, err)
+	require.Equal(t, -1, decision.rejected)
+
+	// Сколько бы раз ни смотрели состояние, оно не меняется
+	for i := 0; i < 5; i++ {
+		inspections, err = backend.inspect(ctx, []limiterSpec{spec}, timeNow().Unix())
+		require.NoError(t, err)
+		assert.InDelta(t, 2, inspections[0].available, 1e-6)
+		assert.Positive(t, inspections[0].fullAfter)
+		assert.LessOrEqual(t, inspections[0].fullAfter, 2*30*time.Second)
+	}
+
+	advanceTime(inspections[0].fullAfter)
+	inspections, err = backend.inspect(ctx, []limiterSpec{spec}, timeNow().Unix())
+	require.NoError(t, err)
+	assert.InDelta(t, 3, inspections[0].available, 1e-6)
+	assert.Zero(t, inspections[0].fullAfter)
+
+	for i := 0; i < 3; i++ {
+		decision, err = backend.allowAll(ctx, []limiterSpec{spec}, timeNow().Unix())
+		require.NoError(t, err)
+		assert.Equal(t, -1, decision.rejected)
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, algorithm := range algorithms {
+		parsed, err := ParseAlgorithm(string(algorithm))
+		require.NoError(t, err)
+		assert.Equal(t, algorithm, parsed)
+	}
+
+	parsed, err := ParseAlgorithm("")
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmTokenBucket, parsed)
+
+	_, err = ParseAlgorithm("leaky")
+	assert.Error(t, err)
+}
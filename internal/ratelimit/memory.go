@@ -0,0 +1,538 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryConfig struct {
+	// Shards - число независимых сегментов со своим мьютексом.
+	Shards int
+	// MaxKeys - максимальное число хранимых ключей. При переполнении вытесняются давно не использованные.
+	MaxKeys int
+	// CleanupInterval - период удаления просроченных ключей.
+	CleanupInterval time.Duration
+}
+
+// MemoryBackend хранит состояние лимитеров в памяти процесса.
+// Подходит для одного экземпляра сервиса и окружений без Redis.
+type MemoryBackend struct {
+	shards          []*memoryShard
+	maxKeysPerShard int
+	cleanupInterval time.Duration
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru упорядочен от недавно использованных ключей к давно не использованным.
+	lru *list.List
+}
+
+type memoryEntry struct {
+	key       string
+	algorithm Algorithm
+	state     memoryState
+	expiresAt int64
+}
+
+// memoryState - состояние лимитера. Каждый алгоритм использует только свои поля,
+// как и соответствующий ключ в Redis (см. algorithms.lua).
+// Счётчик отказов хранится в start и current, бан - в level и until (см. strike.lua).
+type memoryState struct {
+	allowance float64
+	timestamp int64
+	start     int64
+	current   int
+	previous  int
+	log       []int64
+	tat       float64
+	level     int
+	until     int64
+}
+
+// Счётчики отказов и баны хранятся рядом с лимитерами и отличаются от них видом состояния.
+// Это не алгоритмы, поэтому они не объявлены константами рядом с ними.
+var (
+	memoryStrikes = Algorithm("strikes")
+	memoryBan     = Algorithm("ban")
+)
+
+func NewMemoryBackend(config MemoryConfig) *MemoryBackend {
+	if config.Shards <= 0 {
+		config.Shards = 1
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Minute
+	}
+
+	maxKeysPerShard := 0
+	if config.MaxKeys > 0 {
+		maxKeysPerShard = max(config.MaxKeys/config.Shards, 1)
+	}
+
+	shards := make([]*memoryShard, config.Shards)
+	for i := range shards {
+		shards[i] = &memoryShard{
+			entries: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
+	}
+
+	return &MemoryBackend{
+		shards:          shards,
+		maxKeysPerShard: maxKeysPerShard,
+		cleanupInterval: config.CleanupInterval,
+	}
+}
+
+// Run периодически удаляет просроченные ключи, пока не завершится контекст.
+func (b *MemoryBackend) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.cleanup(timeNow().Unix())
+		}
+	}
+}
+
+// Len возвращает число хранимых ключей.
+func (b *MemoryBackend) Len() int {
+	total := 0
+	for _, shard := range b.shards {
+		shard.mu.Lock()
+		total += len(shard.entries)
+		shard.mu.Unlock()
+	}
+
+	return total
+}
+
+func (b *MemoryBackend) allowAll(_ context.Context, specs []limiterSpec, now int64) (decision, error) {
+	shards := b.lockShards(specs)
+	defer unlockShards(shards)
+
+	checks := make([]memoryCheck, len(specs))
+	for i, spec := range specs {
+		b.migratePreviousState(spec, now)
+		state, exists := b.shardFor(spec.key).get(spec, now)
+		checks[i] = checkMemory(spec, state, exists, now)
+		if !checks[i].ok {
+			retryAfter := max(math.Ceil(checks[i].retryAfter), 1)
+			return decision{rejected: i, retryAfter: time.Duration(retryAfter) * time.Second}, nil
+		}
+	}
+
+	result := decision{rejected: -1, remaining: -1}
+	for i, spec := range specs {
+		b.shardFor(spec.key).put(spec, checks[i].next, now+checks[i].ttl, b.maxKeysPerShard)
+
+		left := int(math.Floor(checks[i].available - 1 + 1e-9))
+		if result.remaining < 0 || left < result.remaining {
+			result.remaining = left
+		}
+	}
+	result.remaining = max(result.remaining, 0)
+
+	return result, nil
+}
+
+func (b *MemoryBackend) inspect(_ context.Context, specs []limiterSpec, now int64) ([]inspection, error) {
+	shards := b.lockShards(specs)
+	defer unlockShards(shards)
+
+	inspections := make([]inspection, len(specs))
+	for i, spec := range specs {
+		state, exists := b.peek(spec, now)
+		check := checkMemory(spec, state, exists, now)
+		inspections[i] = inspection{
+			available: math.Max(check.available, 0),
+			fullAfter: time.Duration(math.Max(check.fullAfter, 0) * float64(time.Second)),
+		}
+	}
+
+	return inspections, nil
+}
+
+// peek возвращает состояние лимитера, не перенося его из-под ключа предыдущего секрета
+// и не отмечая ключ как использованный.
+func (b *MemoryBackend) peek(spec limiterSpec, now int64) (memoryState, bool) {
+	for _, key := range append([]string{spec.key}, spec.previousKeys...) {
+		element, ok := b.shardFor(key).entries[key]
+		if !ok {
+			continue
+		}
+
+		entry := element.Value.(*memoryEntry)
+		if entry.expiresAt <= now || entry.algorithm != spec.algorithm {
+			return memoryState{}, false
+		}
+		return entry.state, true
+	}
+
+	return memoryState{}, false
+}
+
+// migratePreviousState переносит состояние, сохранённое под ключом предыдущего секрета, под текущий ключ.
+func (b *MemoryBackend) migratePreviousState(spec limiterSpec, now int64) {
+	shard := b.shardFor(spec.key)
+	if element, ok := shard.entries[spec.key]; ok && element.Value.(*memoryEntry).expiresAt > now {
+		return
+	}
+
+	for _, previousKey := range spec.previousKeys {
+		previousShard := b.shardFor(previousKey)
+		element, ok := previousShard.entries[previousKey]
+		if !ok {
+			continue
+		}
+
+		entry := *element.Value.(*memoryEntry)
+		previousShard.remove(previousKey)
+		if entry.expiresAt > now {
+			shard.put(limiterSpec{algorithm: entry.algorithm, key: spec.key}, entry.state, entry.expiresAt, b.maxKeysPerShard)
+		}
+		return
+	}
+}
+
+func (b *MemoryBackend) strike(
+	_ context.Context, strikesKey, banKey string, policy banPolicy, now int64,
+) (banState, error) {
+	strikesSpec := limiterSpec{algorithm: memoryStrikes, key: strikesKey}
+	banSpec := limiterSpec{algorithm: memoryBan, key: banKey}
+
+	shards := b.lockShards([]limiterSpec{strikesSpec, banSpec})
+	defer unlockShards(shards)
+
+	strikesShard := b.shardFor(strikesKey)
+	strikes, exists := strikesShard.get(strikesSpec, now)
+	if !exists {
+		strikes = memoryState{start: now}
+	}
+	strikes.current++
+	if strikes.current < policy.threshold {
+		strikesShard.put(strikesSpec, strikes, strikes.start+policy.period, b.maxKeysPerShard)
+		return banState{}, nil
+	}
+	strikesShard.remove(strikesKey)
+
+	banShard := b.shardFor(banKey)
+	ban, _ := banShard.get(banSpec, now)
+	if ban.until+policy.forgetAfter <= now {
+		ban.level = 0
+	}
+	ban.level++
+	ban.until = now + policy.duration(ban.level)
+	banShard.put(banSpec, ban, ban.until+policy.forgetAfter, b.maxKeysPerShard)
+
+	return banState{level: ban.level, until: ban.until}, nil
+}
+
+func (b *MemoryBackend) bans(_ context.Context, keys []string, now int64) ([]banState, error) {
+	states := make([]banState, len(keys))
+	for i, key := range keys {
+		shard := b.shardFor(key)
+		shard.mu.Lock()
+		state, _ := shard.get(limiterSpec{algorithm: memoryBan, key: key}, now)
+		shard.mu.Unlock()
+
+		states[i] = banState{level: state.level, until: state.until}
+	}
+
+	return states, nil
+}
+
+func (b *MemoryBackend) reset(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		shard := b.shardFor(key)
+		shard.mu.Lock()
+		shard.remove(key)
+		shard.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (b *MemoryBackend) cleanup(now int64) {
+	for _, shard := range b.shards {
+		shard.mu.Lock()
+		for key, element := range shard.entries {
+			if element.Value.(*memoryEntry).expiresAt <= now {
+				shard.remove(key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+}
+
+func (b *MemoryBackend) shardIndex(key string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % uint32(len(b.shards))) //nolint:gosec // число сегментов положительно
+}
+
+func (b *MemoryBackend) shardFor(key string) *memoryShard {
+	return b.shards[b.shardIndex(key)]
+}
+
+// lockShards блокирует сегменты всех ключей в порядке возрастания индекса, чтобы избежать взаимоблокировок.
+func (b *MemoryBackend) lockShards(specs []limiterSpec) []*memoryShard {
+	indexes := make([]int, 0, len(specs))
+	seen := make(map[int]bool, len(specs))
+	for _, spec := range specs {
+		for _, key := range append([]string{spec.key}, spec.previousKeys...) {
+			index := b.shardIndex(key)
+			if !seen[index] {
+				seen[index] = true
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	sort.Ints(indexes)
+
+	shards := make([]*memoryShard, 0, len(indexes))
+	for _, index := range indexes {
+		b.shards[index].mu.Lock()
+		shards = append(shards, b.shards[index])
+	}
+
+	return shards
+}
+
+func unlockShards(shards []*memoryShard) {
+	for _, shard := range shards {
+		shard.mu.Unlock()
+	}
+}
+
+// get возвращает состояние ключа и отмечает его как недавно использованный.
+// Просроченное состояние и состояние другого алгоритма не учитываются.
+func (s *memoryShard) get(spec limiterSpec, now int64) (memoryState, bool) {
+	element, ok := s.entries[spec.key]
+	if !ok {
+		return memoryState{}, false
+	}
+
+	s.lru.MoveToFront(element)
+
+	entry := element.Value.(*memoryEntry)
+	if entry.expiresAt <= now || entry.algorithm != spec.algorithm {
+		return memoryState{}, false
+	}
+
+	return entry.state, true
+}
+
+func (s *memoryShard) put(spec limiterSpec, state memoryState, expiresAt int64, maxKeys int) {
+	if element, ok := s.entries[spec.key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.algorithm = spec.algorithm
+		entry.state = state
+		entry.expiresAt = expiresAt
+		s.lru.MoveToFront(element)
+		return
+	}
+
+	if maxKeys > 0 && len(s.entries) >= maxKeys {
+		if oldest := s.lru.Back(); oldest != nil {
+			s.remove(oldest.Value.(*memoryEntry).key)
+		}
+	}
+
+	s.entries[spec.key] = s.lru.PushFront(&memoryEntry{
+		key:       spec.key,
+		algorithm: spec.algorithm,
+		state:     state,
+		expiresAt: expiresAt,
+	})
+}
+
+func (s *memoryShard) remove(key string) {
+	if element, ok := s.entries[key]; ok {
+		s.lru.Remove(element)
+		delete(s.entries, key)
+	}
+}
+
+// memoryCheck - результат проверки одного лимитера, аналог результата check в algorithms.lua.
+type memoryCheck struct {
+	ok bool
+	// available - сколько единиц доступно до списания.
+	available float64
+	// retryAfter - через сколько секунд лимитер пропустит запрос, если сейчас отказал.
+	retryAfter float64
+	// fullAfter - через сколько секунд лимитер полностью восстановится.
+	fullAfter float64
+	// next - состояние после списания, ttl - через сколько секунд его можно удалить.
+	next memoryState
+	ttl  int64
+}
+
+// checkMemory повторяет логику algorithms.lua.
+func checkMemory(spec limiterSpec, state memoryState, exists bool, now int64) memoryCheck {
+	limit, window := float64(spec.limit), int64(spec.window)
+	// Нулевой лимит не пропускает ни одного запроса, формулы алгоритмов на него не рассчитаны
+	if limit < 1 {
+		return memoryCheck{retryAfter: float64(window), next: state, ttl: window + 1}
+	}
+
+	switch spec.algorithm {
+	case AlgorithmTokenBucket:
+		return checkTokenBucket(state, exists, limit, window, now)
+	case AlgorithmFixedWindow:
+		return checkFixedWindow(state, limit, window, now)
+	case AlgorithmSlidingWindow:
+		return checkSlidingWindow(state, limit, window, now)
+	case AlgorithmSlidingLog:
+		return checkSlidingLog(state, limit, window, now)
+	case AlgorithmGCRA:
+		return checkGCRA(state, exists, limit, window, now)
+	}
+
+	return memoryCheck{}
+}
+
+func checkTokenBucket(state memoryState, exists bool, limit float64, window, now int64) memoryCheck {
+	if !exists {
+		state.allowance, state.timestamp = limit, now
+	}
+
+	elapsed := max(now-state.timestamp, 0)
+	allowance := math.Min(state.allowance+float64(elapsed)*limit/float64(window), limit)
+
+	check := memoryCheck{
+		ok:        allowance >= 1,
+		available: allowance,
+		fullAfter: (limit - allowance) * float64(window) / limit,
+		next:      memoryState{allowance: allowance - 1, timestamp: now},
+		ttl:       window + 1,
+	}
+	if !check.ok {
+		check.retryAfter = (1 - allowance) * float64(window) / limit
+	}
+
+	return check
+}
+
+func checkFixedWindow(state memoryState, limit float64, window, now int64) memoryCheck {
+	start := now - now%window
+	if state.start != start {
+		state.current = 0
+	}
+
+	check := memoryCheck{
+		ok:        float64(state.current) < limit,
+		available: limit - float64(state.current),
+		next:      memoryState{start: start, current: state.current + 1},
+		ttl:       start + window - now + 1,
+	}
+	if !check.ok {
+		check.retryAfter = float64(start + window - now)
+	}
+	if state.current > 0 {
+		check.fullAfter = float64(start + window - now)
+	}
+
+	return check
+}
+
+func checkSlidingWindow(state memoryState, limit float64, window, now int64) memoryCheck {
+	start := now - now%window
+	switch state.start {
+	case start:
+	case start - window:
+		state.previous, state.current = state.current, 0
+	default:
+		state.previous, state.current = 0, 0
+	}
+
+	previous, current := float64(state.previous), float64(state.current)
+	weight := float64(window-(now-start)) / float64(window)
+	estimate := previous*weight + current
+
+	check := memoryCheck{
+		ok:        estimate+1 <= limit,
+		available: limit - estimate,
+		next:      memoryState{start: start, current: state.current + 1, previous: state.previous},
+		ttl:       start + 2*window - now + 1,
+	}
+	switch {
+	case check.ok:
+	case current+1 <= limit:
+		check.retryAfter = float64(start-now) + float64(window)*(1-(limit-1-current)/previous)
+	default:
+		check.retryAfter = float64(start+window-now) + float64(window)*(1-(limit-1)/current)
+	}
+	switch {
+	case state.current > 0:
+		check.fullAfter = float64(start + 2*window - now)
+	case state.previous > 0:
+		check.fullAfter = float64(start + window - now)
+	}
+
+	return check
+}
+
+func checkSlidingLog(state memoryState, limit float64, window, now int64) memoryCheck {
+	expired := 0
+	for expired < len(state.log) && state.log[expired] <= now-window {
+		expired++
+	}
+
+	log := make([]int64, 0, len(state.log)-expired+1)
+	log = append(log, state.log[expired:]...)
+	count := float64(len(log))
+
+	check := memoryCheck{
+		ok:        count < limit,
+		available: limit - count,
+		next:      memoryState{log: append(log, now)},
+		ttl:       window + 1,
+	}
+	switch {
+	case check.ok:
+	case limit >= 1 && len(log) >= int(limit):
+		check.retryAfter = float64(log[len(log)-int(limit)] + window - now)
+	default:
+		// С нулевым лимитом запросы не проходят никогда, освобождаться в логе нечему
+		check.retryAfter = float64(window)
+	}
+	if len(log) > 0 {
+		check.fullAfter = float64(log[len(log)-1] + window - now)
+	}
+
+	return check
+}
+
+func checkGCRA(state memoryState, exists bool, limit float64, window, now int64) memoryCheck {
+	interval := float64(window) / limit
+	tat := float64(now)
+	if exists {
+		tat = math.Max(state.tat, tat)
+	}
+	next := tat + interval
+
+	check := memoryCheck{
+		ok:        next-float64(now) <= float64(window),
+		available: (float64(window) - (tat - float64(now))) / interval,
+		fullAfter: tat - float64(now),
+		next:      memoryState{tat: next},
+		ttl:       int64(math.Ceil(next-float64(now))) + 1,
+	}
+	if !check.ok {
+		check.retryAfter = next - float64(window) - float64(now)
+	}
+
+	return check
+}
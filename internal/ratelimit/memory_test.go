@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBackend_EvictsExpiredKeys(t *testing.T) {
+	freezeTime(t)
+
+	memory := NewMemoryBackend(MemoryConfig{Shards: 2})
+	ctx := context.Background()
+
+	for i := 0; i < 10; i++ {
+		_, err := newTestLimiter(t, AlgorithmTokenBucket, memory, fmt.Sprintf("test:%d", i), 5, 60).Allow(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 10, memory.Len())
+
+	memory.cleanup(timeNow().Unix())
+	assert.Equal(t, 10, memory.Len())
+
+	advanceTime(61 * time.Second)
+	memory.cleanup(timeNow().Unix())
+	assert.Zero(t, memory.Len())
+}
+
+func TestMemoryBackend_CapsNumberOfKeys(t *testing.T) {
+	freezeTime(t)
+
+	memory := NewMemoryBackend(MemoryConfig{Shards: 1, MaxKeys: 3})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := newTestLimiter(t, AlgorithmTokenBucket, memory, fmt.Sprintf("test:%d", i), 1, 60).Allow(ctx)
+		require.NoError(t, err)
+	}
+
+	// Обращение к test:0 делает его недавно использованным, поэтому вытеснен будет test:1
+	_, err := newTestLimiter(t, AlgorithmTokenBucket, memory, "test:0", 1, 60).Allow(ctx)
+	require.NoError(t, err)
+	_, err = newTestLimiter(t, AlgorithmTokenBucket, memory, "test:3", 1, 60).Allow(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, memory.Len())
+
+	allowed, err := newTestLimiter(t, AlgorithmTokenBucket, memory, "test:1", 1, 60).Allow(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed, "evicted bucket should start full")
+
+	allowed, err = newTestLimiter(t, AlgorithmTokenBucket, memory, "test:3", 1, 60).Allow(ctx)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	freezeTime(t)
+
+	limiter, err := NewMemoryRateLimiter(NewMemoryBackend(MemoryConfig{Shards: 4}), Config{
+		LoginLimit:    2,
+		PasswordLimit: 10,
+		IPLimit:       10,
+		Window:        60,
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+	require.ErrorIs(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")), ErrLimitExceeded)
+
+	require.NoError(t, limiter.ResetBuckets(ctx, "user", "10.0.0.1"))
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+}
+
+func TestMemoryRateLimiter_ZeroLimit(t *testing.T) {
+	freezeTime(t)
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			limiter, err := NewMemoryRateLimiter(NewMemoryBackend(MemoryConfig{}), Config{
+				LoginLimit:     0,
+				PasswordLimit:  10,
+				IPLimit:        10,
+				Window:         60,
+				LoginAlgorithm: algorithm,
+			})
+			require.NoError(t, err)
+
+			// Нулевой лимит отклоняет все попытки логина и не роняет проверку
+			_, err = limiter.Check(context.Background(), "user", "secret", "10.0.0.1")
+			var limitErr *LimitExceededError
+			require.ErrorAs(t, err, &limitErr)
+			assert.Equal(t, BucketLogin, limitErr.Bucket)
+			assert.Positive(t, limitErr.RetryAfter)
+		})
+	}
+}
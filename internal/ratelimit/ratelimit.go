@@ -2,78 +2,404 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+type Bucket string
+
+const (
+	BucketLogin    Bucket = "login"
+	BucketPassword Bucket = "password"
+	BucketIP       Bucket = "ip"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitExceededError сообщает, какой из бакетов отклонил запрос и когда он пропустит следующий.
+type LimitExceededError struct {
+	Bucket     Bucket
+	RetryAfter time.Duration
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s limit exceeded", e.Bucket)
+}
+
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
 type RateLimiter struct {
-	client *redis.Client
-	config Config
+	backend backend
+	config  Config
+	hasher  *keyHasher
+	bans    banPolicy
 }
 
 type Config struct {
-	LoginLimit    int
-	PasswordLimit int
-	IPLimit       int
-	Window        int
+	LoginLimit        int
+	PasswordLimit     int
+	IPLimit           int
+	Window            int
+	LoginAlgorithm    Algorithm
+	PasswordAlgorithm Algorithm
+	IPAlgorithm       Algorithm
+
+	// GreylistLoginLimit, GreylistPasswordLimit и GreylistIPLimit - лимиты для запросов из серого списка
+	// (Options.Greylisted). 0 означает тот же лимит, что у остальных запросов.
+	GreylistLoginLimit    int
+	GreylistPasswordLimit int
+	GreylistIPLimit       int
+
+	// KeySecrets - секреты HMAC для ключей бакетов паролей (и логинов при HashLogins).
+	// Первый - текущий, остальные - предыдущие. С Redis секрет обязателен: бакеты общие для всех
+	// экземпляров сервиса. В памяти без секретов генерируется случайный, и бакеты не переживают перезапуск.
+	KeySecrets []string
+	HashLogins bool
+
+	// IPv4Prefix и IPv6Prefix - длина префикса, по которому адреса объединяются в один бакет IP.
+	// 0 означает полный адрес.
+	IPv4Prefix int
+	IPv6Prefix int
+
+	Bans BanConfig
 }
 
-func NewRateLimiter(client *redis.Client, config Config) *RateLimiter {
-	return &RateLimiter{
-		client: client,
-		config: config,
+// ErrKeySecretRequired - для бакетов в Redis не задан секрет HMAC. Со случайными секретами экземпляры
+// сервиса не делили бы бакеты паролей, а хеши паролей в журнале и /buckets значили бы что-то только
+// на записавшем их экземпляре.
+var ErrKeySecretRequired = errors.New("rate limit key secret is required with the Redis backend")
+
+// NewRateLimiter создаёт ограничитель, хранящий бакеты в Redis.
+func NewRateLimiter(client *redis.Client, config Config) (*RateLimiter, error) {
+	if !slices.ContainsFunc(config.KeySecrets, func(secret string) bool { return secret != "" }) {
+		return nil, ErrKeySecretRequired
 	}
+
+	return newRateLimiter(newRedisBackend(client), config)
 }
 
-func (r *RateLimiter) Check(ctx context.Context, login, password, ip string) error {
-	loginBucket := NewTokenBucket(r.client, "ratelimit:login:"+login, r.config.LoginLimit, r.config.Window)
-	allowed, err := loginBucket.Allow(ctx)
-	if err != nil {
-		return fmt.Errorf("login limit check failed: %w", err)
+// NewMemoryRateLimiter создаёт ограничитель, хранящий бакеты в памяти процесса.
+func NewMemoryRateLimiter(memory *MemoryBackend, config Config) (*RateLimiter, error) {
+	return newRateLimiter(memory, config)
+}
+
+func newRateLimiter(backend backend, config Config) (*RateLimiter, error) {
+	for _, algorithm := range []*Algorithm{&config.LoginAlgorithm, &config.PasswordAlgorithm, &config.IPAlgorithm} {
+		parsed, err := ParseAlgorithm(string(*algorithm))
+		if err != nil {
+			return nil, err
+		}
+		*algorithm = parsed
+	}
+
+	if config.Window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit window: %d", config.Window)
 	}
-	if !allowed {
-		return fmt.Errorf("login limit exceeded: %s", login)
+	for _, limit := range []int{config.LoginLimit, config.PasswordLimit, config.IPLimit} {
+		if limit < 0 {
+			return nil, fmt.Errorf("invalid rate limit: %d", limit)
+		}
 	}
 
-	passwordBucket := NewTokenBucket(r.client, "ratelimit:password:"+password, r.config.PasswordLimit, r.config.Window)
-	allowed, err = passwordBucket.Allow(ctx)
-	if err != nil {
-		return fmt.Errorf("password limit check failed: %w", err)
+	if config.IPv4Prefix < 0 || config.IPv4Prefix > 32 {
+		return nil, fmt.Errorf("invalid IPv4 prefix length: %d", config.IPv4Prefix)
 	}
-	if !allowed {
-		return fmt.Errorf("password limit exceeded")
+	if config.IPv6Prefix < 0 || config.IPv6Prefix > 128 {
+		return nil, fmt.Errorf("invalid IPv6 prefix length: %d", config.IPv6Prefix)
 	}
 
-	ipBucket := NewTokenBucket(r.client, "ratelimit:ip:"+ip, r.config.IPLimit, r.config.Window)
-	allowed, err = ipBucket.Allow(ctx)
+	for _, limit := range []struct{ greylist, regular *int }{
+		{&config.GreylistLoginLimit, &config.LoginLimit},
+		{&config.GreylistPasswordLimit, &config.PasswordLimit},
+		{&config.GreylistIPLimit, &config.IPLimit},
+	} {
+		if *limit.greylist < 0 {
+			return nil, fmt.Errorf("invalid greylist limit: %d", *limit.greylist)
+		}
+		if *limit.greylist == 0 {
+			*limit.greylist = *limit.regular
+		}
+	}
+
+	hasher, err := newKeyHasher(config.KeySecrets)
 	if err != nil {
-		return fmt.Errorf("ip limit check failed: %w", err)
+		return nil, err
 	}
-	if !allowed {
-		return fmt.Errorf("ip limit exceeded: %s", ip)
+
+	bans, err := newBanPolicy(config.Bans)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &RateLimiter{
+		backend: backend,
+		config:  config,
+		hasher:  hasher,
+		bans:    bans,
+	}, nil
 }
 
-func (r *RateLimiter) ResetBuckets(ctx context.Context, login, ip string) error {
-	keys := []string{
-		fmt.Sprintf("ratelimit:login:%s", login),
-		fmt.Sprintf("ratelimit:ip:%s", ip),
+// Result - результат успешной проверки.
+type Result struct {
+	// Remaining - сколько ещё запросов пропустит самый строгий из бакетов.
+	Remaining int
+}
+
+// Options смягчают проверку для доверенных сетей.
+type Options struct {
+	// SkipIP не проверяет бакет IP: одним адресом NAT или офисного шлюза пользуются многие.
+	SkipIP bool
+	// SkipLogin не проверяет бакет логина: служебные учётки ходят часто и с разных адресов.
+	SkipLogin bool
+	// LimitMultiplier умножает лимиты бакетов. Бакеты остаются общими с обычными запросами,
+	// поэтому попытки из доверенной сети расходуют те же токены. 0 и 1 оставляют лимиты как есть.
+	LimitMultiplier int
+	// Greylisted заменяет лимиты на Config.GreylistLoginLimit, GreylistPasswordLimit и GreylistIPLimit.
+	// Такие запросы расходуют отдельные бакеты: с общими бакетами одна попытка из серого списка урезала бы
+	// накопленные токены логина до строгого лимита. Баны общие.
+	Greylisted bool
+}
+
+// Check списывает по токену из бакетов логина, пароля и IP, только если все они разрешают запрос.
+// При отказе возвращает *LimitExceededError, а если отказ привёл к бану логина или IP - *BannedError.
+func (r *RateLimiter) Check(ctx context.Context, login, password, ip string) (Result, error) {
+	return r.CheckWithOptions(ctx, login, password, ip, Options{})
+}
+
+// CheckWithOptions работает как Check, но с лимитами, изменёнными opts.
+func (r *RateLimiter) CheckWithOptions(ctx context.Context, login, password, ip string, opts Options) (Result, error) {
+	loginKey, previousLoginKeys := r.loginKey(login)
+	passwordKey, previousPasswordKeys := r.hasher.key("ratelimit:password:", password)
+	loginLimit, passwordLimit, ipLimit := r.limits(opts)
+
+	buckets := []Bucket{BucketLogin, BucketPassword, BucketIP}
+	// banSubjects - ключи, от которых считаются баны, даже если бакеты отдельные
+	banSubjects := []string{loginKey, passwordKey, r.ipKey(ip)}
+	specs := []limiterSpec{
+		{
+			algorithm:    r.config.LoginAlgorithm,
+			key:          loginKey,
+			previousKeys: previousLoginKeys,
+			limit:        loginLimit,
+			window:       r.config.Window,
+		},
+		{
+			algorithm:    r.config.PasswordAlgorithm,
+			key:          passwordKey,
+			previousKeys: previousPasswordKeys,
+			limit:        passwordLimit,
+			window:       r.config.Window,
+		},
+		{
+			algorithm: r.config.IPAlgorithm,
+			key:       banSubjects[2],
+			limit:     ipLimit,
+			window:    r.config.Window,
+		},
+	}
+	if opts.Greylisted {
+		for i := range specs {
+			specs[i].key = greylistKey(specs[i].key)
+			for j, key := range specs[i].previousKeys {
+				specs[i].previousKeys[j] = greylistKey(key)
+			}
+		}
+	}
+	if opts.SkipLogin || opts.SkipIP {
+		skip := []bool{opts.SkipLogin, false, opts.SkipIP}
+		keptBuckets, keptSpecs, keptSubjects := buckets[:0], specs[:0], banSubjects[:0]
+		for i := range buckets {
+			if !skip[i] {
+				keptBuckets = append(keptBuckets, buckets[i])
+				keptSpecs = append(keptSpecs, specs[i])
+				keptSubjects = append(keptSubjects, banSubjects[i])
+			}
+		}
+		buckets, specs, banSubjects = keptBuckets, keptSpecs, keptSubjects
+	}
+
+	decision, err := r.backend.allowAll(ctx, specs, timeNow().Unix())
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit check failed: %w", err)
 	}
+	if decision.rejected >= 0 {
+		bucket := buckets[decision.rejected]
+		if err := r.strike(ctx, bucket, banSubjects[decision.rejected]); err != nil {
+			return Result{}, err
+		}
+		return Result{}, &LimitExceededError{Bucket: bucket, RetryAfter: decision.retryAfter}
+	}
+
+	return Result{Remaining: decision.remaining}, nil
+}
+
+// ErrInvalidPasswordHash - хеш пароля не похож на ключ бакета.
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+// BucketState - состояние бакета на текущий момент.
+type BucketState struct {
+	Bucket    Bucket
+	Algorithm Algorithm
+	// Available - сколько запросов бакет пропустит сейчас, с учётом восстановления.
+	Available float64
+	Capacity  int
+	Window    time.Duration
+	// FullIn - через сколько бакет полностью восстановится.
+	FullIn time.Duration
+}
 
-	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
-		for _, key := range keys {
-			if err := pipe.Del(ctx, key).Err(); err != nil {
-				return fmt.Errorf("failed to delete key %s: %w", key, err)
+// Inspect возвращает состояние бакетов, ничего не списывая. Пустые значения пропускаются.
+// Пароль сервис не хранит, поэтому бакет пароля задаётся хешем из его ключа.
+// opts должны быть теми же, с которыми запрос проверяет CheckWithOptions: от них зависят лимиты,
+// бакеты серого списка и то, какие бакеты проверяются вообще.
+func (r *RateLimiter) Inspect(
+	ctx context.Context, login, passwordHash, ip string, opts Options,
+) ([]BucketState, error) {
+	loginLimit, passwordLimit, ipLimit := r.limits(opts)
+
+	var buckets []Bucket
+	var specs []limiterSpec
+
+	if login != "" && !opts.SkipLogin {
+		loginKey, previousLoginKeys := r.loginKey(login)
+		buckets = append(buckets, BucketLogin)
+		specs = append(specs, limiterSpec{
+			algorithm:    r.config.LoginAlgorithm,
+			key:          loginKey,
+			previousKeys: previousLoginKeys,
+			limit:        loginLimit,
+			window:       r.config.Window,
+		})
+	}
+	if passwordHash != "" {
+		if !isKeyDigest(passwordHash) {
+			return nil, ErrInvalidPasswordHash
+		}
+		buckets = append(buckets, BucketPassword)
+		specs = append(specs, limiterSpec{
+			algorithm: r.config.PasswordAlgorithm,
+			key:       "ratelimit:password:" + strings.ToLower(passwordHash),
+			limit:     passwordLimit,
+			window:    r.config.Window,
+		})
+	}
+	if ip != "" && !opts.SkipIP {
+		buckets = append(buckets, BucketIP)
+		specs = append(specs, limiterSpec{
+			algorithm: r.config.IPAlgorithm,
+			key:       r.ipKey(ip),
+			limit:     ipLimit,
+			window:    r.config.Window,
+		})
+	}
+	if opts.Greylisted {
+		for i := range specs {
+			specs[i].key = greylistKey(specs[i].key)
+			for j, key := range specs[i].previousKeys {
+				specs[i].previousKeys[j] = greylistKey(key)
 			}
 		}
-		return nil
-	})
+	}
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	inspections, err := r.backend.inspect(ctx, specs, timeNow().Unix())
 	if err != nil {
+		return nil, fmt.Errorf("failed to inspect buckets: %w", err)
+	}
+
+	states := make([]BucketState, len(specs))
+	for i, spec := range specs {
+		states[i] = BucketState{
+			Bucket:    buckets[i],
+			Algorithm: spec.algorithm,
+			Available: inspections[i].available,
+			Capacity:  spec.limit,
+			Window:    time.Duration(spec.window) * time.Second,
+			FullIn:    inspections[i].fullAfter,
+		}
+	}
+
+	return states, nil
+}
+
+// PasswordHash возвращает хеш пароля из ключа его бакета. Его можно писать в журнал и передавать в Inspect.
+func (r *RateLimiter) PasswordHash(password string) string {
+	key, _ := r.hasher.key("", password)
+	return key
+}
+
+// ResetBuckets сбрасывает бакеты логина и IP вместе с их банами.
+func (r *RateLimiter) ResetBuckets(ctx context.Context, login, ip string) error {
+	loginKey, previousLoginKeys := r.loginKey(login)
+	bucketKeys := append([]string{loginKey, r.ipKey(ip)}, previousLoginKeys...)
+
+	keys := make([]string, 0, len(bucketKeys)*4)
+	for _, key := range bucketKeys {
+		keys = append(keys, key, greylistKey(key), banKey(key), strikesKey(key))
+	}
+
+	if err := r.backend.reset(ctx, keys); err != nil {
 		return fmt.Errorf("failed to reset buckets: %w", err)
 	}
 
 	return nil
 }
+
+// limits возвращает лимиты бакетов логина, пароля и IP с учётом opts.
+func (r *RateLimiter) limits(opts Options) (login, password, ip int) {
+	login, password, ip = r.config.LoginLimit, r.config.PasswordLimit, r.config.IPLimit
+	if opts.Greylisted {
+		login, password, ip = r.config.GreylistLoginLimit, r.config.GreylistPasswordLimit, r.config.GreylistIPLimit
+	}
+
+	multiplier := max(opts.LimitMultiplier, 1)
+	return login * multiplier, password * multiplier, ip * multiplier
+}
+
+// greylistKey возвращает ключ отдельного бакета для запросов из серого списка.
+func greylistKey(key string) string {
+	return "ratelimit:greylist:" + strings.TrimPrefix(key, "ratelimit:")
+}
+
+func (r *RateLimiter) loginKey(login string) (string, []string) {
+	if r.config.HashLogins {
+		return r.hasher.key("ratelimit:login:", login)
+	}
+
+	return "ratelimit:login:" + login, nil
+}
+
+// ipKey возвращает ключ бакета IP. Адреса объединяются по префиксу из конфигурации,
+// так как атакующему обычно принадлежит целая подсеть IPv6 (например, /64), а не один адрес.
+func (r *RateLimiter) ipKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "ratelimit:ip:" + ip
+	}
+	addr = addr.Unmap()
+
+	bits := r.config.IPv4Prefix
+	if addr.Is6() {
+		bits = r.config.IPv6Prefix
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return "ratelimit:ip:" + addr.WithZone("").String()
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return "ratelimit:ip:" + ip
+	}
+
+	return "ratelimit:ip:" + prefix.String()
+}
@@ -0,0 +1,371 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRateLimiter(t *testing.T, config Config) (*RateLimiter, *miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+
+	client := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	t.Cleanup(func() {
+		client.Close()
+		mr.Close()
+	})
+
+	if config.KeySecrets == nil {
+		config.KeySecrets = []string{"test-secret"}
+	}
+	limiter, err := NewRateLimiter(client, config)
+	require.NoError(t, err)
+
+	return limiter, mr, client
+}
+
+func TestRateLimiter_NamesRejectingBucket(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		bucket Bucket
+	}{
+		{
+			name:   "login",
+			config: Config{LoginLimit: 1, PasswordLimit: 10, IPLimit: 10, Window: 60},
+			bucket: BucketLogin,
+		},
+		{
+			name:   "password",
+			config: Config{LoginLimit: 10, PasswordLimit: 1, IPLimit: 10, Window: 60},
+			bucket: BucketPassword,
+		},
+		{
+			name:   "ip",
+			config: Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 1, Window: 60},
+			bucket: BucketIP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, _, _ := setupRateLimiter(t, tt.config)
+			ctx := context.Background()
+
+			require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+
+			_, err := limiter.Check(ctx, "user", "secret", "10.0.0.1")
+			require.ErrorIs(t, err, ErrLimitExceeded)
+
+			var limitErr *LimitExceededError
+			require.True(t, errors.As(err, &limitErr))
+			assert.Equal(t, tt.bucket, limitErr.Bucket)
+		})
+	}
+}
+
+func TestRateLimiter_RejectionDoesNotConsumeTokens(t *testing.T) {
+	limiter, _, client := setupRateLimiter(t, Config{LoginLimit: 5, PasswordLimit: 5, IPLimit: 1, Window: 60})
+	ctx := context.Background()
+
+	// Исчерпываем бакет IP атакующего
+	require.NoError(t, checkErr(limiter.Check(ctx, "attacker", "guess1", "10.0.0.66")))
+
+	// Попытки с заблокированного IP не должны расходовать бакет легитимного пользователя
+	for i := 0; i < 10; i++ {
+		_, err := limiter.Check(ctx, "victim", "guess2", "10.0.0.66")
+		require.ErrorIs(t, err, ErrLimitExceeded)
+	}
+
+	passwordKey, _ := limiter.hasher.key("ratelimit:password:", "guess2")
+	exists, err := client.Exists(ctx, "ratelimit:login:victim", passwordKey).Result()
+	require.NoError(t, err)
+	assert.Zero(t, exists)
+
+	// Пользователь со своего IP по-прежнему может сделать все попытки
+	for i := 0; i < 5; i++ {
+		require.NoError(t, checkErr(limiter.Check(ctx, "victim", "password", fmt.Sprintf("10.0.1.%d", i))))
+	}
+}
+
+func TestRateLimiter_CheckWithOptions(t *testing.T) {
+	limiter, _, _ := setupRateLimiter(t, Config{LoginLimit: 2, PasswordLimit: 100, IPLimit: 1, Window: 60})
+	ctx := context.Background()
+
+	// Без бакета IP один адрес обслуживает много логинов
+	skipIP := Options{SkipIP: true}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, checkErr(limiter.CheckWithOptions(ctx, fmt.Sprintf("user%d", i), "pwd", "10.0.0.1", skipIP)))
+	}
+
+	// Лимит логина смягчается, но не снимается
+	relaxed := Options{SkipIP: true, LimitMultiplier: 10}
+	for i := 0; i < 20; i++ {
+		require.NoError(t, checkErr(limiter.CheckWithOptions(ctx, "victim", "guess", "10.0.0.1", relaxed)))
+	}
+	_, err := limiter.CheckWithOptions(ctx, "victim", "guess", "10.0.0.1", relaxed)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	// Бакеты общие: обычная проверка видит попытки из доверенной сети
+	_, err = limiter.Check(ctx, "victim", "guess", "10.0.0.2")
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	// Без бакета логина сервисная учётка ходит сколько угодно, но бакет IP действует
+	skipLogin := Options{SkipLogin: true}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, checkErr(limiter.CheckWithOptions(ctx, "probe", "pwd", fmt.Sprintf("10.0.1.%d", i), skipLogin)))
+	}
+	_, err = limiter.CheckWithOptions(ctx, "probe", "pwd", "10.0.1.0", skipLogin)
+	var limitErr *LimitExceededError
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, BucketIP, limitErr.Bucket)
+}
+
+func TestRateLimiter_GreylistedBuckets(t *testing.T) {
+	limiter, _, _ := setupRateLimiter(t, Config{
+		LoginLimit:         5,
+		PasswordLimit:      100,
+		IPLimit:            100,
+		Window:             60,
+		GreylistLoginLimit: 1,
+	})
+	ctx := context.Background()
+	greylisted := Options{Greylisted: true}
+
+	require.NoError(t, checkErr(limiter.CheckWithOptions(ctx, "user", "pwd", "10.0.0.1", greylisted)))
+	_, err := limiter.CheckWithOptions(ctx, "user", "pwd", "10.0.0.1", greylisted)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	// Попытки из серого списка не урезают обычный бакет логина
+	for i := 0; i < 5; i++ {
+		require.NoError(t, checkErr(limiter.Check(ctx, "user", "pwd", "10.0.1.1")))
+	}
+
+	require.NoError(t, limiter.ResetBuckets(ctx, "user", ""))
+	require.NoError(t, checkErr(limiter.CheckWithOptions(ctx, "user", "pwd", "10.0.0.1", greylisted)))
+}
+
+type countingHook struct {
+	commands atomic.Int64
+}
+
+func (h *countingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *countingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.commands.Add(1)
+		return next(ctx, cmd)
+	}
+}
+
+func (h *countingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.commands.Add(int64(len(cmds)))
+		return next(ctx, cmds)
+	}
+}
+
+func TestRateLimiter_SingleRoundTrip(t *testing.T) {
+	limiter, _, client := setupRateLimiter(t, Config{LoginLimit: 10, PasswordLimit: 10, IPLimit: 10, Window: 60})
+	ctx := context.Background()
+
+	hook := &countingHook{}
+	client.AddHook(hook)
+
+	// Первый вызов загружает скрипт (EVALSHA -> NOSCRIPT -> EVAL)
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+
+	before := hook.commands.Load()
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+	assert.Equal(t, int64(1), hook.commands.Load()-before)
+}
+
+func TestRateLimiter_AlgorithmPerDimension(t *testing.T) {
+	limiter, mr, _ := setupRateLimiter(t, Config{
+		LoginLimit:        2,
+		PasswordLimit:     10,
+		IPLimit:           10,
+		Window:            60,
+		LoginAlgorithm:    AlgorithmGCRA,
+		PasswordAlgorithm: AlgorithmSlidingLog,
+		IPAlgorithm:       AlgorithmFixedWindow,
+	})
+	ctx := context.Background()
+
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+
+	_, err := limiter.Check(ctx, "user", "secret", "10.0.0.1")
+	var limitErr *LimitExceededError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, BucketLogin, limitErr.Bucket)
+
+	assert.Equal(t, "string", mr.Type("ratelimit:login:user"))
+	passwordKey, _ := limiter.hasher.key("ratelimit:password:", "secret")
+	assert.Equal(t, "list", mr.Type(passwordKey))
+	assert.Equal(t, "hash", mr.Type("ratelimit:ip:10.0.0.1"))
+}
+
+func TestRateLimiter_Inspect(t *testing.T) {
+	freezeTime(t)
+	limiter, _, _ := setupRateLimiter(t, Config{LoginLimit: 5, PasswordLimit: 10, IPLimit: 20, Window: 60})
+	ctx := context.Background()
+
+	require.NoError(t, checkErr(limiter.Check(ctx, "user", "secret", "10.0.0.1")))
+
+	states, err := limiter.Inspect(ctx, "user", limiter.PasswordHash("secret"), "10.0.0.1", Options{})
+	require.NoError(t, err)
+	require.Len(t, states, 3)
+
+	for i, expected := range []struct {
+		bucket   Bucket
+		capacity int
+	}{{BucketLogin, 5}, {BucketPassword, 10}, {BucketIP, 20}} {
+		assert.Equal(t, expected.bucket, states[i].Bucket)
+		assert.Equal(t, AlgorithmTokenBucket, states[i].Algorithm)
+		assert.Equal(t, expected.capacity, states[i].Capacity)
+		assert.InDelta(t, float64(expected.capacity-1), states[i].Available, 1e-6)
+		assert.Equal(t, time.Minute, states[i].Window)
+		assert.Positive(t, states[i].FullIn)
+	}
+
+	// Пустые значения пропускаются
+	states, err = limiter.Inspect(ctx, "", "", "10.0.0.1", Options{})
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, BucketIP, states[0].Bucket)
+
+	_, err = limiter.Inspect(ctx, "", "secret", "", Options{})
+	assert.ErrorIs(t, err, ErrInvalidPasswordHash)
+}
+
+func TestRateLimiter_InspectWithOptions(t *testing.T) {
+	freezeTime(t)
+	limiter, _, _ := setupRateLimiter(t, Config{
+		LoginLimit:            5,
+		PasswordLimit:         10,
+		IPLimit:               20,
+		Window:                60,
+		GreylistLoginLimit:    2,
+		GreylistPasswordLimit: 3,
+		GreylistIPLimit:       4,
+	})
+	ctx := context.Background()
+	greylisted := Options{Greylisted: true}
+
+	require.NoError(t, checkErr(limiter.CheckWithOptions(ctx, "user", "secret", "10.0.0.1", greylisted)))
+
+	// Запрос из серого списка расходует отдельные бакеты с лимитами серого списка
+	states, err := limiter.Inspect(ctx, "user", limiter.PasswordHash("secret"), "10.0.0.1", greylisted)
+	require.NoError(t, err)
+	require.Len(t, states, 3)
+	for i, capacity := range []int{2, 3, 4} {
+		assert.Equal(t, capacity, states[i].Capacity)
+		assert.InDelta(t, float64(capacity-1), states[i].Available, 1e-6)
+	}
+
+	states, err = limiter.Inspect(ctx, "user", "", "10.0.0.1", Options{})
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	assert.InDelta(t, 5, states[0].Available, 1e-6, "regular buckets are untouched")
+
+	states, err = limiter.Inspect(ctx, "user", "", "10.0.0.1", Options{LimitMultiplier: 3, SkipIP: true})
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, BucketLogin, states[0].Bucket)
+	assert.Equal(t, 15, states[0].Capacity)
+
+	states, err = limiter.Inspect(ctx, "user", "", "10.0.0.1", Options{SkipLogin: true})
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, BucketIP, states[0].Bucket)
+}
+
+func TestRateLimiter_IPBucketKeyedOnPrefix(t *testing.T) {
+	limiter, mr, _ := setupRateLimiter(t, Config{
+		LoginLimit:    100,
+		PasswordLimit: 100,
+		IPLimit:       2,
+		Window:        60,
+		IPv6Prefix:    64,
+	})
+	ctx := context.Background()
+
+	// Адреса из одной /64 делят бакет
+	require.NoError(t, checkErr(limiter.Check(ctx, "user1", "secret", "2001:db8:1:2::1")))
+	require.NoError(t, checkErr(limiter.Check(ctx, "user2", "secret", "2001:db8:1:2:ffff::1")))
+	_, err := limiter.Check(ctx, "user3", "secret", "2001:db8:1:2:abcd::1")
+	var limitErr *LimitExceededError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, BucketIP, limitErr.Bucket)
+
+	require.NoError(t, checkErr(limiter.Check(ctx, "user4", "secret", "2001:db8:1:3::1")))
+	assert.True(t, mr.Exists("ratelimit:ip:2001:db8:1:2::/64"))
+
+	// IPv4 по умолчанию учитывается по полному адресу, в том числе записанный как IPv4-mapped IPv6
+	require.NoError(t, checkErr(limiter.Check(ctx, "user5", "secret", "10.0.0.1")))
+	require.NoError(t, checkErr(limiter.Check(ctx, "user6", "secret", "::ffff:10.0.0.1")))
+	require.NoError(t, checkErr(limiter.Check(ctx, "user7", "secret", "10.0.0.2")))
+	assert.True(t, mr.Exists("ratelimit:ip:10.0.0.1"))
+	_, err = limiter.Check(ctx, "user8", "secret", "10.0.0.1")
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	require.NoError(t, limiter.ResetBuckets(ctx, "", "2001:db8:1:2::42"))
+	assert.False(t, mr.Exists("ratelimit:ip:2001:db8:1:2::/64"))
+}
+
+func TestNewRateLimiter_InvalidIPPrefix(t *testing.T) {
+	_, err := newRateLimiter(nil, Config{Window: 60, IPv4Prefix: 33})
+	assert.Error(t, err)
+
+	_, err = newRateLimiter(nil, Config{Window: 60, IPv6Prefix: 129})
+	assert.Error(t, err)
+}
+
+func TestNewRateLimiter_InvalidLimits(t *testing.T) {
+	for _, config := range []Config{
+		{Window: 0, LoginLimit: 10, PasswordLimit: 100, IPLimit: 1000},
+		{Window: -60, LoginLimit: 10, PasswordLimit: 100, IPLimit: 1000},
+		{Window: 60, LoginLimit: -1, PasswordLimit: 100, IPLimit: 1000},
+		{Window: 60, LoginLimit: 10, PasswordLimit: -1, IPLimit: 1000},
+		{Window: 60, LoginLimit: 10, PasswordLimit: 100, IPLimit: -1},
+	} {
+		_, err := newRateLimiter(nil, config)
+		assert.Error(t, err)
+	}
+}
+
+func TestNewRateLimiter_RequiresKeySecret(t *testing.T) {
+	config := Config{LoginLimit: 10, PasswordLimit: 100, IPLimit: 1000, Window: 60}
+	for _, secrets := range [][]string{nil, {""}} {
+		config.KeySecrets = secrets
+		_, err := NewRateLimiter(nil, config)
+		assert.ErrorIs(t, err, ErrKeySecretRequired)
+	}
+}
+
+func TestNewRateLimiter_UnknownAlgorithm(t *testing.T) {
+	_, err := newRateLimiter(nil, Config{LoginAlgorithm: "leaky_bucket"})
+	assert.Error(t, err)
+}
+
+// checkErr отбрасывает результат Check, оставляя только ошибку.
+func checkErr(_ Result, err error) error {
+	return err
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed" // Lua-скрипты лимитеров
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed algorithms.lua
+	algorithmsSource string
+	//go:embed allow.lua
+	allowSource string
+	//go:embed inspect.lua
+	inspectSource string
+	//go:embed strike.lua
+	strikeSource string
+
+	allowScript   = redis.NewScript(algorithmsSource + allowSource)
+	inspectScript = redis.NewScript(algorithmsSource + inspectSource)
+	strikeScript  = redis.NewScript(strikeSource)
+)
+
+// redisBackend хранит состояние лимитеров в Redis. Проверка выполняется одним Lua-скриптом
+// (EVALSHA с откатом на EVAL), поэтому реплики сервиса не могут выдать больше токенов, чем позволяет лимит.
+type redisBackend struct {
+	client *redis.Client
+}
+
+func newRedisBackend(client *redis.Client) *redisBackend {
+	return &redisBackend{client: client}
+}
+
+func (b *redisBackend) allowAll(ctx context.Context, specs []limiterSpec, now int64) (decision, error) {
+	keys, args := scriptArgs(specs, now)
+
+	result, err := allowScript.Run(ctx, b.client, keys, args...).Int64Slice()
+	if err != nil {
+		return decision{rejected: -1}, err
+	}
+	if len(result) != 3 {
+		return decision{rejected: -1}, fmt.Errorf("unexpected rate limit script result: %v", result)
+	}
+
+	return decision{
+		rejected:   int(result[0]) - 1,
+		remaining:  int(result[1]),
+		retryAfter: time.Duration(result[2]) * time.Second,
+	}, nil
+}
+
+func (b *redisBackend) inspect(ctx context.Context, specs []limiterSpec, now int64) ([]inspection, error) {
+	keys, args := scriptArgs(specs, now)
+
+	result, err := inspectScript.Run(ctx, b.client, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != len(specs) {
+		return nil, fmt.Errorf("unexpected inspect script result: %v", result)
+	}
+
+	inspections := make([]inspection, len(specs))
+	for i, item := range result {
+		values, ok := item.([]interface{})
+		if !ok || len(values) != 2 {
+			return nil, fmt.Errorf("unexpected inspect script result: %v", item)
+		}
+
+		available, err := strconv.ParseFloat(fmt.Sprint(values[0]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid available value: %w", err)
+		}
+		fullAfter, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid full_after value: %w", err)
+		}
+
+		inspections[i] = inspection{
+			available: available,
+			fullAfter: time.Duration(fullAfter * float64(time.Second)),
+		}
+	}
+
+	return inspections, nil
+}
+
+// scriptArgs раскладывает лимитеры в KEYS и ARGV скриптов allow.lua и inspect.lua.
+func scriptArgs(specs []limiterSpec, now int64) ([]string, []interface{}) {
+	keys := make([]string, 0, len(specs))
+	args := make([]interface{}, 0, len(specs)*4+1)
+	args = append(args, now)
+	for _, spec := range specs {
+		keys = append(keys, spec.key)
+		keys = append(keys, spec.previousKeys...)
+		args = append(args, string(spec.algorithm), spec.limit, spec.window, len(spec.previousKeys))
+	}
+
+	return keys, args
+}
+
+func (b *redisBackend) strike(
+	ctx context.Context, strikesKey, banKey string, policy banPolicy, now int64,
+) (banState, error) {
+	args := make([]interface{}, 0, len(policy.durations)+4)
+	args = append(args, now, policy.threshold, policy.period, policy.forgetAfter)
+	for _, duration := range policy.durations {
+		args = append(args, duration)
+	}
+
+	result, err := strikeScript.Run(ctx, b.client, []string{strikesKey, banKey}, args...).Int64Slice()
+	if err != nil {
+		return banState{}, err
+	}
+	if len(result) != 2 {
+		return banState{}, fmt.Errorf("unexpected strike script result: %v", result)
+	}
+
+	return banState{level: int(result[0]), until: result[1]}, nil
+}
+
+func (b *redisBackend) bans(ctx context.Context, keys []string, _ int64) ([]banState, error) {
+	cmds := make([]*redis.SliceCmd, len(keys))
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.HMGet(ctx, key, "level", "until")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]banState, len(keys))
+	for i, cmd := range cmds {
+		values := cmd.Val()
+		if len(values) != 2 || values[0] == nil || values[1] == nil {
+			continue
+		}
+
+		level, err := strconv.Atoi(fmt.Sprint(values[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ban level: %w", err)
+		}
+		until, err := strconv.ParseInt(fmt.Sprint(values[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ban end: %w", err)
+		}
+		states[i] = banState{level: level, until: until}
+	}
+
+	return states, nil
+}
+
+func (b *redisBackend) reset(ctx context.Context, keys []string) error {
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			if err := pipe.Del(ctx, key).Err(); err != nil {
+				return fmt.Errorf("failed to delete key %s: %w", key, err)
+			}
+		}
+		return nil
+	})
+
+	return err
+}
@@ -0,0 +1,17 @@
+package ratelimit
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// SlidingWindowCounter хранит счётчики текущего и предыдущего фиксированных окон
+// и оценивает число запросов за последние window секунд их взвешенной суммой.
+type SlidingWindowCounter struct {
+	baseLimiter
+}
+
+func NewSlidingWindowCounter(client *redis.Client, key string, limit, window int) *SlidingWindowCounter {
+	return &SlidingWindowCounter{
+		baseLimiter: newBaseLimiter(AlgorithmSlidingWindow, newRedisBackend(client), key, limit, window),
+	}
+}
@@ -0,0 +1,17 @@
+package ratelimit
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// SlidingWindowLog хранит время каждого разрешённого запроса и пропускает новый,
+// если за последние window секунд их было меньше limit.
+type SlidingWindowLog struct {
+	baseLimiter
+}
+
+func NewSlidingWindowLog(client *redis.Client, key string, limit, window int) *SlidingWindowLog {
+	return &SlidingWindowLog{
+		baseLimiter: newBaseLimiter(AlgorithmSlidingLog, newRedisBackend(client), key, limit, window),
+	}
+}
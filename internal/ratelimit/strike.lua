@@ -0,0 +1,34 @@
+-- Учёт отказа бакета и прогрессивный бан субъекта (логина или IP).
+-- KEYS[1] - счётчик отказов, KEYS[2] - бан (хеш с полями level и until).
+-- ARGV: now, threshold, period (сек), forget_after (сек), далее длительности банов по возрастанию (сек).
+-- Когда за period набирается threshold отказов, счётчик сбрасывается, а субъект банится на длительность
+-- следующего уровня. Уровень забывается через forget_after после окончания бана.
+-- Возвращает {level, until}: {0, 0}, если субъект не забанен.
+
+local now = tonumber(ARGV[1])
+local threshold = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+local forget_after = tonumber(ARGV[4])
+
+local strikes = redis.call('INCR', KEYS[1])
+if strikes == 1 then
+	redis.call('EXPIRE', KEYS[1], period)
+end
+if strikes < threshold then
+	return {0, 0}
+end
+redis.call('DEL', KEYS[1])
+
+local ban = redis.call('HMGET', KEYS[2], 'level', 'until')
+local level = tonumber(ban[1]) or 0
+if (tonumber(ban[2]) or 0) + forget_after <= now then
+	level = 0
+end
+level = level + 1
+local duration = tonumber(ARGV[4 + math.min(level, #ARGV - 4)])
+local banned_until = now + duration
+
+redis.call('HSET', KEYS[2], 'level', level, 'until', banned_until)
+redis.call('EXPIRE', KEYS[2], duration + forget_after)
+
+return {level, banned_until}
@@ -4,6 +4,8 @@ package ratelimit
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -60,7 +62,7 @@ func TestTokenBucket_RefillLogic(t *testing.T) {
 
 	// Симулируем: 0 токенов 5 секунд назад
 	initialTime := time.Now().Unix() - 5
-	err := bucket.saveAllowance(ctx, 0, initialTime)
+	err := client.HSet(ctx, "test:refill", "allowance", 0, "timestamp", initialTime).Err()
 	require.NoError(t, err)
 
 	// Должно быть 5 токенов (5 * 10 / 10)
@@ -106,3 +108,80 @@ func TestTokenBucket_IndependentBuckets(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, allowed, "Bucket2 should still work")
 }
+
+func TestTokenBucket_ConcurrentCallersNeverExceedLimit(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+
+	const (
+		limit    = 10
+		replicas = 5
+		callers  = 50
+	)
+
+	ctx := context.Background()
+	var allowedCount atomic.Int64
+	var wg sync.WaitGroup
+
+	// Каждая "реплика" использует свой клиент, как отдельные экземпляры сервиса
+	for r := 0; r < replicas; r++ {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer client.Close()
+
+		for c := 0; c < callers; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				bucket := NewTokenBucket(client, "test:concurrent", limit, 3600)
+				allowed, err := bucket.Allow(ctx)
+				assert.NoError(t, err)
+				if allowed {
+					allowedCount.Add(1)
+				}
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int64(limit), allowedCount.Load())
+}
+
+func TestTokenBucket_ScriptFlushFallsBackToEval(t *testing.T) {
+	client, cleanup := setupTest(t)
+	defer cleanup()
+
+	bucket := NewTokenBucket(client, "test:flush", 2, 60)
+	ctx := context.Background()
+
+	allowed, err := bucket.Allow(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// После сброса кэша скриптов EVALSHA вернёт NOSCRIPT, и бакет должен выполнить EVAL
+	require.NoError(t, client.ScriptFlush(ctx).Err())
+
+	allowed, err = bucket.Allow(ctx)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = bucket.Allow(ctx)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestTokenBucket_SetsExpiration(t *testing.T) {
+	client, cleanup := setupTest(t)
+	defer cleanup()
+
+	bucket := NewTokenBucket(client, "test:ttl", 5, 60)
+	ctx := context.Background()
+
+	_, err := bucket.Allow(ctx)
+	require.NoError(t, err)
+
+	ttl, err := client.TTL(ctx, "test:ttl").Result()
+	require.NoError(t, err)
+	assert.Equal(t, 61*time.Second, ttl)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// GeoRuleRequest - правило по стране или ASN. Kind - "country" или "asn", Value - код страны ("RU")
+// или номер ASN ("13335" или "AS13335").
+type GeoRuleRequest struct {
+	Kind      domain.GeoRuleKind `json:"kind"`
+	Value     string             `json:"value"`
+	Comment   string             `json:"comment,omitempty"`
+	CreatedBy string             `json:"createdBy,omitempty"`
+}
+
+type GeoRuleResponse struct {
+	ListType domain.ListType    `json:"listType"`
+	Kind     domain.GeoRuleKind `json:"kind"`
+	Value    string             `json:"value"`
+	// Rule - правило в виде, в котором оно попадает в ответы /auth и логи: "country:RU" или "asn:AS13335".
+	Rule      string    `json:"rule"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type GeoRulesListResponse struct {
+	Rules []GeoRuleResponse `json:"rules"`
+	Count int               `json:"count"`
+}
+
+func (s *Server) geoRulesHandler(listType domain.ListType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			s.getGeoRulesHandler(w, listType)
+		case http.MethodPost:
+			s.addGeoRuleHandler(w, r, listType)
+		case http.MethodDelete:
+			s.removeGeoRuleHandler(w, r, listType)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) getGeoRulesHandler(w http.ResponseWriter, listType domain.ListType) {
+	rules, err := s.app.GetGeoRules(listType)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Get geo %s failed: %v", listType, err))
+		s.sendError(w, fmt.Sprintf("Failed to get geo %s: %v", listType, err), http.StatusInternalServerError)
+		return
+	}
+
+	response := GeoRulesListResponse{Rules: make([]GeoRuleResponse, len(rules)), Count: len(rules)}
+	for i, rule := range rules {
+		response.Rules[i] = toGeoRuleResponse(rule)
+	}
+
+	s.sendJSON(w, response, http.StatusOK)
+}
+
+func (s *Server) addGeoRuleHandler(w http.ResponseWriter, r *http.Request, listType domain.ListType) {
+	var req GeoRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	comment := strings.TrimSpace(req.Comment)
+	if len(comment) > maxCommentLength {
+		s.sendError(w, fmt.Sprintf("comment is longer than %d characters", maxCommentLength), http.StatusBadRequest)
+		return
+	}
+
+	rule := &domain.GeoRule{
+		ListType:  listType,
+		Kind:      req.Kind,
+		Value:     req.Value,
+		Comment:   comment,
+		CreatedBy: strings.TrimSpace(req.CreatedBy),
+	}
+	if err := s.app.CreateGeoRule(rule); err != nil {
+		if errors.Is(err, domain.ErrInvalidGeoRule) {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Error(fmt.Sprintf("Add geo rule to %s failed: %v", listType, err))
+		s.sendError(w, fmt.Sprintf("Failed to add geo rule to %s: %v", listType, err), http.StatusInternalServerError)
+		return
+	}
+
+	s.sendJSON(w, toGeoRuleResponse(*rule), http.StatusCreated)
+}
+
+func (s *Server) removeGeoRuleHandler(w http.ResponseWriter, r *http.Request, listType domain.ListType) {
+	var req GeoRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.app.DeleteGeoRule(listType, req.Kind, req.Value); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidGeoRule):
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrGeoRuleNotFound):
+			s.sendError(w, fmt.Sprintf("Geo rule %s:%s not found in %s", req.Kind, req.Value, listType),
+				http.StatusNotFound)
+		default:
+			s.sendError(w, fmt.Sprintf("Failed to remove geo rule from %s: %v", listType, err),
+				http.StatusInternalServerError)
+		}
+		return
+	}
+
+	s.sendJSON(w, map[string]string{
+		"message": fmt.Sprintf("Geo rule removed from %s successfully", listType),
+	}, http.StatusOK)
+}
+
+func toGeoRuleResponse(rule domain.GeoRule) GeoRuleResponse {
+	return GeoRuleResponse{
+		ListType:  rule.ListType,
+		Kind:      rule.Kind,
+		Value:     rule.Value,
+		Rule:      rule.String(),
+		Comment:   rule.Comment,
+		CreatedBy: rule.CreatedBy,
+		CreatedAt: rule.CreatedAt,
+	}
+}
@@ -5,27 +5,97 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/gomonov/otus-go-project/internal/ratelimit"
 )
 
 type CreateSubnetRequest struct {
 	CIDR string `json:"cidr"`
+	// Range - диапазон вместо CIDR: "203.0.113.17-203.0.113.90" или "203.0.113.*". Он добавляется
+	// минимальным набором покрывающих подсетей.
+	Range string `json:"range,omitempty"`
+	// TTL (например, "24h") или ExpiresAt делают запись временной.
+	TTL       string     `json:"ttl,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Comment   string     `json:"comment,omitempty"`
+	// CreatedBy - кто добавляет подсеть. CLI передаёт имя пользователя ОС.
+	CreatedBy string   `json:"createdBy,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// Trust - уровень доверия подсети белого списка: bypass_all (по умолчанию), bypass_ip или relaxed.
+	Trust string `json:"trust,omitempty"`
 }
 
 type DeleteSubnetRequest struct {
 	CIDR string `json:"cidr"`
+	// Range удаляет все подсети, которыми был добавлен тот же диапазон.
+	Range string `json:"range,omitempty"`
 }
 
 type SubnetResponse struct {
-	ListType domain.ListType `json:"listType"`
-	CIDR     string          `json:"cidr"`
+	ListType  domain.ListType `json:"listType"`
+	CIDR      string          `json:"cidr"`
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
+	Comment   string          `json:"comment,omitempty"`
+	CreatedBy string          `json:"createdBy,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Tags      []string        `json:"tags"`
+	Source    string          `json:"source,omitempty"`
+	Trust     string          `json:"trust,omitempty"`
+	// Warnings - пересечения с другими подсетями, с которыми подсеть добавлена при force=true.
+	Warnings []OverlapResponse `json:"warnings,omitempty"`
+}
+
+// SubnetRangeResponse - подсети, которыми добавлен или удалён диапазон.
+type SubnetRangeResponse struct {
+	Range   string           `json:"range"`
+	Subnets []SubnetResponse `json:"subnets"`
+	Count   int              `json:"count"`
+	// Warnings - пересечения подсетей диапазона, с которыми он добавлен при force=true.
+	Warnings []OverlapResponse `json:"warnings,omitempty"`
+}
+
+const maxCommentLength = 1024
+
+// OverlapResponse - пересечение с подсетью Other.
+type OverlapResponse struct {
+	Kind  domain.OverlapKind `json:"kind"`
+	Other SubnetResponse     `json:"other"`
+}
+
+// OverlapErrorResponse возвращается, если подсеть не добавлена из-за пересечений.
+type OverlapErrorResponse struct {
+	Error    string            `json:"error"`
+	Overlaps []OverlapResponse `json:"overlaps"`
+}
+
+// ConflictResponse - пара пересекающихся подсетей: Subnet перекрыта подсетью Other или конфликтует с ней.
+type ConflictResponse struct {
+	Kind   domain.OverlapKind `json:"kind"`
+	Subnet SubnetResponse     `json:"subnet"`
+	Other  SubnetResponse     `json:"other"`
+}
+
+type FeedsResponse struct {
+	Feeds []domain.FeedStatus `json:"feeds"`
+	Count int                 `json:"count"`
+}
+
+type ConflictsResponse struct {
+	Conflicts []ConflictResponse `json:"conflicts"`
+	Count     int                `json:"count"`
 }
 
 type SubnetsListResponse struct {
 	Subnets []SubnetResponse `json:"subnets"`
 	Count   int              `json:"count"`
+	// NextCursor передаётся в ?cursor= за следующей страницей. Пуст на последней странице.
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -36,10 +106,25 @@ func (s *Server) setupRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", s.rootHandler)
-	mux.HandleFunc("/blacklist", s.blacklistHandler)
-	mux.HandleFunc("/whitelist", s.whitelistHandler)
+	mux.HandleFunc("/blacklist", s.listHandler(domain.Blacklist))
+	mux.HandleFunc("/whitelist", s.listHandler(domain.Whitelist))
+	mux.HandleFunc("/greylist", s.listHandler(domain.Greylist))
+	mux.HandleFunc("/blacklist/import", s.importHandler(domain.Blacklist))
+	mux.HandleFunc("/whitelist/import", s.importHandler(domain.Whitelist))
+	mux.HandleFunc("/greylist/import", s.importHandler(domain.Greylist))
+	mux.HandleFunc("/blacklist/export", s.exportHandler(domain.Blacklist))
+	mux.HandleFunc("/whitelist/export", s.exportHandler(domain.Whitelist))
+	mux.HandleFunc("/greylist/export", s.exportHandler(domain.Greylist))
 	mux.HandleFunc("/auth", s.authHandler)
 	mux.HandleFunc("/reset", s.resetHandler)
+	mux.HandleFunc("/buckets", s.bucketsHandler)
+	mux.HandleFunc("/lists/conflicts", s.conflictsHandler)
+	mux.HandleFunc("/explain", s.explainHandler)
+	mux.HandleFunc("/feeds", s.feedsHandler)
+	mux.HandleFunc("/geo/blacklist", s.geoRulesHandler(domain.Blacklist))
+	mux.HandleFunc("/geo/whitelist", s.geoRulesHandler(domain.Whitelist))
+	mux.HandleFunc("/logins/blacklist", s.loginRulesHandler(domain.Blacklist))
+	mux.HandleFunc("/logins/whitelist", s.loginRulesHandler(domain.Whitelist))
 
 	return mux
 }
@@ -64,32 +149,62 @@ func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
 			{
 				"method":      "GET",
 				"path":        "/blacklist",
-				"description": "Get all subnets from blacklist",
+				"description": "Get blacklist (pages: ?limit=, ?cursor=), ?tag=, ?source=, ?contains=, ?within=, ?created_since=",
 			},
 			{
 				"method":      "POST",
 				"path":        "/blacklist",
-				"description": "Add subnet to blacklist",
+				"description": "Add subnet or range (start-end, 203.0.113.*) to blacklist, ?force=true to add despite overlaps",
 			},
 			{
 				"method":      "DELETE",
 				"path":        "/blacklist",
-				"description": "Remove subnet from blacklist",
+				"description": "Remove subnet or range from blacklist",
 			},
 			{
 				"method":      "GET",
 				"path":        "/whitelist",
-				"description": "Get all subnets from whitelist",
+				"description": "Get subnets from whitelist, same parameters as for blacklist",
 			},
 			{
 				"method":      "POST",
 				"path":        "/whitelist",
-				"description": "Add subnet to whitelist",
+				"description": "Add subnet or range (start-end, 203.0.113.*) to whitelist, ?force=true to add despite overlaps",
 			},
 			{
 				"method":      "DELETE",
 				"path":        "/whitelist",
-				"description": "Remove subnet from whitelist",
+				"description": "Remove subnet or range from whitelist",
+			},
+			{
+				"method":      "GET, POST, DELETE",
+				"path":        "/greylist",
+				"description": "Manage greylist: subnets checked with stricter rate limits instead of being blocked",
+			},
+			{
+				"method":      "POST",
+				"path":        "/{blacklist,whitelist,greylist}/import",
+				"description": "Import subnets as text, csv or json (?format=, ?mode=merge|replace, ?dry_run=true)",
+			},
+			{
+				"method":      "GET",
+				"path":        "/{blacklist,whitelist,greylist}/export",
+				"description": "Export subnets as text, csv or json (?format=)",
+			},
+			{
+				"method":      "GET",
+				"path":        "/lists/conflicts",
+				"description": "Report overlapping subnets within and across lists",
+			},
+			{
+				"method":      "GET, POST, DELETE",
+				"path":        "/geo/{blacklist,whitelist}",
+				"description": "Manage country and ASN rules, {\"kind\": \"country\"|\"asn\", \"value\": ...}",
+			},
+			{
+				"method":      "GET, POST, DELETE",
+				"path":        "/logins/{blacklist,whitelist}",
+				"description": "Manage login rules, {\"kind\": \"exact\"|\"glob\"|\"regex\", \"pattern\": ...}",
 			},
 			{
 				"method":      "POST",
@@ -99,7 +214,22 @@ func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
 			{
 				"method":      "POST",
 				"path":        "/reset",
-				"description": "Reset rate limit buckets for login and/or IP",
+				"description": "Reset rate limit buckets and lift bans for login and/or IP",
+			},
+			{
+				"method":      "GET",
+				"path":        "/buckets",
+				"description": "Show rate limit buckets and bans for login, password hash and/or IP without consuming tokens",
+			},
+			{
+				"method":      "GET",
+				"path":        "/explain",
+				"description": "Explain which list entries and buckets affect an IP and/or login without changing state",
+			},
+			{
+				"method":      "GET",
+				"path":        "/feeds",
+				"description": "Show threat feed synchronisation status",
 			},
 		},
 	}
@@ -107,160 +237,113 @@ func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(apiInfo)
 }
 
-func (s *Server) blacklistHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		s.getBlacklistHandler(w)
-	case http.MethodPost:
-		s.addToBlacklistHandler(w, r)
-	case http.MethodDelete:
-		s.removeFromBlacklistHandler(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func (s *Server) whitelistHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		s.getWhitelistHandler(w)
-	case http.MethodPost:
-		s.addToWhitelistHandler(w, r)
-	case http.MethodDelete:
-		s.removeFromWhitelistHandler(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (s *Server) listHandler(listType domain.ListType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			s.getSubnetsHandler(w, r, listType)
+		case http.MethodPost:
+			s.addSubnetHandler(w, r, listType)
+		case http.MethodDelete:
+			s.removeSubnetHandler(w, r, listType)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
 	}
 }
 
-func (s *Server) getBlacklistHandler(w http.ResponseWriter) {
-	subnets, err := s.app.GetSubnetsByListType(domain.Blacklist)
+func (s *Server) getSubnetsHandler(w http.ResponseWriter, r *http.Request, listType domain.ListType) {
+	filter, err := subnetFilter(r, time.Now())
 	if err != nil {
-		s.sendError(w, fmt.Sprintf("Failed to get blacklist: %v", err), http.StatusInternalServerError)
+		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	response := s.convertSubnetsToResponse(subnets)
-	s.sendJSON(w, response, http.StatusOK)
-}
-
-func (s *Server) getWhitelistHandler(w http.ResponseWriter) {
-	subnets, err := s.app.GetSubnetsByListType(domain.Whitelist)
+	page, err := s.app.GetSubnetsByListType(listType, filter)
 	if err != nil {
-		s.sendError(w, fmt.Sprintf("Failed to get whitelist: %v", err), http.StatusInternalServerError)
+		s.sendError(w, fmt.Sprintf("Failed to get %s: %v", listType, err), http.StatusInternalServerError)
 		return
 	}
 
-	response := s.convertSubnetsToResponse(subnets)
+	response := s.convertSubnetsToResponse(page.Subnets)
+	response.NextCursor = page.NextCursor
 	s.sendJSON(w, response, http.StatusOK)
 }
 
-func (s *Server) addToBlacklistHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) addSubnetHandler(w http.ResponseWriter, r *http.Request, listType domain.ListType) {
 	var req CreateSubnetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.sendError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if req.CIDR == "" {
-		s.sendError(w, "CIDR is required", http.StatusBadRequest)
+	if req.Range != "" {
+		s.addRangeHandler(w, r, listType, req)
 		return
 	}
-
-	subnet := &domain.Subnet{
-		ListType: domain.Blacklist,
-		CIDR:     req.CIDR,
-	}
-
-	if err := s.app.CreateSubnet(subnet); err != nil {
-		s.sendError(w, fmt.Sprintf("Failed to add to blacklist: %v", err), http.StatusInternalServerError)
+	if req.CIDR == "" {
+		s.sendError(w, "CIDR or range is required", http.StatusBadRequest)
 		return
 	}
 
-	response := SubnetResponse{
-		ListType: subnet.ListType,
-		CIDR:     subnet.CIDR,
-	}
-
-	s.sendJSON(w, response, http.StatusCreated)
-}
-
-func (s *Server) addToWhitelistHandler(w http.ResponseWriter, r *http.Request) {
-	var req CreateSubnetRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+	subnet, err := newSubnet(listType, req, time.Now())
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if req.CIDR == "" {
-		s.sendError(w, "CIDR is required", http.StatusBadRequest)
+	force, err := forceParam(r)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	subnet := &domain.Subnet{
-		ListType: domain.Whitelist,
-		CIDR:     req.CIDR,
-	}
-
-	if err := s.app.CreateSubnet(subnet); err != nil {
-		s.sendError(w, fmt.Sprintf("Failed to add to whitelist: %v", err), http.StatusInternalServerError)
+	overlaps, err := s.app.CreateSubnet(subnet, force)
+	if err != nil {
+		s.sendCreateSubnetError(w, listType, err)
 		return
 	}
 
-	response := SubnetResponse{
-		ListType: subnet.ListType,
-		CIDR:     subnet.CIDR,
-	}
-
+	response := toSubnetResponse(*subnet)
+	response.Warnings = toOverlapResponses(overlaps)
 	s.sendJSON(w, response, http.StatusCreated)
 }
 
-func (s *Server) removeFromBlacklistHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) removeSubnetHandler(w http.ResponseWriter, r *http.Request, listType domain.ListType) {
 	var req DeleteSubnetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.sendError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if req.CIDR == "" {
-		s.sendError(w, "CIDR is required", http.StatusBadRequest)
-		return
-	}
-
-	if err := s.app.DeleteSubnet(domain.Blacklist, req.CIDR); err != nil {
-		if errors.Is(err, domain.ErrSubnetNotFound) {
-			s.sendError(w, "Subnet not found in blacklist", http.StatusNotFound)
-		} else {
-			s.sendError(w, fmt.Sprintf("Failed to remove from blacklist: %v", err), http.StatusInternalServerError)
-		}
+	if req.Range != "" {
+		s.removeRangeHandler(w, listType, req)
 		return
 	}
-
-	s.sendJSON(w, map[string]string{"message": "Subnet removed from blacklist successfully"}, http.StatusOK)
-}
-
-func (s *Server) removeFromWhitelistHandler(w http.ResponseWriter, r *http.Request) {
-	var req DeleteSubnetRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+	if req.CIDR == "" {
+		s.sendError(w, "CIDR or range is required", http.StatusBadRequest)
 		return
 	}
 
-	if req.CIDR == "" {
-		s.sendError(w, "CIDR is required", http.StatusBadRequest)
+	cidr, err := domain.CanonicalCIDR(req.CIDR)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err := s.app.DeleteSubnet(domain.Whitelist, req.CIDR); err != nil {
+	if err := s.app.DeleteSubnet(listType, cidr); err != nil {
 		if errors.Is(err, domain.ErrSubnetNotFound) {
-			s.sendError(w, "Subnet not found in whitelist", http.StatusNotFound)
+			s.sendError(w, fmt.Sprintf("Subnet %s not found in %s", cidr, listType), http.StatusNotFound)
 		} else {
-			s.sendError(w, fmt.Sprintf("Failed to remove from whitelist: %v", err), http.StatusInternalServerError)
+			s.sendError(w, fmt.Sprintf("Failed to remove from %s: %v", listType, err), http.StatusInternalServerError)
 		}
 		return
 	}
 
-	s.sendJSON(w, map[string]string{"message": "Subnet removed from whitelist successfully"}, http.StatusOK)
+	s.sendJSON(w, map[string]string{
+		"message": fmt.Sprintf("Subnet removed from %s successfully", listType),
+		"cidr":    cidr,
+	}, http.StatusOK)
 }
 
 func (s *Server) authHandler(w http.ResponseWriter, r *http.Request) {
@@ -282,6 +365,12 @@ func (s *Server) authHandler(w http.ResponseWriter, r *http.Request) {
 
 	response, err := s.app.CheckAuth(req)
 	if err != nil {
+		if errors.Is(err, domain.ErrIPListsUnavailable) {
+			s.logger.Error(fmt.Sprintf("Auth check failed: %v", err))
+			s.sendError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
 		if isValidationError(err) {
 			s.sendError(w, err.Error(), http.StatusBadRequest)
 			return
@@ -292,6 +381,10 @@ func (s *Server) authHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !response.OK && response.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(response.RetryAfter))
+	}
+
 	s.sendJSON(w, response, http.StatusOK)
 }
 
@@ -322,11 +415,465 @@ func (s *Server) resetHandler(w http.ResponseWriter, r *http.Request) {
 	s.sendJSON(w, response, http.StatusOK)
 }
 
+func (s *Server) bucketsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	req := domain.BucketsRequest{
+		Login:        query.Get("login"),
+		PasswordHash: query.Get("password_hash"),
+		IP:           query.Get("ip"),
+	}
+
+	if req.Login == "" && req.PasswordHash == "" && req.IP == "" {
+		s.sendError(w, "either login, password_hash or ip must be provided", http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.app.GetBuckets(req)
+	if err != nil {
+		if errors.Is(err, ratelimit.ErrInvalidPasswordHash) {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.logger.Error(fmt.Sprintf("Get buckets failed: %v", err))
+		s.sendError(w, fmt.Sprintf("Get buckets failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.sendJSON(w, response, http.StatusOK)
+}
+
+// maxImportSize ограничивает тело запроса импорта.
+const maxImportSize = 32 << 20
+
+func (s *Server) importHandler(listType domain.ListType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		format, err := parseListFormat(query.Get("format"), r.Header.Get("Content-Type"))
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mode, err := domain.ParseImportMode(query.Get("mode"))
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		dryRun := false
+		if value := query.Get("dry_run"); value != "" {
+			if dryRun, err = strconv.ParseBool(value); err != nil {
+				s.sendError(w, fmt.Sprintf("invalid dry_run value: %s", value), http.StatusBadRequest)
+				return
+			}
+		}
+
+		subnets, err := readSubnets(format, http.MaxBytesReader(w, r.Body, maxImportSize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				s.sendError(w, fmt.Sprintf("import is larger than %d bytes", maxImportSize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		createdBy := strings.TrimSpace(query.Get("created_by"))
+		for i := range subnets {
+			subnets[i].ListType = listType
+			if subnets[i].CreatedBy == "" {
+				subnets[i].CreatedBy = createdBy
+			}
+		}
+
+		result, err := s.app.ImportSubnets(listType, subnets, domain.ImportOptions{Mode: mode, DryRun: dryRun})
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("Import to %s failed: %v", listType, err))
+			s.sendError(w, fmt.Sprintf("Failed to import to %s: %v", listType, err), http.StatusInternalServerError)
+			return
+		}
+
+		s.sendJSON(w, result, http.StatusOK)
+	}
+}
+
+func (s *Server) exportHandler(listType domain.ListType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		format, err := parseListFormat(r.URL.Query().Get("format"), "")
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		subnets, err := s.app.ExportSubnets(listType)
+		if err != nil {
+			s.sendError(w, fmt.Sprintf("Failed to export %s: %v", listType, err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", listFormatContentTypes[format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFileName(listType, format)))
+		w.WriteHeader(http.StatusOK)
+
+		if err := writeSubnets(format, w, listType, subnets); err != nil {
+			s.logger.Error(fmt.Sprintf("Failed to write %s export: %v", listType, err))
+		}
+	}
+}
+
+func exportFileName(listType domain.ListType, format listFormat) string {
+	extensions := map[listFormat]string{listFormatText: "txt", listFormatCSV: "csv", listFormatJSON: "json"}
+	return fmt.Sprintf("%s.%s", listType, extensions[format])
+}
+
+func (s *Server) conflictsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	conflicts, err := s.app.GetConflicts()
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Get conflicts failed: %v", err))
+		s.sendError(w, fmt.Sprintf("Get conflicts failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := ConflictsResponse{
+		Conflicts: make([]ConflictResponse, len(conflicts)),
+		Count:     len(conflicts),
+	}
+	for i, conflict := range conflicts {
+		response.Conflicts[i] = ConflictResponse{
+			Kind:   conflict.Kind,
+			Subnet: toSubnetResponse(conflict.Subnet),
+			Other:  toSubnetResponse(conflict.Other),
+		}
+	}
+
+	s.sendJSON(w, response, http.StatusOK)
+}
+
+func (s *Server) sendCreateSubnetError(w http.ResponseWriter, listType domain.ListType, err error) {
+	var overlapErr *domain.SubnetOverlapError
+	if errors.As(err, &overlapErr) {
+		s.sendJSON(w, OverlapErrorResponse{
+			Error:    err.Error() + ", use force=true to add it anyway",
+			Overlaps: toOverlapResponses(overlapErr.Overlaps),
+		}, http.StatusConflict)
+		return
+	}
+
+	s.sendError(w, fmt.Sprintf("Failed to add to %s: %v", listType, err), http.StatusInternalServerError)
+}
+
+func forceParam(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("force")
+	if value == "" {
+		return false, nil
+	}
+
+	force, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid force value: %s", value)
+	}
+
+	return force, nil
+}
+
+func toOverlapResponses(overlaps []domain.SubnetOverlap) []OverlapResponse {
+	if len(overlaps) == 0 {
+		return nil
+	}
+
+	responses := make([]OverlapResponse, len(overlaps))
+	for i, overlap := range overlaps {
+		responses[i] = OverlapResponse{Kind: overlap.Kind, Other: toSubnetResponse(overlap.Other)}
+	}
+
+	return responses
+}
+
+// addRangeHandler добавляет подсети, покрывающие диапазон из запроса, в одной транзакции.
+func (s *Server) addRangeHandler(
+	w http.ResponseWriter, r *http.Request, listType domain.ListType, req CreateSubnetRequest,
+) {
+	if req.CIDR != "" {
+		s.sendError(w, "only one of cidr and range can be set", http.StatusBadRequest)
+		return
+	}
+
+	ipRange, err := domain.ParseIPRange(req.Range)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	template, err := subnetTemplate(listType, req, time.Now())
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	force, err := forceParam(r)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subnets, overlaps, err := s.app.CreateSubnetRange(*template, ipRange, force)
+	if err != nil {
+		s.sendCreateSubnetError(w, listType, err)
+		return
+	}
+
+	s.sendJSON(w, SubnetRangeResponse{
+		Range:    ipRange.String(),
+		Subnets:  s.convertSubnetsToResponse(subnets).Subnets,
+		Count:    len(subnets),
+		Warnings: toOverlapResponses(overlaps),
+	}, http.StatusCreated)
+}
+
+func (s *Server) removeRangeHandler(w http.ResponseWriter, listType domain.ListType, req DeleteSubnetRequest) {
+	if req.CIDR != "" {
+		s.sendError(w, "only one of cidr and range can be set", http.StatusBadRequest)
+		return
+	}
+
+	ipRange, err := domain.ParseIPRange(req.Range)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	subnets, err := s.app.DeleteSubnetRange(listType, ipRange)
+	if err != nil {
+		if errors.Is(err, domain.ErrSubnetNotFound) {
+			s.sendError(w, fmt.Sprintf("Range %s not found in %s", ipRange, listType), http.StatusNotFound)
+		} else {
+			s.sendError(w, fmt.Sprintf("Failed to remove from %s: %v", listType, err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	s.sendJSON(w, SubnetRangeResponse{
+		Range:   ipRange.String(),
+		Subnets: s.convertSubnetsToResponse(subnets).Subnets,
+		Count:   len(subnets),
+	}, http.StatusOK)
+}
+
+// newSubnet проверяет запрос на добавление подсети и собирает по нему запись списка.
+func newSubnet(listType domain.ListType, req CreateSubnetRequest, now time.Time) (*domain.Subnet, error) {
+	cidr, err := domain.CanonicalCIDR(req.CIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	subnet, err := subnetTemplate(listType, req, now)
+	if err != nil {
+		return nil, err
+	}
+	subnet.CIDR = cidr
+
+	return subnet, nil
+}
+
+// subnetTemplate проверяет поля запроса, кроме подсети: для диапазона они общие у всех его подсетей.
+func subnetTemplate(listType domain.ListType, req CreateSubnetRequest, now time.Time) (*domain.Subnet, error) {
+	expiresAt, err := subnetExpiry(req, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Comment) > maxCommentLength {
+		return nil, fmt.Errorf("comment is longer than %d characters", maxCommentLength)
+	}
+
+	tags, err := domain.NormalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	trust, err := domain.NormalizeTrustLevel(listType, strings.TrimSpace(req.Trust))
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Subnet{
+		ListType:  listType,
+		ExpiresAt: expiresAt,
+		Comment:   strings.TrimSpace(req.Comment),
+		CreatedBy: strings.TrimSpace(req.CreatedBy),
+		Tags:      tags,
+		Trust:     trust,
+	}, nil
+}
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// subnetFilter разбирает параметры выборки подсетей: tag, source, contains (IP), within (подсеть),
+// created_since (время RFC 3339 или давность вроде "24h"), limit и cursor.
+// Без limit и cursor список возвращается целиком, как до появления страниц: на этом рассчитаны клиенты,
+// которые синхронизируют списки. С cursor, но без limit страница содержит defaultPageSize подсетей.
+func subnetFilter(r *http.Request, now time.Time) (domain.SubnetFilter, error) {
+	query := r.URL.Query()
+	filter := domain.SubnetFilter{
+		Tag:    strings.ToLower(strings.TrimSpace(query.Get("tag"))),
+		Source: strings.TrimSpace(query.Get("source")),
+	}
+
+	if value := query.Get("contains"); value != "" {
+		addr, err := netip.ParseAddr(strings.TrimSpace(value))
+		if err != nil || addr.Zone() != "" {
+			return filter, fmt.Errorf("invalid contains value %q: expected an IP address", value)
+		}
+		filter.Contains = addr.Unmap()
+	}
+
+	if value := query.Get("within"); value != "" {
+		cidr, err := domain.CanonicalCIDR(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Within = netip.MustParsePrefix(cidr)
+	}
+
+	if value := query.Get("created_since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			age, durationErr := time.ParseDuration(value)
+			if durationErr != nil || age <= 0 {
+				return filter, fmt.Errorf("invalid created_since value %q: expected RFC 3339 time or duration", value)
+			}
+			since = now.Add(-age)
+		}
+		filter.CreatedSince = since
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return filter, fmt.Errorf("invalid limit value %q: expected 1 to %d", value, maxPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		after, err := domain.DecodeSubnetCursor(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.After = after
+		if filter.Limit == 0 {
+			filter.Limit = defaultPageSize
+		}
+	}
+
+	return filter, nil
+}
+
+func (s *Server) explainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	req := domain.ExplainRequest{
+		IP:    query.Get("ip"),
+		Login: query.Get("login"),
+	}
+
+	if req.IP == "" && req.Login == "" {
+		s.sendError(w, "either ip or login must be provided", http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.app.Explain(req)
+	if err != nil {
+		if errors.Is(err, domain.ErrIPListsUnavailable) {
+			s.sendError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		if isValidationError(err) {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.logger.Error(fmt.Sprintf("Explain failed: %v", err))
+		s.sendError(w, fmt.Sprintf("Explain failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.sendJSON(w, response, http.StatusOK)
+}
+
+func (s *Server) feedsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feeds, err := s.app.FeedStatuses()
+	if err != nil {
+		s.sendError(w, fmt.Sprintf("Failed to get feed statuses: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.sendJSON(w, FeedsResponse{Feeds: feeds, Count: len(feeds)}, http.StatusOK)
+}
+
+// subnetExpiry возвращает срок действия добавляемой подсети или nil для постоянной записи.
+func subnetExpiry(req CreateSubnetRequest, now time.Time) (*time.Time, error) {
+	if req.TTL != "" && req.ExpiresAt != nil {
+		return nil, errors.New("only one of ttl and expiresAt can be set")
+	}
+
+	expiresAt := req.ExpiresAt
+	if req.TTL != "" {
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl: %w", err)
+		}
+		if ttl <= 0 {
+			return nil, errors.New("ttl must be positive")
+		}
+		expiry := now.Add(ttl)
+		expiresAt = &expiry
+	}
+
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, errors.New("expiresAt must be in the future")
+	}
+
+	return expiresAt, nil
+}
+
 func isValidationError(err error) bool {
 	errorMsg := err.Error()
-	return strings.Contains(errorMsg, "invalid IP address") ||
-		strings.Contains(errorMsg, "only IPv4 addresses are supported") ||
-		strings.Contains(errorMsg, "IP lists not initialized")
+	return strings.Contains(errorMsg, "invalid IP address")
 }
 
 func (s *Server) convertSubnetsToResponse(subnets []domain.Subnet) SubnetsListResponse {
@@ -336,15 +883,32 @@ func (s *Server) convertSubnetsToResponse(subnets []domain.Subnet) SubnetsListRe
 	}
 
 	for i, subnet := range subnets {
-		response.Subnets[i] = SubnetResponse{
-			ListType: subnet.ListType,
-			CIDR:     subnet.CIDR,
-		}
+		response.Subnets[i] = toSubnetResponse(subnet)
 	}
 
 	return response
 }
 
+func toSubnetResponse(subnet domain.Subnet) SubnetResponse {
+	tags := subnet.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return SubnetResponse{
+		ListType:  subnet.ListType,
+		CIDR:      subnet.CIDR,
+		ExpiresAt: subnet.ExpiresAt,
+		Comment:   subnet.Comment,
+		CreatedBy: subnet.CreatedBy,
+		CreatedAt: subnet.CreatedAt,
+		UpdatedAt: subnet.UpdatedAt,
+		Tags:      tags,
+		Source:    subnet.Source,
+		Trust:     string(subnet.Trust),
+	}
+}
+
 func (s *Server) sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statusCode)
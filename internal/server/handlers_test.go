@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubnetFilter_Paging(t *testing.T) {
+	cursor := domain.EncodeSubnetCursor("10.0.0.0/8")
+
+	tests := []struct {
+		query string
+		limit int
+	}{
+		// Без limit и cursor список не делится на страницы
+		{query: "", limit: 0},
+		{query: "?tag=vpn", limit: 0},
+		{query: "?limit=10", limit: 10},
+		{query: "?cursor=" + cursor, limit: defaultPageSize},
+		{query: "?limit=10&cursor=" + cursor, limit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			filter, err := subnetFilter(httptest.NewRequest("GET", "/blacklist"+tt.query, nil), time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, tt.limit, filter.Limit)
+		})
+	}
+
+	_, err := subnetFilter(httptest.NewRequest("GET", "/blacklist?limit=0", nil), time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,278 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// listFormat - формат импорта и экспорта списков.
+type listFormat string
+
+const (
+	// listFormatText - по подсети в строке, после # - комментарий. Строки из одного комментария пропускаются.
+	listFormatText listFormat = "text"
+	// listFormatCSV - колонки cidr, comment, tags (через ;), expires_at (RFC 3339), created_by, created_at, trust.
+	// Заголовок необязателен.
+	listFormatCSV listFormat = "csv"
+	// listFormatJSON - {"subnets": [...]} как в ответе GET /blacklist или просто массив подсетей.
+	listFormatJSON listFormat = "json"
+)
+
+var listFormatContentTypes = map[listFormat]string{
+	listFormatText: "text/plain; charset=utf-8",
+	listFormatCSV:  "text/csv; charset=utf-8",
+	listFormatJSON: "application/json; charset=utf-8",
+}
+
+// parseListFormat определяет формат по параметру format, а без него - по Content-Type запроса.
+func parseListFormat(format, contentType string) (listFormat, error) {
+	if format == "" {
+		switch {
+		case strings.HasPrefix(contentType, "text/csv"):
+			return listFormatCSV, nil
+		case strings.HasPrefix(contentType, "application/json"):
+			return listFormatJSON, nil
+		default:
+			return listFormatText, nil
+		}
+	}
+
+	switch listFormat(format) {
+	case listFormatText, listFormatCSV, listFormatJSON:
+		return listFormat(format), nil
+	default:
+		return "", fmt.Errorf("unknown format: %s, expected text, csv or json", format)
+	}
+}
+
+var csvColumns = []string{"cidr", "comment", "tags", "expires_at", "created_by", "created_at", "trust"}
+
+// readSubnets разбирает импортируемые подсети. Ошибки содержат номер строки.
+func readSubnets(format listFormat, r io.Reader) ([]domain.Subnet, error) {
+	switch format {
+	case listFormatCSV:
+		return readSubnetsCSV(r)
+	case listFormatJSON:
+		return readSubnetsJSON(r)
+	case listFormatText:
+	}
+
+	return readSubnetsText(r)
+}
+
+func readSubnetsText(r io.Reader) ([]domain.Subnet, error) {
+	var subnets []domain.Subnet
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, comment, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		subnet, err := newImportedSubnet(text, comment, nil, "")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+
+	return subnets, nil
+}
+
+func readSubnetsCSV(r io.Reader) ([]domain.Subnet, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	columns := csvColumns
+	var subnets []domain.Subnet
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if len(subnets) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "cidr") {
+			columns = make([]string, len(record))
+			for i, column := range record {
+				columns[i] = strings.ToLower(strings.TrimSpace(column))
+			}
+			continue
+		}
+
+		fields := make(map[string]string, len(columns))
+		for i, value := range record {
+			if i < len(columns) {
+				fields[columns[i]] = strings.TrimSpace(value)
+			}
+		}
+
+		var tags []string
+		if fields["tags"] != "" {
+			tags = strings.Split(fields["tags"], ";")
+		}
+
+		subnet, err := newImportedSubnet(fields["cidr"], fields["comment"], tags, fields["expires_at"])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		subnet.CreatedBy = fields["created_by"]
+		subnet.Trust = domain.TrustLevel(fields["trust"])
+		subnets = append(subnets, subnet)
+	}
+
+	return subnets, nil
+}
+
+type importedSubnetJSON struct {
+	CIDR      string     `json:"cidr"`
+	Comment   string     `json:"comment"`
+	CreatedBy string     `json:"createdBy"`
+	Tags      []string   `json:"tags"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Trust     string     `json:"trust"`
+}
+
+func readSubnetsJSON(r io.Reader) ([]domain.Subnet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+
+	var entries []importedSubnetJSON
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		var list struct {
+			Subnets []importedSubnetJSON `json:"subnets"`
+		}
+		err = json.Unmarshal(data, &list)
+		entries = list.Subnets
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	subnets := make([]domain.Subnet, 0, len(entries))
+	for i, entry := range entries {
+		subnet, err := newImportedSubnet(entry.CIDR, entry.Comment, entry.Tags, "")
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		subnet.CreatedBy = entry.CreatedBy
+		subnet.ExpiresAt = entry.ExpiresAt
+		subnet.Trust = domain.TrustLevel(entry.Trust)
+		subnets = append(subnets, subnet)
+	}
+
+	return subnets, nil
+}
+
+func newImportedSubnet(cidr, comment string, tags []string, expiresAt string) (domain.Subnet, error) {
+	canonical, err := domain.CanonicalCIDR(cidr)
+	if err != nil {
+		return domain.Subnet{}, err
+	}
+
+	comment = strings.TrimSpace(comment)
+	if len(comment) > maxCommentLength {
+		return domain.Subnet{}, fmt.Errorf("comment is longer than %d characters", maxCommentLength)
+	}
+
+	normalized, err := domain.NormalizeTags(tags)
+	if err != nil {
+		return domain.Subnet{}, err
+	}
+
+	subnet := domain.Subnet{CIDR: canonical, Comment: comment, Tags: normalized}
+	if expiresAt != "" {
+		expiry, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return domain.Subnet{}, fmt.Errorf("invalid expires_at: %s", expiresAt)
+		}
+		subnet.ExpiresAt = &expiry
+	}
+
+	return subnet, nil
+}
+
+// writeSubnets записывает подсети в формате, который принимает readSubnets.
+func writeSubnets(format listFormat, w io.Writer, listType domain.ListType, subnets []domain.Subnet) error {
+	switch format {
+	case listFormatCSV:
+		return writeSubnetsCSV(w, subnets)
+	case listFormatJSON:
+		response := SubnetsListResponse{Subnets: make([]SubnetResponse, len(subnets)), Count: len(subnets)}
+		for i, subnet := range subnets {
+			response.Subnets[i] = toSubnetResponse(subnet)
+		}
+		return json.NewEncoder(w).Encode(response)
+	case listFormatText:
+	}
+
+	buffered := bufio.NewWriter(w)
+	fmt.Fprintf(buffered, "# %s, %d subnets, exported at %s\n",
+		listType, len(subnets), time.Now().UTC().Format(time.RFC3339))
+	for _, subnet := range subnets {
+		if subnet.Comment != "" {
+			// Переводы строк в комментарии превратили бы его остаток в подсеть
+			fmt.Fprintf(buffered, "%s # %s\n", subnet.CIDR, strings.Join(strings.Fields(subnet.Comment), " "))
+		} else {
+			fmt.Fprintln(buffered, subnet.CIDR)
+		}
+	}
+
+	return buffered.Flush()
+}
+
+func writeSubnetsCSV(w io.Writer, subnets []domain.Subnet) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, subnet := range subnets {
+		var expiresAt string
+		if subnet.ExpiresAt != nil {
+			expiresAt = subnet.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		var createdAt string
+		if !subnet.CreatedAt.IsZero() {
+			createdAt = subnet.CreatedAt.UTC().Format(time.RFC3339)
+		}
+
+		record := []string{
+			subnet.CIDR,
+			subnet.Comment,
+			strings.Join(subnet.Tags, ";"),
+			expiresAt,
+			subnet.CreatedBy,
+			createdAt,
+			string(subnet.Trust),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
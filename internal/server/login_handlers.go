@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+)
+
+// LoginRuleRequest - правило списка логинов. Kind - "exact", "glob" или "regex", по умолчанию "exact".
+type LoginRuleRequest struct {
+	Kind      domain.LoginRuleKind `json:"kind"`
+	Pattern   string               `json:"pattern"`
+	Comment   string               `json:"comment,omitempty"`
+	CreatedBy string               `json:"createdBy,omitempty"`
+}
+
+type LoginRuleResponse struct {
+	ListType domain.ListType      `json:"listType"`
+	Kind     domain.LoginRuleKind `json:"kind"`
+	Pattern  string               `json:"pattern"`
+	// Rule - правило в виде, в котором оно попадает в ответы /auth и логи: "exact:admin" или "glob:svc-*".
+	Rule      string    `json:"rule"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type LoginRulesListResponse struct {
+	Rules []LoginRuleResponse `json:"rules"`
+	Count int                 `json:"count"`
+}
+
+func (s *Server) loginRulesHandler(listType domain.ListType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			s.getLoginRulesHandler(w, listType)
+		case http.MethodPost:
+			s.addLoginRuleHandler(w, r, listType)
+		case http.MethodDelete:
+			s.removeLoginRuleHandler(w, r, listType)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (s *Server) getLoginRulesHandler(w http.ResponseWriter, listType domain.ListType) {
+	rules, err := s.app.GetLoginRules(listType)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("Get login %s failed: %v", listType, err))
+		s.sendError(w, fmt.Sprintf("Failed to get login %s: %v", listType, err), http.StatusInternalServerError)
+		return
+	}
+
+	response := LoginRulesListResponse{Rules: make([]LoginRuleResponse, len(rules)), Count: len(rules)}
+	for i, rule := range rules {
+		response.Rules[i] = toLoginRuleResponse(rule)
+	}
+
+	s.sendJSON(w, response, http.StatusOK)
+}
+
+func (s *Server) addLoginRuleHandler(w http.ResponseWriter, r *http.Request, listType domain.ListType) {
+	var req LoginRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	comment := strings.TrimSpace(req.Comment)
+	if len(comment) > maxCommentLength {
+		s.sendError(w, fmt.Sprintf("comment is longer than %d characters", maxCommentLength), http.StatusBadRequest)
+		return
+	}
+
+	rule := &domain.LoginRule{
+		ListType:  listType,
+		Kind:      req.Kind,
+		Pattern:   req.Pattern,
+		Comment:   comment,
+		CreatedBy: strings.TrimSpace(req.CreatedBy),
+	}
+	if err := s.app.CreateLoginRule(rule); err != nil {
+		if errors.Is(err, domain.ErrInvalidLoginRule) {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.logger.Error(fmt.Sprintf("Add login rule to %s failed: %v", listType, err))
+		s.sendError(w, fmt.Sprintf("Failed to add login rule to %s: %v", listType, err), http.StatusInternalServerError)
+		return
+	}
+
+	s.sendJSON(w, toLoginRuleResponse(*rule), http.StatusCreated)
+}
+
+func (s *Server) removeLoginRuleHandler(w http.ResponseWriter, r *http.Request, listType domain.ListType) {
+	var req LoginRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.app.DeleteLoginRule(listType, req.Kind, req.Pattern); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidLoginRule):
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrLoginRuleNotFound):
+			s.sendError(w, fmt.Sprintf("Login rule %s:%s not found in %s", req.Kind, req.Pattern, listType),
+				http.StatusNotFound)
+		default:
+			s.sendError(w, fmt.Sprintf("Failed to remove login rule from %s: %v", listType, err),
+				http.StatusInternalServerError)
+		}
+		return
+	}
+
+	s.sendJSON(w, map[string]string{
+		"message": fmt.Sprintf("Login rule removed from %s successfully", listType),
+	}, http.StatusOK)
+}
+
+func toLoginRuleResponse(rule domain.LoginRule) LoginRuleResponse {
+	return LoginRuleResponse{
+		ListType:  rule.ListType,
+		Kind:      rule.Kind,
+		Pattern:   rule.Pattern,
+		Rule:      rule.String(),
+		Comment:   rule.Comment,
+		CreatedBy: rule.CreatedBy,
+		CreatedAt: rule.CreatedAt,
+	}
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+)
+
+// MetricsResponse - метрики сервиса. Формат совпадает с выводом expvar, чтобы сборщики метрик
+// достаточно было перенастроить на новый адрес.
+type MetricsResponse struct {
+	IPListsCacheAgeSeconds float64 `json:"ip_lists_cache_age_seconds"`
+}
+
+// setupMetricsRoutes возвращает маршруты отдельного адреса для метрик. На адресе API их нет.
+func (s *Server) setupMetricsRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/vars", s.metricsHandler)
+
+	return mux
+}
+
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.sendJSON(w, MetricsResponse{IPListsCacheAgeSeconds: s.app.CacheAge().Seconds()}, http.StatusOK)
+}
@@ -11,10 +11,11 @@ import (
 )
 
 type Server struct {
-	server *http.Server
-	logger Logger
-	app    Application
-	config Conf
+	server        *http.Server
+	metricsServer *http.Server
+	logger        Logger
+	app           Application
+	config        Conf
 }
 
 type Logger interface {
@@ -23,16 +24,38 @@ type Logger interface {
 }
 
 type Application interface {
-	CreateSubnet(subnet *domain.Subnet) error
+	CreateSubnet(subnet *domain.Subnet, force bool) ([]domain.SubnetOverlap, error)
 	DeleteSubnet(listType domain.ListType, cidr string) error
-	GetSubnetsByListType(listType domain.ListType) ([]domain.Subnet, error)
+	CreateSubnetRange(
+		template domain.Subnet, ipRange domain.IPRange, force bool,
+	) ([]domain.Subnet, []domain.SubnetOverlap, error)
+	DeleteSubnetRange(listType domain.ListType, ipRange domain.IPRange) ([]domain.Subnet, error)
+	GetSubnetsByListType(listType domain.ListType, filter domain.SubnetFilter) (domain.SubnetsPage, error)
+	GetConflicts() ([]domain.SubnetOverlap, error)
+	ImportSubnets(
+		listType domain.ListType, subnets []domain.Subnet, opts domain.ImportOptions,
+	) (domain.ImportResult, error)
+	ExportSubnets(listType domain.ListType) ([]domain.Subnet, error)
 	CheckAuth(req domain.AuthRequest) (domain.AuthResponse, error)
 	ResetBuckets(req domain.ResetBucketsRequest) (domain.ResetBucketsResponse, error)
+	GetBuckets(req domain.BucketsRequest) (domain.BucketsResponse, error)
+	Explain(req domain.ExplainRequest) (domain.ExplainResponse, error)
+	FeedStatuses() ([]domain.FeedStatus, error)
+	CreateGeoRule(rule *domain.GeoRule) error
+	DeleteGeoRule(listType domain.ListType, kind domain.GeoRuleKind, value string) error
+	GetGeoRules(listType domain.ListType) ([]domain.GeoRule, error)
+	CreateLoginRule(rule *domain.LoginRule) error
+	DeleteLoginRule(listType domain.ListType, kind domain.LoginRuleKind, pattern string) error
+	GetLoginRules(listType domain.ListType) ([]domain.LoginRule, error)
+	CacheAge() time.Duration
 }
 
 type Conf struct {
 	Host string
 	Port string
+
+	MetricsHost string
+	MetricsPort string
 }
 
 func NewServer(logger Logger, app Application, config Conf) *Server {
@@ -64,6 +87,24 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	if s.config.MetricsPort != "" {
+		s.metricsServer = &http.Server{
+			Addr:         net.JoinHostPort(s.config.MetricsHost, s.config.MetricsPort),
+			Handler:      s.setupMetricsRoutes(),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+
+		go func() {
+			s.logger.Info(fmt.Sprintf("Metrics server starting on %s", s.metricsServer.Addr))
+
+			if err := s.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				s.logger.Error(fmt.Sprintf("Metrics server failed: %v", err))
+			}
+		}()
+	}
+
 	<-ctx.Done()
 	return nil
 }
@@ -76,6 +117,11 @@ func (s *Server) Stop(ctx context.Context) error {
 			return fmt.Errorf("HTTP server shutdown error: %w", err)
 		}
 	}
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("metrics server shutdown error: %w", err)
+		}
+	}
 
 	s.logger.Info("HTTP server stopped")
 	return nil
@@ -0,0 +1,118 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+const feedStatusColumns = "name, last_attempt, last_sync, entries, invalid, added, updated, removed, error"
+
+// feedLockPrefix отделяет блокировки фидов от других рекомендательных блокировок в той же базе.
+const feedLockPrefix = "feed_sync:"
+
+type FeedRepository struct {
+	db *sqlx.DB
+}
+
+type feedStatusDB struct {
+	Name        string       `db:"name"`
+	LastAttempt sql.NullTime `db:"last_attempt"`
+	LastSync    sql.NullTime `db:"last_sync"`
+	Entries     int          `db:"entries"`
+	Invalid     int          `db:"invalid"`
+	Added       int          `db:"added"`
+	Updated     int          `db:"updated"`
+	Removed     int          `db:"removed"`
+	Error       string       `db:"error"`
+}
+
+func (f feedStatusDB) toDomain() domain.FeedStatus {
+	status := domain.FeedStatus{
+		Name:    f.Name,
+		Entries: f.Entries,
+		Invalid: f.Invalid,
+		Added:   f.Added,
+		Updated: f.Updated,
+		Removed: f.Removed,
+		Error:   f.Error,
+	}
+	if f.LastAttempt.Valid {
+		status.LastAttempt = &f.LastAttempt.Time
+	}
+	if f.LastSync.Valid {
+		status.LastSync = &f.LastSync.Time
+	}
+
+	return status
+}
+
+// Lock берёт сессионную рекомендательную блокировку Postgres. Она держится на отдельном соединении
+// и снимается сама, если экземпляр, взявший её, упадёт.
+func (r *FeedRepository) Lock(ctx context.Context, name string) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := feedLockPrefix + name
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		// Соединение возвращается в пул, поэтому блокировку нужно снять явно
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, key)
+		conn.Close()
+	}, true, nil
+}
+
+func (r *FeedRepository) GetStatuses() ([]domain.FeedStatus, error) {
+	var statusesDB []feedStatusDB
+	if err := r.db.Select(&statusesDB, `SELECT `+feedStatusColumns+` FROM feed_statuses ORDER BY name`); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]domain.FeedStatus, len(statusesDB))
+	for i, status := range statusesDB {
+		statuses[i] = status.toDomain()
+	}
+
+	return statuses, nil
+}
+
+func (r *FeedRepository) SaveStatus(status domain.FeedStatus) error {
+	query := `
+		INSERT INTO feed_statuses (` + feedStatusColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (name) DO UPDATE SET
+			last_attempt = EXCLUDED.last_attempt,
+			last_sync = EXCLUDED.last_sync,
+			entries = EXCLUDED.entries,
+			invalid = EXCLUDED.invalid,
+			added = EXCLUDED.added,
+			updated = EXCLUDED.updated,
+			removed = EXCLUDED.removed,
+			error = EXCLUDED.error`
+
+	_, err := r.db.Exec(query, status.Name, nullTime(status.LastAttempt), nullTime(status.LastSync),
+		status.Entries, status.Invalid, status.Added, status.Updated, status.Removed, status.Error)
+	return err
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
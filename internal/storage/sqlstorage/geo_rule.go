@@ -0,0 +1,91 @@
+package sqlstorage
+
+import (
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+const geoRuleColumns = "list_type, kind, value, comment, created_by, created_at"
+
+type GeoRuleRepository struct {
+	db *sqlx.DB
+}
+
+type geoRuleDB struct {
+	ListType  string    `db:"list_type"`
+	Kind      string    `db:"kind"`
+	Value     string    `db:"value"`
+	Comment   string    `db:"comment"`
+	CreatedBy string    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (g geoRuleDB) toDomain() domain.GeoRule {
+	return domain.GeoRule{
+		ListType:  domain.ListType(g.ListType),
+		Kind:      domain.GeoRuleKind(g.Kind),
+		Value:     g.Value,
+		Comment:   g.Comment,
+		CreatedBy: g.CreatedBy,
+		CreatedAt: g.CreatedAt,
+	}
+}
+
+func (r *GeoRuleRepository) Create(rule *domain.GeoRule) error {
+	// Повторное добавление правила обновляет комментарий, но сохраняет автора и время создания
+	query := `
+		INSERT INTO geo_rules (list_type, kind, value, comment, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (list_type, kind, value) DO UPDATE SET comment = EXCLUDED.comment
+		RETURNING ` + geoRuleColumns
+
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		var created geoRuleDB
+		err := tx.Get(&created, query,
+			string(rule.ListType), string(rule.Kind), rule.Value, rule.Comment, rule.CreatedBy)
+		if err != nil {
+			return err
+		}
+		*rule = created.toDomain()
+
+		return notifyRulesChange(tx, domain.GeoRulesChanged)
+	})
+}
+
+func (r *GeoRuleRepository) Delete(listType domain.ListType, kind domain.GeoRuleKind, value string) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`DELETE FROM geo_rules WHERE list_type = $1 AND kind = $2 AND value = $3`,
+			string(listType), string(kind), value)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return domain.ErrGeoRuleNotFound
+		}
+
+		return notifyRulesChange(tx, domain.GeoRulesChanged)
+	})
+}
+
+func (r *GeoRuleRepository) GetAll() ([]domain.GeoRule, error) {
+	query := `SELECT ` + geoRuleColumns + ` FROM geo_rules ORDER BY list_type, kind, value`
+
+	var rulesDB []geoRuleDB
+	if err := r.db.Select(&rulesDB, query); err != nil {
+		return nil, err
+	}
+
+	rules := make([]domain.GeoRule, len(rulesDB))
+	for i, rule := range rulesDB {
+		rules[i] = rule.toDomain()
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,142 @@
+package sqlstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// subnetsChannel - канал NOTIFY, в который репозиторий сообщает об изменениях подсетей.
+const subnetsChannel = "subnets_changed"
+
+type subnetNotification struct {
+	Op        domain.SubnetChangeOp `json:"op"`
+	ListType  string                `json:"listType"`
+	CIDR      string                `json:"cidr"`
+	ExpiresAt *time.Time            `json:"expiresAt,omitempty"`
+	Trust     string                `json:"trust,omitempty"`
+}
+
+func notifySubnetChange(tx *sqlx.Tx, op domain.SubnetChangeOp, subnet subnetDB) error {
+	notification := subnetNotification{Op: op, ListType: subnet.ListType, CIDR: subnet.CIDR, Trust: subnet.Trust}
+	if subnet.ExpiresAt.Valid {
+		notification.ExpiresAt = &subnet.ExpiresAt.Time
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`SELECT pg_notify($1, $2)`, subnetsChannel, string(payload))
+	return err
+}
+
+// notifyRulesChange сообщает, что правила, о которых говорит op, нужно перечитать.
+func notifyRulesChange(tx *sqlx.Tx, op domain.SubnetChangeOp) error {
+	payload, err := json.Marshal(subnetNotification{Op: op})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`SELECT pg_notify($1, $2)`, subnetsChannel, string(payload))
+	return err
+}
+
+// SubnetListener получает изменения подсетей через LISTEN/NOTIFY.
+type SubnetListener struct {
+	listener *pq.Listener
+	changes  chan domain.SubnetChange
+}
+
+// NewSubnetListener подписывается на изменения подсетей. Соединение восстанавливается автоматически.
+func NewSubnetListener(connectionString string) (*SubnetListener, error) {
+	l := &SubnetListener{
+		listener: pq.NewListener(connectionString, time.Second, time.Minute, nil),
+		changes:  make(chan domain.SubnetChange, 64),
+	}
+
+	if err := l.listener.Listen(subnetsChannel); err != nil {
+		l.listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", subnetsChannel, err)
+	}
+
+	return l, nil
+}
+
+func (l *SubnetListener) Changes() <-chan domain.SubnetChange {
+	return l.changes
+}
+
+// Run пересылает уведомления в Changes, пока не завершится контекст.
+func (l *SubnetListener) Run(ctx context.Context) {
+	defer close(l.changes)
+
+	// Проверка соединения, чтобы разрыв обнаруживался, даже если уведомлений нет
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ping.C:
+			go l.listener.Ping()
+		case notification, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// Неразборчивое уведомление не должно оставить кэш устаревшим: списки перечитываются целиком
+			change, err := parseSubnetNotification(notification)
+			if err != nil {
+				change = domain.SubnetChange{Op: domain.SubnetResync}
+			}
+
+			select {
+			case l.changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (l *SubnetListener) Close() error {
+	return l.listener.Close()
+}
+
+// parseSubnetNotification разбирает уведомление. nil приходит после переподключения.
+func parseSubnetNotification(notification *pq.Notification) (domain.SubnetChange, error) {
+	if notification == nil {
+		return domain.SubnetChange{Op: domain.SubnetResync}, nil
+	}
+
+	var payload subnetNotification
+	if err := json.Unmarshal([]byte(notification.Extra), &payload); err != nil {
+		return domain.SubnetChange{}, fmt.Errorf("invalid subnet notification: %w", err)
+	}
+
+	switch payload.Op {
+	case domain.SubnetUpserted, domain.SubnetDeleted:
+	case domain.SubnetResync, domain.GeoRulesChanged, domain.LoginRulesChanged:
+		return domain.SubnetChange{Op: payload.Op}, nil
+	default:
+		return domain.SubnetChange{}, fmt.Errorf("unknown subnet notification op: %s", payload.Op)
+	}
+
+	return domain.SubnetChange{
+		Op: payload.Op,
+		Subnet: domain.Subnet{
+			ListType:  domain.ListType(payload.ListType),
+			CIDR:      payload.CIDR,
+			ExpiresAt: payload.ExpiresAt,
+			Trust:     domain.TrustLevel(payload.Trust),
+		},
+	}, nil
+}
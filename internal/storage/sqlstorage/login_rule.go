@@ -0,0 +1,91 @@
+package sqlstorage
+
+import (
+	"time"
+
+	"github.com/gomonov/otus-go-project/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+const loginRuleColumns = "list_type, kind, pattern, comment, created_by, created_at"
+
+type LoginRuleRepository struct {
+	db *sqlx.DB
+}
+
+type loginRuleDB struct {
+	ListType  string    `db:"list_type"`
+	Kind      string    `db:"kind"`
+	Pattern   string    `db:"pattern"`
+	Comment   string    `db:"comment"`
+	CreatedBy string    `db:"created_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (l loginRuleDB) toDomain() domain.LoginRule {
+	return domain.LoginRule{
+		ListType:  domain.ListType(l.ListType),
+		Kind:      domain.LoginRuleKind(l.Kind),
+		Pattern:   l.Pattern,
+		Comment:   l.Comment,
+		CreatedBy: l.CreatedBy,
+		CreatedAt: l.CreatedAt,
+	}
+}
+
+func (r *LoginRuleRepository) Create(rule *domain.LoginRule) error {
+	// Повторное добавление правила обновляет комментарий, но сохраняет автора и время создания
+	query := `
+		INSERT INTO login_rules (list_type, kind, pattern, comment, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (list_type, kind, pattern) DO UPDATE SET comment = EXCLUDED.comment
+		RETURNING ` + loginRuleColumns
+
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		var created loginRuleDB
+		err := tx.Get(&created, query,
+			string(rule.ListType), string(rule.Kind), rule.Pattern, rule.Comment, rule.CreatedBy)
+		if err != nil {
+			return err
+		}
+		*rule = created.toDomain()
+
+		return notifyRulesChange(tx, domain.LoginRulesChanged)
+	})
+}
+
+func (r *LoginRuleRepository) Delete(listType domain.ListType, kind domain.LoginRuleKind, pattern string) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(`DELETE FROM login_rules WHERE list_type = $1 AND kind = $2 AND pattern = $3`,
+			string(listType), string(kind), pattern)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return domain.ErrLoginRuleNotFound
+		}
+
+		return notifyRulesChange(tx, domain.LoginRulesChanged)
+	})
+}
+
+func (r *LoginRuleRepository) GetAll() ([]domain.LoginRule, error) {
+	query := `SELECT ` + loginRuleColumns + ` FROM login_rules ORDER BY list_type, kind, pattern`
+
+	var rulesDB []loginRuleDB
+	if err := r.db.Select(&rulesDB, query); err != nil {
+		return nil, err
+	}
+
+	rules := make([]domain.LoginRule, len(rulesDB))
+	for i, rule := range rulesDB {
+		rules[i] = rule.toDomain()
+	}
+
+	return rules, nil
+}
@@ -1,6 +1,8 @@
 package sqlstorage
 
 import (
+	"context"
+
 	"github.com/gomonov/otus-go-project/internal/storage"
 	"github.com/jmoiron/sqlx"
 )
@@ -9,17 +11,34 @@ type Storage struct {
 	db *sqlx.DB
 }
 
+// NewStorage создаёт хранилище, не подключаясь к базе: соединение устанавливается при первом запросе,
+// поэтому сервис запускается, даже пока база недоступна. Доступность проверяет Ping.
 func NewStorage(connectionString string) (*Storage, error) {
-	db, err := sqlx.Connect("postgres", connectionString)
+	db, err := sqlx.Open("postgres", connectionString)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	return &Storage{db: db}, nil
+}
+
+// inTx выполняет fn в транзакции. Уведомления NOTIFY доставляются только после фиксации транзакции.
+func inTx(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
 	}
 
-	return &Storage{db: db}, nil
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
 }
 
 func (s *Storage) Close() error {
@@ -29,3 +48,15 @@ func (s *Storage) Close() error {
 func (s *Storage) Subnet() storage.SubnetRepository {
 	return &SubnetRepository{db: s.db}
 }
+
+func (s *Storage) GeoRule() storage.GeoRuleRepository {
+	return &GeoRuleRepository{db: s.db}
+}
+
+func (s *Storage) LoginRule() storage.LoginRuleRepository {
+	return &LoginRuleRepository{db: s.db}
+}
+
+func (s *Storage) Feed() storage.FeedRepository {
+	return &FeedRepository{db: s.db}
+}
@@ -1,71 +1,241 @@
 package sqlstorage
 
 import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/gomonov/otus-go-project/internal/domain"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+const subnetColumns = "list_type, cidr, expires_at, comment, created_by, created_at, updated_at, tags, source, trust"
+
 type SubnetRepository struct {
 	db *sqlx.DB
 }
 
 type subnetDB struct {
-	ListType string `db:"list_type"`
-	CIDR     string `db:"cidr"`
+	ListType  string         `db:"list_type"`
+	CIDR      string         `db:"cidr"`
+	ExpiresAt sql.NullTime   `db:"expires_at"`
+	Comment   string         `db:"comment"`
+	CreatedBy string         `db:"created_by"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+	Tags      pq.StringArray `db:"tags"`
+	Source    string         `db:"source"`
+	Trust     string         `db:"trust"`
 }
 
 func (s subnetDB) toDomain() domain.Subnet {
-	return domain.Subnet{
-		ListType: domain.ListType(s.ListType),
-		CIDR:     s.CIDR,
+	subnet := domain.Subnet{
+		ListType:  domain.ListType(s.ListType),
+		CIDR:      s.CIDR,
+		Comment:   s.Comment,
+		CreatedBy: s.CreatedBy,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Tags:      []string(s.Tags),
+		Source:    s.Source,
+		Trust:     domain.TrustLevel(s.Trust),
+	}
+	if s.ExpiresAt.Valid {
+		expiresAt := s.ExpiresAt.Time
+		subnet.ExpiresAt = &expiresAt
 	}
+
+	return subnet
 }
 
 func toSubnetDB(s domain.Subnet) subnetDB {
-	return subnetDB{
-		ListType: string(s.ListType),
-		CIDR:     s.CIDR,
+	subnet := subnetDB{
+		ListType:  string(s.ListType),
+		CIDR:      s.CIDR,
+		Comment:   s.Comment,
+		CreatedBy: s.CreatedBy,
+		Tags:      pq.StringArray(s.Tags),
+		Source:    s.Source,
+		Trust:     string(s.Trust),
+	}
+	if subnet.Tags == nil {
+		subnet.Tags = pq.StringArray{}
+	}
+	if s.ExpiresAt != nil {
+		subnet.ExpiresAt = sql.NullTime{Time: *s.ExpiresAt, Valid: true}
 	}
+
+	return subnet
 }
 
 func (r *SubnetRepository) Create(subnet *domain.Subnet) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		return createSubnet(tx, subnet)
+	})
+}
+
+func (r *SubnetRepository) CreateAll(subnets []*domain.Subnet) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		for _, subnet := range subnets {
+			if err := createSubnet(tx, subnet); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func createSubnet(tx *sqlx.Tx, subnet *domain.Subnet) error {
+	// Повторное добавление подсети обновляет срок её действия, комментарий, теги и уровень доверия,
+	// но сохраняет автора и время создания. Подсеть фида, добавленная вручную, перестаёт
+	// принадлежать фиду и не удаляется при его синхронизации
 	query := `
-		INSERT INTO subnets (list_type, cidr) 
-		VALUES (:list_type, :cidr)
-		ON CONFLICT (list_type, cidr) DO NOTHING
+		INSERT INTO subnets (list_type, cidr, expires_at, comment, created_by, tags, source, trust)
+		VALUES (:list_type, :cidr, :expires_at, :comment, :created_by, :tags, :source, :trust)
+		ON CONFLICT (list_type, cidr) DO UPDATE SET
+			expires_at = EXCLUDED.expires_at,
+			comment = EXCLUDED.comment,
+			tags = EXCLUDED.tags,
+			source = EXCLUDED.source,
+			trust = EXCLUDED.trust,
+			updated_at = now()
+		RETURNING ` + subnetColumns + `
 	`
 
-	subnetDB := toSubnetDB(*subnet)
-	_, err := r.db.NamedExec(query, &subnetDB)
-	return err
+	rows, err := tx.NamedQuery(query, toSubnetDB(*subnet))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var created subnetDB
+	if !rows.Next() {
+		return sql.ErrNoRows
+	}
+	if err := rows.StructScan(&created); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	*subnet = created.toDomain()
+
+	return notifySubnetChange(tx, domain.SubnetUpserted, created)
 }
 
 func (r *SubnetRepository) Delete(listType domain.ListType, cidr string) error {
-	query := `DELETE FROM subnets WHERE list_type = $1 AND cidr = $2`
+	query := `DELETE FROM subnets WHERE list_type = $1 AND cidr = $2 RETURNING ` + subnetColumns
+
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		var deleted []subnetDB
+		if err := tx.Select(&deleted, query, string(listType), cidr); err != nil {
+			return err
+		}
 
-	result, err := r.db.Exec(query, string(listType), cidr)
+		if len(deleted) == 0 {
+			return domain.ErrSubnetNotFound
+		}
+
+		return notifySubnetChange(tx, domain.SubnetDeleted, deleted[0])
+	})
+}
+
+func (r *SubnetRepository) DeleteAll(listType domain.ListType, cidrs []string) ([]domain.Subnet, error) {
+	query := `DELETE FROM subnets WHERE list_type = $1 AND cidr = ANY($2::cidr[]) RETURNING ` + subnetColumns
+
+	var deleted []subnetDB
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := tx.Select(&deleted, query, string(listType), pq.Array(cidrs)); err != nil {
+			return err
+		}
+
+		if len(deleted) == 0 {
+			return domain.ErrSubnetNotFound
+		}
+
+		for _, subnet := range deleted {
+			if err := notifySubnetChange(tx, domain.SubnetDeleted, subnet); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	subnets := make([]domain.Subnet, len(deleted))
+	for i, subnet := range deleted {
+		subnets[i] = subnet.toDomain()
+	}
+
+	return subnets, nil
+}
+
+func (r *SubnetRepository) GetByListType(
+	listType domain.ListType, filter domain.SubnetFilter,
+) ([]domain.Subnet, error) {
+	conditions := []string{"list_type = $1", "(expires_at IS NULL OR expires_at > now())"}
+	args := []interface{}{string(listType)}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Tag != "" {
+		where("tags @> ARRAY[$%d::text]", filter.Tag)
+	}
+	if filter.Source != "" {
+		where("source = $%d", filter.StoredSource())
+	}
+	// >>= и <<= используют GiST-индекс subnets_cidr_gist_idx
+	if filter.Contains.IsValid() {
+		where("cidr >>= $%d::inet", filter.Contains.String())
+	}
+	if filter.Within.IsValid() {
+		where("cidr <<= $%d::cidr", filter.Within.String())
+	}
+	if !filter.CreatedSince.IsZero() {
+		where("created_at >= $%d", filter.CreatedSince)
+	}
+	// Страницы выбираются по ключу, а не через OFFSET: первичный ключ (list_type, cidr) отдаёт следующую
+	// страницу сразу, и подсети, добавленные между запросами, не сдвигают её
+	if filter.After != "" {
+		where("cidr > $%d::cidr", filter.After)
+	}
+
+	query := `SELECT ` + subnetColumns + ` FROM subnets WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY cidr`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	var subnetsDB []subnetDB
+	err := r.db.Select(&subnetsDB, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if rowsAffected == 0 {
-		return domain.ErrSubnetNotFound
+	subnets := make([]domain.Subnet, len(subnetsDB))
+	for i, subnet := range subnetsDB {
+		subnets[i] = subnet.toDomain()
 	}
 
-	return nil
+	return subnets, nil
 }
 
-func (r *SubnetRepository) GetByListType(listType domain.ListType) ([]domain.Subnet, error) {
-	query := `SELECT list_type, cidr FROM subnets WHERE list_type = $1 ORDER BY cidr`
+func (r *SubnetRepository) FindOverlapping(listType domain.ListType, cidr string) ([]domain.Subnet, error) {
+	// && использует GiST-индекс subnets_cidr_gist_idx
+	query := `
+		SELECT ` + subnetColumns + ` FROM subnets
+		WHERE cidr && $1::cidr AND (expires_at IS NULL OR expires_at > now())
+			AND NOT (list_type = $2 AND cidr = $1::cidr)
+		ORDER BY list_type, cidr
+	`
 
 	var subnetsDB []subnetDB
-	err := r.db.Select(&subnetsDB, query, string(listType))
-	if err != nil {
+	if err := r.db.Select(&subnetsDB, query, cidr, string(listType)); err != nil {
 		return nil, err
 	}
 
@@ -76,3 +246,145 @@ func (r *SubnetRepository) GetByListType(listType domain.ListType) ([]domain.Sub
 
 	return subnets, nil
 }
+
+type subnetOverlapDB struct {
+	Subnet subnetDB `db:"subnet"`
+	Other  subnetDB `db:"other"`
+}
+
+func (r *SubnetRepository) FindOverlaps() ([]domain.SubnetOverlap, error) {
+	// Каждая пара возвращается один раз: подсеть чёрного списка раньше подсети белого,
+	// а в одном списке более узкая подсеть раньше покрывающей её
+	query := `
+		SELECT ` + prefixedSubnetColumns("s", "subnet") + `, ` + prefixedSubnetColumns("o", "other") + `
+		FROM subnets s
+		JOIN subnets o ON s.cidr && o.cidr
+		WHERE (s.expires_at IS NULL OR s.expires_at > now())
+			AND (o.expires_at IS NULL OR o.expires_at > now())
+			AND (
+				s.list_type < o.list_type
+				OR (s.list_type = o.list_type AND masklen(s.cidr) > masklen(o.cidr))
+			)
+		ORDER BY s.list_type, s.cidr, o.list_type, o.cidr
+	`
+
+	var overlapsDB []subnetOverlapDB
+	if err := r.db.Select(&overlapsDB, query); err != nil {
+		return nil, err
+	}
+
+	overlaps := make([]domain.SubnetOverlap, len(overlapsDB))
+	for i, overlap := range overlapsDB {
+		overlaps[i] = domain.SubnetOverlap{
+			Subnet: overlap.Subnet.toDomain(),
+			Other:  overlap.Other.toDomain(),
+		}
+	}
+
+	return overlaps, nil
+}
+
+// prefixedSubnetColumns перечисляет колонки таблицы с алиасом table как prefix.колонка для вложенных структур sqlx.
+func prefixedSubnetColumns(table, prefix string) string {
+	columns := strings.Split(subnetColumns, ", ")
+	for i, column := range columns {
+		columns[i] = fmt.Sprintf(`%s.%s AS "%s.%s"`, table, column, prefix, column)
+	}
+
+	return strings.Join(columns, ", ")
+}
+
+func (r *SubnetRepository) Import(
+	listType domain.ListType, source string, subnets []domain.Subnet, replace bool,
+) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TEMP TABLE subnets_import
+			(LIKE subnets INCLUDING DEFAULTS) ON COMMIT DROP
+		`)
+		if err != nil {
+			return err
+		}
+
+		// Десятки тысяч строк загружаются через COPY, а не отдельными INSERT
+		stmt, err := tx.Prepare(pq.CopyIn("subnets_import",
+			"list_type", "cidr", "expires_at", "comment", "created_by", "tags", "source", "trust"))
+		if err != nil {
+			return err
+		}
+		for _, subnet := range subnets {
+			subnet.ListType, subnet.Source = listType, source
+			row := toSubnetDB(subnet)
+			_, err := stmt.Exec(row.ListType, row.CIDR, row.ExpiresAt, row.Comment, row.CreatedBy, row.Tags,
+				row.Source, row.Trust)
+			if err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		if _, err := stmt.Exec(); err != nil {
+			stmt.Close()
+			return err
+		}
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+
+		// Фид не перезаписывает подсети, добавленные вручную, а ручной импорт забирает подсети у фидов
+		_, err = tx.Exec(`
+			INSERT INTO subnets (list_type, cidr, expires_at, comment, created_by, tags, source, trust)
+			SELECT list_type, cidr, expires_at, comment, created_by, tags, source, trust FROM subnets_import
+			ON CONFLICT (list_type, cidr) DO UPDATE SET
+				expires_at = EXCLUDED.expires_at,
+				comment = EXCLUDED.comment,
+				tags = EXCLUDED.tags,
+				source = EXCLUDED.source,
+				trust = EXCLUDED.trust,
+				updated_at = now()
+			WHERE (subnets.source = EXCLUDED.source OR EXCLUDED.source = '')
+				AND (subnets.expires_at, subnets.comment, subnets.tags, subnets.source, subnets.trust)
+					IS DISTINCT FROM (EXCLUDED.expires_at, EXCLUDED.comment, EXCLUDED.tags, EXCLUDED.source,
+						EXCLUDED.trust)
+		`)
+		if err != nil {
+			return err
+		}
+
+		if replace {
+			_, err = tx.Exec(`
+				DELETE FROM subnets s
+				WHERE s.list_type = $1 AND s.source = $2
+					AND NOT EXISTS (SELECT 1 FROM subnets_import i WHERE i.cidr = s.cidr)
+			`, string(listType), source)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Поштучные уведомления о десятках тысяч подсетей дороже, чем перечитать списки целиком
+		return notifySubnetChange(tx, domain.SubnetResync, subnetDB{ListType: string(listType)})
+	})
+}
+
+func (r *SubnetRepository) DeleteExpired(now time.Time) (int64, error) {
+	query := `DELETE FROM subnets WHERE expires_at <= $1 RETURNING ` + subnetColumns
+
+	var deleted []subnetDB
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := tx.Select(&deleted, query, now); err != nil {
+			return err
+		}
+
+		for _, subnet := range deleted {
+			if err := notifySubnetChange(tx, domain.SubnetDeleted, subnet); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(deleted)), nil
+}
@@ -1,16 +1,72 @@
 package storage
 
 import (
+	"context"
+	"time"
+
 	"github.com/gomonov/otus-go-project/internal/domain"
 )
 
 type Storage interface {
 	Subnet() SubnetRepository
+	GeoRule() GeoRuleRepository
+	LoginRule() LoginRuleRepository
+	Feed() FeedRepository
 	Close() error
 }
 
 type SubnetRepository interface {
+	// Create добавляет или обновляет подсеть и заполняет subnet сохранёнными значениями.
 	Create(subnet *domain.Subnet) error
+	// CreateAll добавляет или обновляет подсети в одной транзакции, как Create.
+	CreateAll(subnets []*domain.Subnet) error
 	Delete(listType domain.ListType, network string) error
-	GetByListType(listType domain.ListType) ([]domain.Subnet, error)
+	// DeleteAll в одной транзакции удаляет подсети cidrs списка listType и возвращает удалённые.
+	// Если не нашлось ни одной, возвращает domain.ErrSubnetNotFound.
+	DeleteAll(listType domain.ListType, cidrs []string) ([]domain.Subnet, error)
+	GetByListType(listType domain.ListType, filter domain.SubnetFilter) ([]domain.Subnet, error)
+	// FindOverlapping возвращает действующие подсети всех списков, пересекающиеся с cidr,
+	// кроме самой подсети в списке listType.
+	FindOverlapping(listType domain.ListType, cidr string) ([]domain.Subnet, error)
+	// FindOverlaps возвращает все пары пересекающихся действующих подсетей. Вид пересечения не заполняется.
+	FindOverlaps() ([]domain.SubnetOverlap, error)
+	// Import в одной транзакции добавляет или обновляет подсети списка listType от источника source,
+	// а с replace удаляет подсети списка от того же источника, которых нет в subnets.
+	// Подсети с пустым источником (добавленные вручную) не перезаписываются фидами.
+	// CIDR в subnets не должны повторяться.
+	Import(listType domain.ListType, source string, subnets []domain.Subnet, replace bool) error
+	// DeleteExpired удаляет записи, срок действия которых истёк к моменту now, и возвращает их число.
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type GeoRuleRepository interface {
+	// Create добавляет или обновляет правило и заполняет rule сохранёнными значениями.
+	Create(rule *domain.GeoRule) error
+	Delete(listType domain.ListType, kind domain.GeoRuleKind, value string) error
+	GetAll() ([]domain.GeoRule, error)
+}
+
+type LoginRuleRepository interface {
+	// Create добавляет или обновляет правило и заполняет rule сохранёнными значениями.
+	Create(rule *domain.LoginRule) error
+	Delete(listType domain.ListType, kind domain.LoginRuleKind, pattern string) error
+	GetAll() ([]domain.LoginRule, error)
+}
+
+// FeedRepository хранит состояние синхронизации фидов, общее для всех экземпляров сервиса.
+type FeedRepository interface {
+	// Lock берёт блокировку синхронизации фида name, общую для всех экземпляров сервиса.
+	// acquired равен false, если её держит другой экземпляр. Взятую блокировку снимает unlock.
+	Lock(ctx context.Context, name string) (unlock func(), acquired bool, err error)
+	// GetStatuses возвращает сохранённые состояния фидов. Заполнены только поля синхронизации и Name.
+	GetStatuses() ([]domain.FeedStatus, error)
+	SaveStatus(status domain.FeedStatus) error
+}
+
+// SubnetListener доставляет изменения подсетей, сделанные любым экземпляром сервиса.
+type SubnetListener interface {
+	// Changes закрывается вместе со слушателем. После переподключения приходит изменение
+	// с Op SubnetResync, так как изменения за время разрыва могли потеряться.
+	Changes() <-chan domain.SubnetChange
+	Close() error
 }
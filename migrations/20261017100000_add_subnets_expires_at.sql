@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subnets ADD COLUMN expires_at TIMESTAMPTZ;
+CREATE INDEX subnets_expires_at_idx ON subnets (expires_at) WHERE expires_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS subnets_expires_at_idx;
+ALTER TABLE subnets DROP COLUMN IF EXISTS expires_at;
+-- +goose StatementEnd
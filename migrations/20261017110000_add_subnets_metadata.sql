@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subnets
+    ADD COLUMN comment TEXT NOT NULL DEFAULT '',
+    ADD COLUMN created_by TEXT NOT NULL DEFAULT '',
+    ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+CREATE INDEX subnets_tags_idx ON subnets USING GIN (tags);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS subnets_tags_idx;
+ALTER TABLE subnets
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS created_by,
+    DROP COLUMN IF EXISTS comment;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX subnets_cidr_gist_idx ON subnets USING GIST (cidr inet_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS subnets_cidr_gist_idx;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subnets ADD COLUMN source TEXT NOT NULL DEFAULT '';
+CREATE INDEX subnets_source_idx ON subnets (source) WHERE source <> '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS subnets_source_idx;
+ALTER TABLE subnets DROP COLUMN IF EXISTS source;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE geo_rules
+(
+    list_type list_type NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('country', 'asn')),
+    value TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (list_type, kind, value)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS geo_rules;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subnets ADD COLUMN trust TEXT NOT NULL DEFAULT ''
+    CHECK (trust IN ('', 'bypass_all', 'bypass_ip', 'relaxed'));
+UPDATE subnets SET trust = 'bypass_all' WHERE list_type = 'whitelist';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subnets DROP COLUMN IF EXISTS trust;
+-- +goose StatementEnd
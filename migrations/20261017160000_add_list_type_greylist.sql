@@ -0,0 +1,20 @@
+-- +goose NO TRANSACTION
+-- ALTER TYPE ... ADD VALUE нельзя выполнять в одной транзакции с использованием нового значения
+
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE list_type ADD VALUE IF NOT EXISTS 'greylist';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+BEGIN;
+DELETE FROM subnets WHERE list_type = 'greylist';
+DELETE FROM geo_rules WHERE list_type = 'greylist';
+ALTER TYPE list_type RENAME TO list_type_old;
+CREATE TYPE list_type AS ENUM ('blacklist', 'whitelist');
+ALTER TABLE subnets ALTER COLUMN list_type TYPE list_type USING list_type::text::list_type;
+ALTER TABLE geo_rules ALTER COLUMN list_type TYPE list_type USING list_type::text::list_type;
+DROP TYPE list_type_old;
+COMMIT;
+-- +goose StatementEnd
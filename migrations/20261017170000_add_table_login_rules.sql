@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE login_rules
+(
+    list_type list_type NOT NULL CHECK (list_type IN ('blacklist', 'whitelist')),
+    kind TEXT NOT NULL CHECK (kind IN ('exact', 'glob', 'regex')),
+    pattern TEXT NOT NULL,
+    comment TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (list_type, kind, pattern)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_rules;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX subnets_list_type_created_at_idx ON subnets (list_type, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS subnets_list_type_created_at_idx;
+-- +goose StatementEnd